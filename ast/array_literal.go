@@ -21,6 +21,10 @@ func (a *ArrayLiteral) TokenLiteral() string {
 	return a.Token.Literal
 }
 
+func (a *ArrayLiteral) Span() token.Span {
+	return a.Token.Span
+}
+
 func (a *ArrayLiteral) String() string {
 	items := []string{}
 	for _, el := range a.Elements {
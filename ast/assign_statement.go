@@ -0,0 +1,38 @@
+package ast
+
+import (
+	"fmt"
+	"monkey/token"
+)
+
+// AssignStatement assigns to an existing binding, or to an element of an array
+// or a hash: `x = 1`, `arr[0] += 1`.
+type AssignStatement struct {
+	Token    token.Token // the assignment operator token
+	Target   Expression  // either an *Identifier or an *IndexExpression
+	Operator string      // "=", or a compound operator such as "+="
+	Value    Expression
+}
+
+func (*AssignStatement) statementNode() {}
+
+func (a *AssignStatement) TokenLiteral() string {
+	return a.Token.Literal
+}
+
+func (a *AssignStatement) Span() token.Span {
+	return a.Token.Span
+}
+
+func (a *AssignStatement) String() string {
+	return fmt.Sprintf("(assign %s %s %s)", a.Target.String(), a.Operator, a.Value.String())
+}
+
+// BinaryOperator returns the operator a compound assignment applies, e.g. "+"
+// for "+=". It returns false for a plain "=".
+func (a *AssignStatement) BinaryOperator() (string, bool) {
+	if a.Operator == "=" {
+		return "", false
+	}
+	return a.Operator[:len(a.Operator)-1], true
+}
@@ -11,6 +11,10 @@ type Node interface {
 
 	String() string
 	TokenLiteral() string
+
+	// Span is the location in the source of the token that defines the node,
+	// e.g. the operator of an infix expression or the "let" of a let statement.
+	Span() token.Span
 }
 
 type Statement interface {
@@ -34,6 +38,13 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Span() token.Span {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Span()
+	}
+	return token.Span{} //nolint:exhaustruct
+}
+
 func (p *Program) String() string {
 	statementLines := []string{}
 	for _, s := range p.Statements {
@@ -58,6 +69,10 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Span() token.Span {
+	return ls.Token.Span
+}
+
 func (ls *LetStatement) String() string {
 	return fmt.Sprintf(
 		"(let %s %s)",
@@ -77,6 +92,10 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+func (rs *ReturnStatement) Span() token.Span {
+	return rs.Token.Span
+}
+
 func (rs *ReturnStatement) String() string {
 	return fmt.Sprintf("(return %s)", rs.ReturnValue.String())
 }
@@ -96,6 +115,10 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Span() token.Span {
+	return i.Token.Span
+}
+
 func (i *Identifier) String() string {
 	return i.Value
 }
@@ -115,10 +138,37 @@ func (i *IntegerLiteral) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *IntegerLiteral) Span() token.Span {
+	return i.Token.Span
+}
+
 func (i IntegerLiteral) String() string {
 	return i.Token.Literal
 }
 
+type FloatLiteral struct {
+	Token token.Token // the FLOAT token.
+	Value float64
+}
+
+func NewFloatLiteral(t token.Token, value float64) *FloatLiteral {
+	return &FloatLiteral{t, value}
+}
+
+func (*FloatLiteral) expressionNode() {}
+
+func (f *FloatLiteral) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *FloatLiteral) Span() token.Span {
+	return f.Token.Span
+}
+
+func (f FloatLiteral) String() string {
+	return f.Token.Literal
+}
+
 type ExpressionStatement struct {
 	Token      token.Token // the first token of the expression
 	Expression Expression
@@ -130,6 +180,10 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+func (es *ExpressionStatement) Span() token.Span {
+	return es.Token.Span
+}
+
 func (es *ExpressionStatement) String() string {
 	return fmt.Sprintf("(expr %s)", es.Expression.String())
 }
@@ -150,6 +204,10 @@ func (p *PrefixExpression) TokenLiteral() string {
 	return p.Token.Literal
 }
 
+func (p *PrefixExpression) Span() token.Span {
+	return p.Token.Span
+}
+
 func (p *PrefixExpression) String() string {
 	return fmt.Sprintf("(prefix %s %s)", p.Operator, p.Right.String())
 }
@@ -171,6 +229,10 @@ func (i *InfixExpression) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *InfixExpression) Span() token.Span {
+	return i.Token.Span
+}
+
 func (i *InfixExpression) String() string {
 	return fmt.Sprintf("(infix %s %s %s)", i.Left.String(), i.Operator, i.Right.String())
 }
@@ -194,6 +256,10 @@ func (b *Boolean) TokenLiteral() string {
 	return b.token.Literal
 }
 
+func (b *Boolean) Span() token.Span {
+	return b.token.Span
+}
+
 func (b *Boolean) String() string {
 	return b.TokenLiteral()
 }
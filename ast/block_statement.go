@@ -25,6 +25,10 @@ func (b *BlockStatement) TokenLiteral() string {
 	return b.token.Literal
 }
 
+func (b *BlockStatement) Span() token.Span {
+	return b.token.Span
+}
+
 func (b *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -24,6 +24,8 @@ func (*CallExpression) expressionNode() {}
 
 func (c *CallExpression) TokenLiteral() string { return c.token.Literal }
 
+func (c *CallExpression) Span() token.Span { return c.token.Span }
+
 func (c *CallExpression) String() string {
 	args := []string{}
 	for _, a := range c.arguments {
@@ -0,0 +1,44 @@
+package ast
+
+import (
+	"fmt"
+	"monkey/token"
+)
+
+// ForStatement is a `for (variable in iterable) { body }` loop.
+type ForStatement struct {
+	token    token.Token // the "for" token
+	variable *Identifier
+	iterable Expression
+	body     *BlockStatement
+}
+
+func NewForStatement(t token.Token, variable *Identifier, iterable Expression, body *BlockStatement) *ForStatement {
+	return &ForStatement{t, variable, iterable, body}
+}
+
+func (f *ForStatement) Variable() *Identifier {
+	return f.variable
+}
+
+func (f *ForStatement) Iterable() Expression {
+	return f.iterable
+}
+
+func (f *ForStatement) Body() *BlockStatement {
+	return f.body
+}
+
+func (*ForStatement) statementNode() {}
+
+func (f *ForStatement) TokenLiteral() string {
+	return f.token.Literal
+}
+
+func (f *ForStatement) Span() token.Span {
+	return f.token.Span
+}
+
+func (f *ForStatement) String() string {
+	return fmt.Sprintf("(for %s %s %s)", f.variable.String(), f.iterable.String(), f.body.String())
+}
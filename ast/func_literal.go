@@ -29,6 +29,8 @@ func (*FunctionLiteral) expressionNode() {}
 
 func (f *FunctionLiteral) TokenLiteral() string { return f.token.Literal }
 
+func (f *FunctionLiteral) Span() token.Span { return f.token.Span }
+
 func (f *FunctionLiteral) String() string {
 	params := []string{}
 	for _, p := range f.parameters {
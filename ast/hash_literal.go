@@ -7,12 +7,18 @@ import (
 )
 
 type HashLiteral struct {
+	keys  []Expression // The keys of pairs, in source order
 	pairs map[Expression]Expression
 	token token.Token // The '{' token
 }
 
-func NewHashLiteral(token token.Token, pairs map[Expression]Expression) *HashLiteral {
-	return &HashLiteral{pairs, token}
+func NewHashLiteral(token token.Token, keys []Expression, pairs map[Expression]Expression) *HashLiteral {
+	return &HashLiteral{keys, pairs, token}
+}
+
+// Keys returns the keys of the pairs, in the order they're written in.
+func (h *HashLiteral) Keys() []Expression {
+	return h.keys
 }
 
 func (h *HashLiteral) Pairs() map[Expression]Expression {
@@ -23,12 +29,16 @@ func (h *HashLiteral) TokenLiteral() string {
 	return h.token.Literal
 }
 
+func (h *HashLiteral) Span() token.Span {
+	return h.token.Span
+}
+
 func (h *HashLiteral) expressionNode() {}
 
 func (h *HashLiteral) String() string {
 	pairs := []string{}
-	for key, value := range h.pairs {
-		pairs = append(pairs, fmt.Sprintf("(pair %s %s)", key.String(), value.String()))
+	for _, key := range h.keys {
+		pairs = append(pairs, fmt.Sprintf("(pair %s %s)", key.String(), h.pairs[key].String()))
 	}
 
 	return fmt.Sprintf("(hash %s)", strings.Join(pairs, " "))
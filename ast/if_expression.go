@@ -38,6 +38,10 @@ func (i *IfExpression) TokenLiteral() string {
 	return i.token.Literal
 }
 
+func (i *IfExpression) Span() token.Span {
+	return i.token.Span
+}
+
 func (i *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -1,5 +1,7 @@
 package ast
 
+import "monkey/token"
+
 type IfExpressionAlternative struct {
 	ok      bool
 	content *BlockStatement
@@ -21,6 +23,13 @@ func (i *IfExpressionAlternative) TokenLiteral() string {
 	return i.content.TokenLiteral()
 }
 
+func (i *IfExpressionAlternative) Span() token.Span {
+	if !i.ok {
+		return token.Span{} //nolint:exhaustruct
+	}
+	return i.content.Span()
+}
+
 func (i *IfExpressionAlternative) String() string {
 	return i.content.String()
 }
@@ -0,0 +1,41 @@
+package ast
+
+import (
+	"fmt"
+	"monkey/token"
+)
+
+// ImportExpression evaluates to the exports of a module, e.g.
+// `import("lib/strings.monkey")`.
+type ImportExpression struct {
+	token token.Token // the "import" token
+	path  *StringLiteral
+}
+
+func NewImportExpression(t token.Token, path *StringLiteral) *ImportExpression {
+	return &ImportExpression{t, path}
+}
+
+// Path is the path of the module, as written in the source.
+func (i *ImportExpression) Path() string {
+	return i.path.Value
+}
+
+// PathLiteral is the string literal of the path, as it was written.
+func (i *ImportExpression) PathLiteral() *StringLiteral {
+	return i.path
+}
+
+func (*ImportExpression) expressionNode() {}
+
+func (i *ImportExpression) TokenLiteral() string {
+	return i.token.Literal
+}
+
+func (i *ImportExpression) Span() token.Span {
+	return i.token.Span
+}
+
+func (i *ImportExpression) String() string {
+	return fmt.Sprintf("(import %s)", i.path.String())
+}
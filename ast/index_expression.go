@@ -35,6 +35,10 @@ func (i *IndexExpression) TokenLiteral() string {
 	return i.token.Literal
 }
 
+func (i *IndexExpression) Span() token.Span {
+	return i.token.Span
+}
+
 func (*IndexExpression) expressionNode() {}
 
 func (i *IndexExpression) String() string {
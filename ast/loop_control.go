@@ -0,0 +1,39 @@
+package ast
+
+import "monkey/token"
+
+type BreakStatement struct {
+	Token token.Token // the "break" token
+}
+
+func (*BreakStatement) statementNode() {}
+
+func (b *BreakStatement) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+func (b *BreakStatement) Span() token.Span {
+	return b.Token.Span
+}
+
+func (b *BreakStatement) String() string {
+	return "(break)"
+}
+
+type ContinueStatement struct {
+	Token token.Token // the "continue" token
+}
+
+func (*ContinueStatement) statementNode() {}
+
+func (c *ContinueStatement) TokenLiteral() string {
+	return c.Token.Literal
+}
+
+func (c *ContinueStatement) Span() token.Span {
+	return c.Token.Span
+}
+
+func (c *ContinueStatement) String() string {
+	return "(continue)"
+}
@@ -20,6 +20,10 @@ func (m *MacroLiteral) TokenLiteral() string {
 	return m.token.Literal
 }
 
+func (m *MacroLiteral) Span() token.Span {
+	return m.token.Span
+}
+
 func (m *MacroLiteral) expressionNode() {}
 
 func (m *MacroLiteral) String() string {
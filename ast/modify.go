@@ -1,7 +1,9 @@
 package ast
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 )
 
 type ModifierFunc func(Node) (Node, error)
@@ -10,7 +12,14 @@ type modifiable interface {
 	modify(ModifierFunc) error
 }
 
+// ErrIncomplete is returned by Modify for trees missing some of their nodes,
+// as the parser leaves them where it recovered from an error.
+var ErrIncomplete = errors.New("incomplete syntax tree")
+
 func Modify(node Node, modifier ModifierFunc) (Node, error) {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return nil, ErrIncomplete
+	}
 	if err := node.modify(modifier); err != nil {
 		return nil, err
 	}
@@ -20,7 +29,8 @@ func Modify(node Node, modifier ModifierFunc) (Node, error) {
 func modifyIntoType[T Node](node Node, modifier ModifierFunc) (T, error) {
 	modResult, err := Modify(node, modifier)
 	if err != nil {
-		return modResult.(T), err
+		var zero T
+		return zero, err
 	}
 	converted, ok := modResult.(T)
 	if !ok {
@@ -185,13 +195,17 @@ func (i *Identifier) modify(modify ModifierFunc) error { return nil }
 
 func (i *IntegerLiteral) modify(modify ModifierFunc) error { return nil }
 
+func (f *FloatLiteral) modify(modify ModifierFunc) error { return nil }
+
 func (b *Boolean) modify(modify ModifierFunc) error { return nil }
 
 func (s *StringLiteral) modify(modify ModifierFunc) error { return nil }
 
 func (h *HashLiteral) modify(modify ModifierFunc) error {
+	modifiedKeys := make([]Expression, 0, len(h.keys))
 	modifiedPairs := map[Expression]Expression{}
-	for key, val := range h.pairs {
+	for _, key := range h.keys {
+		val := h.pairs[key]
 		modKey, err := modifyIntoType[Expression](key, modify)
 		if err != nil {
 			return err
@@ -201,16 +215,22 @@ func (h *HashLiteral) modify(modify ModifierFunc) error {
 		if err != nil {
 			return err
 		}
+		modifiedKeys = append(modifiedKeys, modKey)
 		modifiedPairs[modKey] = modVal
 	}
 
+	h.keys = modifiedKeys
 	h.pairs = modifiedPairs
 
 	return nil
 }
 
 func (c *CallExpression) modify(modify ModifierFunc) error {
-	c.function, _ = modifyIntoType[Expression](c.function, modify)
+	function, err := modifyIntoType[Expression](c.function, modify)
+	if err != nil {
+		return err
+	}
+	c.function = function
 
 	for i, arg := range c.arguments {
 		argRes, err := modifyIntoType[Expression](arg, modify)
@@ -229,3 +249,48 @@ func (m *MacroLiteral) modify(modify ModifierFunc) error {
 	// I don't know.
 	return nil
 }
+
+func (w *WhileStatement) modify(modify ModifierFunc) error {
+	var err error
+	w.condition, err = modifyIntoType[Expression](w.condition, modify)
+	if err != nil {
+		return err
+	}
+	w.body, err = modifyIntoType[*BlockStatement](w.body, modify)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (f *ForStatement) modify(modify ModifierFunc) error {
+	var err error
+	f.iterable, err = modifyIntoType[Expression](f.iterable, modify)
+	if err != nil {
+		return err
+	}
+	f.body, err = modifyIntoType[*BlockStatement](f.body, modify)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (i *ImportExpression) modify(modify ModifierFunc) error { return nil }
+
+func (b *BreakStatement) modify(modify ModifierFunc) error { return nil }
+
+func (c *ContinueStatement) modify(modify ModifierFunc) error { return nil }
+
+func (a *AssignStatement) modify(modify ModifierFunc) error {
+	var err error
+	a.Target, err = modifyIntoType[Expression](a.Target, modify)
+	if err != nil {
+		return err
+	}
+	a.Value, err = modifyIntoType[Expression](a.Value, modify)
+	if err != nil {
+		return err
+	}
+	return nil
+}
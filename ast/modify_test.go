@@ -1,6 +1,7 @@
 package ast_test
 
 import (
+	"errors"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/parser"
@@ -157,6 +158,18 @@ func TestModify(t *testing.T) {
 	}
 }
 
+func TestModifyIncomplete(t *testing.T) {
+	for _, input := range []string{"let a = 1 +;", "let b = -;", "return 2 *;"} {
+		t.Run(input, func(t *testing.T) {
+			program := parser.New(lexer.New(input)).ParseProgram()
+			_, err := ast.Modify(program, func(node ast.Node) (ast.Node, error) { return node, nil })
+			if !errors.Is(err, ast.ErrIncomplete) {
+				t.Errorf("expected ErrIncomplete. got = %v", err)
+			}
+		})
+	}
+}
+
 func checkParserErrors(t *testing.T, p *parser.Parser) {
 	if len(p.Errors()) > 0 {
 		t.Fatalf("parsing resulted in unexpected errors: %#v", p.Errors())
@@ -20,6 +20,10 @@ func (s *StringLiteral) TokenLiteral() string {
 	return s.Token.Literal
 }
 
+func (s *StringLiteral) Span() token.Span {
+	return s.Token.Span
+}
+
 func (s *StringLiteral) String() string {
 	return fmt.Sprintf(`"%s"`, s.Value)
 }
@@ -0,0 +1,38 @@
+package ast
+
+import (
+	"fmt"
+	"monkey/token"
+)
+
+type WhileStatement struct {
+	token     token.Token // the "while" token
+	condition Expression
+	body      *BlockStatement
+}
+
+func NewWhileStatement(t token.Token, condition Expression, body *BlockStatement) *WhileStatement {
+	return &WhileStatement{t, condition, body}
+}
+
+func (w *WhileStatement) Condition() Expression {
+	return w.condition
+}
+
+func (w *WhileStatement) Body() *BlockStatement {
+	return w.body
+}
+
+func (*WhileStatement) statementNode() {}
+
+func (w *WhileStatement) TokenLiteral() string {
+	return w.token.Literal
+}
+
+func (w *WhileStatement) Span() token.Span {
+	return w.token.Span
+}
+
+func (w *WhileStatement) String() string {
+	return fmt.Sprintf("(while %s %s)", w.condition.String(), w.body.String())
+}
@@ -15,6 +15,7 @@ const (
 	OpEqual
 	OpNotEqual
 	OpGreaterThan
+	OpGreaterThanOrEqual
 	OpMinus
 	OpBang
 	OpJumpNotTruthy
@@ -42,39 +43,71 @@ const (
 	OpGetFree
 
 	OpCurrentClosure
+
+	// OpIterator replaces the collection on top of the stack with an iterator over it.
+	OpIterator
+	// OpIterNext pops an iterator and pushes its next element, or jumps to its
+	// operand if there are none left.
+	OpIterNext
+
+	// OpSetFree assigns to a free variable of the current closure.
+	OpSetFree
+	// OpSetIndex pops a value, an index and a collection, and assigns the value
+	// at the index of the collection.
+	OpSetIndex
+	// OpDup2 duplicates the top two elements of the stack.
+	OpDup2
+	// OpGetLocalCell and OpGetFreeCell push the cell of a variable rather than
+	// its value, they're how closures capture variables.
+	OpGetLocalCell
+	OpGetFreeCell
+
+	// OpImport pushes the exports of a module, running its body (a function
+	// constant, the first operand) the first time. The exports are then kept
+	// in the global slot of the second operand.
+	OpImport
 )
 
 var definitions = map[Opcode]*Definition{
-	OpConstant:       {"OpConstant", []int{2}},
-	OpAdd:            {"OpAdd", []int{}},
-	OpSub:            {"OpSub", []int{}},
-	OpMul:            {"OpMul", []int{}},
-	OpDiv:            {"OpDiv", []int{}},
-	OpPop:            {"OpPop", []int{}},
-	OpNull:           {"OpNull", []int{}},
-	OpTrue:           {"OpTrue", []int{}},
-	OpFalse:          {"OpFalse", []int{}},
-	OpEqual:          {"OpEqual", []int{}},
-	OpNotEqual:       {"OpNotEqual", []int{}},
-	OpGreaterThan:    {"OpGreaterThan", []int{}},
-	OpMinus:          {"OpMinus", []int{}},
-	OpBang:           {"OpBang", []int{}},
-	OpJumpNotTruthy:  {"OpJumpNotTruthy", []int{2}},
-	OpJump:           {"OpJump", []int{2}},
-	OpGetGlobal:      {"OpGetGlobal", []int{2}},
-	OpSetGlobal:      {"OpSetGlobal", []int{2}},
-	OpArray:          {"OpArray", []int{2}}, // operand here is 2 bytes wide, which gives us 65535 possible number of elements
-	OpHash:           {"OpHash", []int{2}},  // operand here is 2 bytes wide, which gives us 65535 possible number of elements
-	OpIndex:          {"OpIndex", []int{}},
-	OpCall:           {"OpCall", []int{1}},
-	OpReturnValue:    {"OpReturnValue", []int{}},
-	OpReturn:         {"OpReturn", []int{}},
-	OpGetLocal:       {"OpGetLocal", []int{1}},
-	OpSetLocal:       {"OpSetLocal", []int{1}},
-	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
-	OpClosure:        {"OpClosure", []int{2, 1}},
-	OpGetFree:        {"OpGetFree", []int{1}},
-	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpConstant:           {"OpConstant", []int{2}},
+	OpAdd:                {"OpAdd", []int{}},
+	OpSub:                {"OpSub", []int{}},
+	OpMul:                {"OpMul", []int{}},
+	OpDiv:                {"OpDiv", []int{}},
+	OpPop:                {"OpPop", []int{}},
+	OpNull:               {"OpNull", []int{}},
+	OpTrue:               {"OpTrue", []int{}},
+	OpFalse:              {"OpFalse", []int{}},
+	OpEqual:              {"OpEqual", []int{}},
+	OpNotEqual:           {"OpNotEqual", []int{}},
+	OpGreaterThan:        {"OpGreaterThan", []int{}},
+	OpGreaterThanOrEqual: {"OpGreaterThanOrEqual", []int{}},
+	OpMinus:              {"OpMinus", []int{}},
+	OpBang:               {"OpBang", []int{}},
+	OpJumpNotTruthy:      {"OpJumpNotTruthy", []int{2}},
+	OpJump:               {"OpJump", []int{2}},
+	OpGetGlobal:          {"OpGetGlobal", []int{2}},
+	OpSetGlobal:          {"OpSetGlobal", []int{2}},
+	OpArray:              {"OpArray", []int{2}}, // operand here is 2 bytes wide, which gives us 65535 possible number of elements
+	OpHash:               {"OpHash", []int{2}},  // operand here is 2 bytes wide, which gives us 65535 possible number of elements
+	OpIndex:              {"OpIndex", []int{}},
+	OpCall:               {"OpCall", []int{1}},
+	OpReturnValue:        {"OpReturnValue", []int{}},
+	OpReturn:             {"OpReturn", []int{}},
+	OpGetLocal:           {"OpGetLocal", []int{1}},
+	OpSetLocal:           {"OpSetLocal", []int{1}},
+	OpGetBuiltin:         {"OpGetBuiltin", []int{1}},
+	OpClosure:            {"OpClosure", []int{2, 1}},
+	OpGetFree:            {"OpGetFree", []int{1}},
+	OpCurrentClosure:     {"OpCurrentClosure", []int{}},
+	OpIterator:           {"OpIterator", []int{}},
+	OpIterNext:           {"OpIterNext", []int{2}},
+	OpSetFree:            {"OpSetFree", []int{1}},
+	OpSetIndex:           {"OpSetIndex", []int{}},
+	OpDup2:               {"OpDup2", []int{}},
+	OpGetLocalCell:       {"OpGetLocalCell", []int{1}},
+	OpGetFreeCell:        {"OpGetFreeCell", []int{1}},
+	OpImport:             {"OpImport", []int{2, 2}},
 }
 
 type Definition struct {
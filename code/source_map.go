@@ -0,0 +1,46 @@
+package code
+
+import (
+	"monkey/token"
+	"sort"
+)
+
+// SourceMapping marks that the instructions starting at Offset were emitted
+// for the source found at Span.
+type SourceMapping struct {
+	Offset int
+	Span   token.Span
+}
+
+// SourceMap links the offsets of an Instructions stream back to the source
+// that produced them.
+//
+// Mappings are kept ordered by their offset, a mapping covers all of the
+// instructions up until the offset of the next one.
+type SourceMap []SourceMapping
+
+// Add records that the instruction at offset was emitted for span.
+//
+// Offsets must be added in increasing order, which is naturally the case when
+// instructions are only ever appended.
+func (s SourceMap) Add(offset int, span token.Span) SourceMap {
+	return append(s, SourceMapping{Offset: offset, Span: span})
+}
+
+// Truncate drops every mapping of instructions at or after offset. Use it
+// whenever instructions are removed from the end of the stream.
+func (s SourceMap) Truncate(offset int) SourceMap {
+	i := sort.Search(len(s), func(i int) bool { return s[i].Offset >= offset })
+	return s[:i]
+}
+
+// Lookup finds the span of the source the instruction at offset was emitted for.
+func (s SourceMap) Lookup(offset int) (token.Span, bool) {
+	i := sort.Search(len(s), func(i int) bool { return s[i].Offset > offset })
+	if i == 0 {
+		return token.Span{}, false //nolint:exhaustruct
+	}
+
+	span := s[i-1].Span
+	return span, span.IsValid()
+}
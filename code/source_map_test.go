@@ -0,0 +1,65 @@
+package code_test
+
+import (
+	"monkey/code"
+	"monkey/token"
+	"testing"
+)
+
+func spanAtLine(line int) token.Span {
+	return token.Span{
+		File:  "script.monkey",
+		Start: token.Position{Offset: 0, Line: line, Column: 1},
+		End:   token.Position{Offset: 0, Line: line, Column: 2},
+	}
+}
+
+func TestSourceMapLookup(t *testing.T) {
+	sourceMap := code.SourceMap{}.
+		Add(0, spanAtLine(1)).
+		Add(3, spanAtLine(2)).
+		Add(4, spanAtLine(5))
+
+	tests := []struct {
+		offset       int
+		expectedLine int
+	}{
+		{0, 1},
+		{2, 1},
+		{3, 2},
+		{4, 5},
+		{100, 5},
+	}
+
+	for _, tt := range tests {
+		span, ok := sourceMap.Lookup(tt.offset)
+		if !ok {
+			t.Errorf("offset %d - expected a span, got none", tt.offset)
+			continue
+		}
+		if span.Start.Line != tt.expectedLine {
+			t.Errorf("offset %d - wrong line. want = %d, got = %d", tt.offset, tt.expectedLine, span.Start.Line)
+		}
+	}
+
+	if _, ok := (code.SourceMap{}).Lookup(0); ok {
+		t.Errorf("an empty source map should not resolve any offset")
+	}
+}
+
+func TestSourceMapTruncate(t *testing.T) {
+	sourceMap := code.SourceMap{}.
+		Add(0, spanAtLine(1)).
+		Add(3, spanAtLine(2)).
+		Add(4, spanAtLine(5))
+
+	truncated := sourceMap.Truncate(3)
+	if len(truncated) != 1 {
+		t.Fatalf("wrong number of mappings after truncate. want = %d, got = %d", 1, len(truncated))
+	}
+
+	span, _ := truncated.Lookup(4)
+	if span.Start.Line != 1 {
+		t.Errorf("truncated mapping still resolved. want line = %d, got = %d", 1, span.Start.Line)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/fileexec"
+	"monkey/lsp"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// subcommands are run as `monkey <name> [flags] [args]`. Without one, the
+// top level flags run a script or enter the REPL.
+var subcommands = map[string]func(args []string){
+	"build":  buildCommand,
+	"run":    runCommand,
+	"disasm": disasmCommand,
+	"debug":  debugCommand,
+	"lsp":    lspCommand,
+	"fmt":    fmtCommand,
+	"lint":   lintCommand,
+}
+
+// buildCommand compiles a script into a bytecode (.mkc) file:
+//
+//	monkey build [-o out.mkc] script.monkey
+func buildCommand(args []string) {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	outFlag := flags.String("o", "", "Path of the bytecode file to write. Defaults to the script's path with a .mkc extension.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey build [-o out.mkc] script.monkey\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	script := flags.Arg(0)
+	out := *outFlag
+	if out == "" {
+		out = strings.TrimSuffix(script, filepath.Ext(script)) + ".mkc"
+	}
+
+	fileexec.BuildFile(script, out)
+}
+
+// runCommand runs a bytecode (.mkc) file, or a script compiling it first:
+//
+//	monkey run [-debug] out.mkc
+func runCommand(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	debugFlag := flags.Bool("debug", false, "Dump the globals, the stack and the instructions of the VM on runtime errors.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey run [-debug] file.mkc\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	fileexec.RunBytecodeFile(flags.Arg(0), *debugFlag)
+}
+
+// disasmCommand prints the disassembled bytecode of a script or of a bytecode
+// (.mkc) file:
+//
+//	monkey disasm -file script.monkey
+func disasmCommand(args []string) {
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	fileFlag := flags.String("file", "", "Path to the script or bytecode file to disassemble.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey disasm -file script.monkey\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	file := *fileFlag
+	if file == "" && flags.NArg() == 1 {
+		file = flags.Arg(0)
+	}
+	if file == "" || flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	fileexec.DisassembleFile(os.Stdout, file)
+}
+
+// debugCommand runs a script in the interactive debugger, or serves the Debug
+// Adapter Protocol over stdio for an editor to launch scripts with:
+//
+//	monkey debug -file script.monkey
+//	monkey debug -dap
+func debugCommand(args []string) {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	fileFlag := flags.String("file", "", "Path to the script to debug.")
+	dapFlag := flags.Bool("dap", false, "Speak the Debug Adapter Protocol over stdio, the script being given by the launch request.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey debug -file script.monkey | monkey debug -dap\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *dapFlag {
+		fileexec.ServeDAP()
+		return
+	}
+
+	file := *fileFlag
+	if file == "" && flags.NArg() == 1 {
+		file = flags.Arg(0)
+	}
+	if file == "" || flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	fileexec.DebugFile(file)
+}
+
+// lspCommand runs the language server, speaking the Language Server Protocol
+// over stdio:
+//
+//	monkey lsp
+func lspCommand(args []string) {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey lsp\n")
+	}
+	flags.Parse(args)
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintf(os.Stderr, "language server failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// fmtCommand formats scripts in the canonical style, printing them or writing
+// them back to their files:
+//
+//	monkey fmt [-w | -check] [script.monkey ...]
+func fmtCommand(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	writeFlag := flags.Bool("w", false, "Write the formatted scripts back to their files instead of printing them.")
+	checkFlag := flags.Bool("check", false, "List the scripts that aren't formatted, failing if there are any, instead of printing them.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey fmt [-w | -check] [script.monkey ...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *writeFlag && (*checkFlag || flags.NArg() == 0) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	fileexec.FormatFiles(flags.Args(), *writeFlag, *checkFlag)
+}
+
+// lintCommand reports likely mistakes in scripts, see the lint package for
+// which:
+//
+//	monkey lint [-json] script.monkey ...
+func lintCommand(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	jsonFlag := flags.Bool("json", false, "Print the diagnostics as a JSON array.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey lint [-json] script.monkey ...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	fileexec.LintFiles(flags.Args(), *jsonFlag)
+}
@@ -3,9 +3,36 @@ package compiler
 import (
 	"monkey/code"
 	"monkey/object"
+	"monkey/token"
 )
 
+// MainFunction stands for the top level Instructions of the bytecode where the
+// index of a function constant is expected, as in Bytecode.Lookup.
+const MainFunction = -1
+
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+
+	// SourceMap maps the top level Instructions back to the source, functions
+	// carry their own in object.CompiledFunction.
+	SourceMap code.SourceMap
+}
+
+// Lookup finds the span of the source the instruction at offset was compiled
+// from. function is the index of the constant of the function the instruction
+// belongs to, or MainFunction for the top level instructions.
+func (b *Bytecode) Lookup(function int, offset int) (token.Span, bool) {
+	if function == MainFunction {
+		return b.SourceMap.Lookup(offset)
+	}
+
+	if function < 0 || function >= len(b.Constants) {
+		return token.Span{}, false //nolint:exhaustruct
+	}
+	fn, ok := b.Constants[function].(*object.CompiledFunction)
+	if !ok {
+		return token.Span{}, false //nolint:exhaustruct
+	}
+	return fn.SourceMap.Lookup(offset)
 }
@@ -0,0 +1,56 @@
+package compiler_test
+
+import (
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+func TestBytecodeLookup(t *testing.T) {
+	input := "let double = fn(x) {\n  x * 2\n};\ndouble(21);"
+
+	p := parser.New(lexer.NewWithFilename("script.monkey", input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parser errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	function := -1
+	for i, constant := range bytecode.Constants {
+		if _, ok := constant.(*object.CompiledFunction); ok {
+			function = i
+		}
+	}
+
+	tests := []struct {
+		function int
+		offset   int
+		expected string
+	}{
+		// OpClosure of the function.
+		{compiler.MainFunction, 0, "script.monkey:1:14"},
+		// OpCall.
+		{compiler.MainFunction, 13, "script.monkey:4:7"},
+		// OpConstant of the argument.
+		{compiler.MainFunction, 10, "script.monkey:4:8"},
+		// OpMul.
+		{function, 5, "script.monkey:2:5"},
+		{function + 1, 0, "<unknown>"},
+		{len(bytecode.Constants), 0, "<unknown>"},
+	}
+
+	for _, tt := range tests {
+		span, _ := bytecode.Lookup(tt.function, tt.offset)
+		if span.String() != tt.expected {
+			t.Errorf("Lookup(%d, %d) wrong. got = %s, want = %s", tt.function, tt.offset, span, tt.expected)
+		}
+	}
+}
@@ -6,6 +6,22 @@ type CompilationScope struct {
 	Instructions    code.Instructions
 	LastInstruction EmittedInstruction
 	PrevInstruction EmittedInstruction
+
+	// SourceMap links the emitted instructions back to the source they came from.
+	SourceMap code.SourceMap
+
+	// Loops are the loops enclosing the code being compiled, innermost last.
+	Loops []LoopContext
+}
+
+// LoopContext is what `break` and `continue` statements need to know about the
+// loop they act on.
+type LoopContext struct {
+	// ContinueTarget is the position a `continue` jumps to.
+	ContinueTarget int
+	// BreakJumps are the positions of the jumps emitted for `break`s. Their
+	// target is only known once the whole loop is compiled.
+	BreakJumps []int
 }
 
 func NewCompilationScope() CompilationScope {
@@ -13,6 +29,8 @@ func NewCompilationScope() CompilationScope {
 		Instructions:    code.Instructions{},
 		LastInstruction: ZeroEmittedInstruction(),
 		PrevInstruction: ZeroEmittedInstruction(),
+		SourceMap:       code.SourceMap{},
+		Loops:           []LoopContext{},
 	}
 }
 
@@ -35,6 +53,7 @@ func (c *CompilationScope) UpdateLastInstruction(op code.Opcode, pos int) {
 
 func (c *CompilationScope) RemoveLastInstruction() {
 	c.Instructions = c.Instructions[:c.LastInstruction.Position]
+	c.SourceMap = c.SourceMap.Truncate(c.LastInstruction.Position)
 	c.LastInstruction = c.PrevInstruction
 }
 
@@ -62,6 +81,29 @@ func (c *CompilationScope) AddInstruction(ins []byte) int {
 	return posNewInstruction
 }
 
+func (c *CompilationScope) EnterLoop(continueTarget int) {
+	c.Loops = append(c.Loops, LoopContext{ContinueTarget: continueTarget, BreakJumps: []int{}})
+}
+
+// LeaveLoop ends the innermost loop, pointing all of its `break` jumps at the
+// current end of the instructions.
+func (c *CompilationScope) LeaveLoop() {
+	loop := c.Loops[len(c.Loops)-1]
+	c.Loops = c.Loops[:len(c.Loops)-1]
+
+	for _, pos := range loop.BreakJumps {
+		c.ChangeOperand(pos, len(c.Instructions))
+	}
+}
+
+// CurrentLoop returns the innermost loop, if there is one.
+func (c *CompilationScope) CurrentLoop() (*LoopContext, bool) {
+	if len(c.Loops) == 0 {
+		return nil, false
+	}
+	return &c.Loops[len(c.Loops)-1], true
+}
+
 func (c *CompilationScope) LastInstructionIs(op code.Opcode) bool {
 	if len(c.Instructions) == 0 {
 		return false
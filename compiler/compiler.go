@@ -1,12 +1,12 @@
 package compiler
 
 import (
-	"cmp"
 	"fmt"
 	"monkey/ast"
 	"monkey/code"
+	"monkey/diagnostic"
 	"monkey/object"
-	"slices"
+	"monkey/token"
 )
 
 type EmittedInstruction struct {
@@ -22,9 +22,56 @@ type Compiler struct {
 	constants []object.Object
 
 	symbolTable *SymbolTable
+	// globals is the symbol table of the program's globals, the one the
+	// compiler started with.
+	globals *SymbolTable
+
+	// modules are the modules compiled so far, nil if imports aren't allowed.
+	modules *Modules
 
 	scopes     []CompilationScope
 	scopeIndex int
+
+	// span is the location of the node currently being compiled, errors are
+	// reported at it and every emitted instruction is mapped back to it.
+	span token.Span
+
+	// references are the identifiers compiled so far, only recorded once
+	// RecordReferences is called.
+	references       []Reference
+	recordReferences bool
+}
+
+// Reference is an identifier of the compiled program, be it where a variable
+// is defined or where it's used, linked to the definition of the variable.
+type Reference struct {
+	Name string
+	Span token.Span
+
+	// Scope is the scope of the variable, as seen from the identifier.
+	Scope SymbolScope
+	// Definition is where the variable was defined, which is Span itself for
+	// definitions. It's invalid for builtins.
+	Definition token.Span
+}
+
+// IsDefinition reports whether the identifier defines the variable.
+func (r Reference) IsDefinition() bool {
+	return r.Definition.IsValid() && r.Span == r.Definition
+}
+
+// flippedComparisons maps the comparison operators that have no opcode of their
+// own, to the opcode that computes them once the operands are swapped.
+var flippedComparisons = map[string]code.Opcode{
+	"<":  code.OpGreaterThan,
+	"<=": code.OpGreaterThanOrEqual,
+}
+
+var arithmeticOpcodes = map[string]code.Opcode{
+	"+": code.OpAdd,
+	"-": code.OpSub,
+	"*": code.OpMul,
+	"/": code.OpDiv,
 }
 
 func New() *Compiler {
@@ -45,6 +92,7 @@ func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
 		constants: constants,
 
 		symbolTable: s,
+		globals:     s,
 
 		scopes:     []CompilationScope{mainScope},
 		scopeIndex: 0,
@@ -56,14 +104,15 @@ func (c *Compiler) scope() *CompilationScope {
 }
 
 func (c *Compiler) Compile(node ast.Node) error {
+	if span := node.Span(); span.IsValid() {
+		outerSpan := c.span
+		c.span = span
+		defer func() { c.span = outerSpan }()
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
-		for _, s := range node.Statements {
-			if err := c.Compile(s); err != nil {
-				return err
-			}
-		}
-		return nil
+		return c.compileStatements(node.Statements)
 
 	case *ast.ExpressionStatement:
 		if err := c.Compile(node.Expression); err != nil {
@@ -73,9 +122,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 		return nil
 
 	case *ast.InfixExpression:
-		// this operator is treated as equivalent to others *just with flipped operands*.
-		// in essence: `1 < 2` is translated into `2 > 1`.
-		if node.Operator == "<" {
+		// these operators are treated as equivalent to others *just with flipped operands*.
+		// in essence: `1 < 2` is translated into `2 > 1`, and `1 <= 2` into `2 >= 1`.
+		if flipped, ok := flippedComparisons[node.Operator]; ok {
 			if err := c.Compile(node.Right); err != nil {
 				return err
 			}
@@ -84,7 +133,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 
-			c.emit(code.OpGreaterThan)
+			c.emit(flipped)
 			return nil
 		}
 
@@ -95,23 +144,22 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
+		if op, ok := arithmeticOpcodes[node.Operator]; ok {
+			c.emit(op)
+			return nil
+		}
+
 		switch node.Operator {
-		case "+":
-			c.emit(code.OpAdd)
-		case "-":
-			c.emit(code.OpSub)
-		case "*":
-			c.emit(code.OpMul)
-		case "/":
-			c.emit(code.OpDiv)
 		case ">":
 			c.emit(code.OpGreaterThan)
+		case ">=":
+			c.emit(code.OpGreaterThanOrEqual)
 		case "==":
 			c.emit(code.OpEqual)
 		case "!=":
 			c.emit(code.OpNotEqual)
 		default:
-			return fmt.Errorf("infix operator %s not supported", node.Operator)
+			return c.errorf(CodeUnsupportedOperator, "infix operator %s not supported", node.Operator)
 		}
 		return nil
 
@@ -128,6 +176,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpConstant, c.addConstant(integer))
 		return nil
 
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+		return nil
+
 	case *ast.StringLiteral:
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
@@ -144,15 +197,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 		return nil
 
 	case *ast.HashLiteral:
-		// Go makes no guarantees regarding the order of the keys in maps,
-		// we need to sort the keys in order to emit consistent bytecode.
+		// The pairs are compiled in source order, which is the order of the
+		// keys of the hash.
 		pairs := node.Pairs()
-		keys := getKeys(pairs)
-		slices.SortFunc(keys, func(a, b ast.Expression) int {
-			return cmp.Compare(a.String(), b.String())
-		})
-
-		for _, key := range keys {
+		for _, key := range node.Keys() {
 			value := pairs[key]
 
 			if err := c.Compile(key); err != nil {
@@ -178,7 +226,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		case "!":
 			c.emit(code.OpBang)
 		default:
-			panic(fmt.Sprintf("prefix operator %s is not supported", node.Operator))
+			return c.errorf(CodeUnsupportedOperator, "prefix operator %s not supported", node.Operator)
 		}
 		return nil
 
@@ -192,10 +240,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err := c.Compile(node.Consequence()); err != nil {
 			return err
 		}
-
-		if c.scope().LastInstructionIs(code.OpPop) {
-			c.scope().RemoveLastInstruction()
-		}
+		c.keepBranchValue()
 
 		// Emit the opcode with a bogus offset
 		jumpInstructionPos := c.emit(code.OpJump, 9999)
@@ -212,10 +257,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			if err := c.Compile(alt); err != nil {
 				return err
 			}
-
-			if c.scope().LastInstructionIs(code.OpPop) {
-				c.scope().RemoveLastInstruction()
-			}
+			c.keepBranchValue()
 		}
 		c.scope().ChangeOperand(
 			jumpInstructionPos,
@@ -225,12 +267,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		return nil
 
 	case *ast.BlockStatement:
-		for _, s := range node.Statements() {
-			if err := c.Compile(s); err != nil {
-				return err
-			}
-		}
-		return nil
+		return c.compileStatements(node.Statements())
 
 	case *ast.Identifier:
 		if node.Value == "null" {
@@ -240,34 +277,97 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable: %s", node.Value)
+			return c.errorf(CodeUndefinedVariable, "undefined variable: %s", node.Value)
 		}
 
+		c.reference(node, symbol)
 		c.loadSymbol(symbol)
 
 		return nil
 
 	case *ast.LetStatement:
-		symbol := c.symbolTable.Define(node.Name.Value)
+		symbol := c.define(node.Name)
 
 		if err := c.Compile(node.Value); err != nil {
 			return err
 		}
 
-		switch symbol.Scope {
+		c.storeSymbol(symbol)
 
-		case GlobalScope:
-			c.emit(code.OpSetGlobal, symbol.Index)
+		return nil
 
-		case LocalScope:
-			c.emit(code.OpSetLocal, symbol.Index)
+	case *ast.AssignStatement:
+		return c.compileAssignStatement(node)
 
-		default:
-			panic(fmt.Sprintf("symbol.Scope is not supported: %+v", symbol))
+	case *ast.WhileStatement:
+		conditionPos := len(c.scope().Instructions)
+		if err := c.Compile(node.Condition()); err != nil {
+			return err
+		}
+
+		// Emit the opcode with a bogus offset
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		c.scope().EnterLoop(conditionPos)
+		if err := c.Compile(node.Body()); err != nil {
+			return err
+		}
+		c.emit(code.OpJump, conditionPos)
+
+		c.scope().ChangeOperand(jumpNotTruthyPos, len(c.scope().Instructions))
+		c.scope().LeaveLoop()
+
+		return nil
+
+	case *ast.ForStatement:
+		if err := c.Compile(node.Iterable()); err != nil {
+			return err
+		}
+
+		// The iterator lives in a hidden variable (no identifier can start
+		// with a "$"), so that the loop's body is free to use the stack.
+		iterator := c.symbolTable.Define(fmt.Sprintf("$iter%d", len(c.scope().Loops)))
+		c.emit(code.OpIterator)
+		c.storeSymbol(iterator)
+
+		loopStart := len(c.scope().Instructions)
+		c.loadSymbol(iterator)
+		// Emit the opcode with a bogus offset
+		iterNextPos := c.emit(code.OpIterNext, 9999)
+
+		variable := c.define(node.Variable())
+		c.storeSymbol(variable)
+
+		c.scope().EnterLoop(loopStart)
+		if err := c.Compile(node.Body()); err != nil {
+			return err
 		}
+		c.emit(code.OpJump, loopStart)
+
+		c.scope().ChangeOperand(iterNextPos, len(c.scope().Instructions))
+		c.scope().LeaveLoop()
 
 		return nil
 
+	case *ast.BreakStatement:
+		loop, ok := c.scope().CurrentLoop()
+		if !ok {
+			return c.errorf(CodeLoopControlOutsideLoop, "break outside of a loop")
+		}
+
+		// Emit the opcode with a bogus offset, fixed once the loop ends.
+		loop.BreakJumps = append(loop.BreakJumps, c.emit(code.OpJump, 9999))
+		return nil
+
+	case *ast.ContinueStatement:
+		loop, ok := c.scope().CurrentLoop()
+		if !ok {
+			return c.errorf(CodeLoopControlOutsideLoop, "continue outside of a loop")
+		}
+
+		c.emit(code.OpJump, loop.ContinueTarget)
+		return nil
+
 	case *ast.IndexExpression:
 		if err := c.Compile(node.Left()); err != nil {
 			return err
@@ -291,7 +391,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		for _, p := range node.Parameters() {
-			c.symbolTable.Define(p.Value)
+			c.define(p)
 		}
 
 		if err := c.Compile(node.Body()); err != nil {
@@ -316,20 +416,30 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefintions
+		localNames := c.symbolTable.LocalNames()
+		sourceMap := c.scope().SourceMap
 		instructions := c.leaveScope()
 
 		// ========== LEAVING FUNCTION SCOPE ==========
 
 		for _, s := range freeSymbols {
-			c.loadSymbol(s)
+			c.captureSymbol(s)
 		}
 
+		name, _ := node.Name()
 		compiledFn := &object.CompiledFunction{
+			Name:         name,
 			Instructions: instructions,
 			NumLocals:    numLocals,
 			// NOTE: This is not the ideal info to bring to the users
 			// Ideally on errors and such we'd name the missing arguments.
 			NumParameters: len(node.Parameters()),
+			SourceMap:     sourceMap,
+			LocalNames:    localNames,
+			FreeNames:     make([]string, len(freeSymbols)),
+		}
+		for i, s := range freeSymbols {
+			compiledFn.FreeNames[i] = s.Name
 		}
 
 		c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
@@ -358,8 +468,50 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpReturnValue)
 		return nil
 
+	case *ast.ImportExpression:
+		return c.compileImport(node)
+
 	default:
-		panic(fmt.Sprintf("don't support node of type %T", node))
+		return c.errorf(CodeUnsupportedNode, "%s can't be compiled", describeNode(node))
+	}
+}
+
+// compileStatements compiles statements in order. While references are
+// recorded, a statement failing doesn't keep the following ones from being
+// compiled, so that the references of the whole program are known: the first
+// error is returned once they all have been.
+func (c *Compiler) compileStatements(statements []ast.Statement) error {
+	var firstErr error
+	for _, s := range statements {
+		scopeIndex, symbolTable := c.scopeIndex, c.symbolTable
+		err := c.Compile(s)
+		if err == nil {
+			continue
+		}
+		if !c.recordReferences {
+			return err
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+		// The statement may have failed within scopes it entered.
+		c.scopes, c.scopeIndex, c.symbolTable = c.scopes[:scopeIndex+1], scopeIndex, symbolTable
+	}
+	return firstErr
+}
+
+// errorf reports an error at the location of the node being compiled.
+func (c *Compiler) errorf(code diagnostic.Code, format string, args ...any) error {
+	return diagnostic.Errorf(code, c.span, format, args...)
+}
+
+func describeNode(node ast.Node) string {
+	switch node.(type) {
+	case *ast.MacroLiteral:
+		return "a macro definition outside of the top level"
+	default:
+		return fmt.Sprintf("a node of type %T", node)
 	}
 }
 
@@ -380,20 +532,172 @@ func (c *Compiler) loadSymbol(symbol Symbol) {
 	}
 }
 
+func (c *Compiler) compileAssignStatement(node *ast.AssignStatement) error {
+	op, isCompound := node.BinaryOperator()
+	var opcode code.Opcode
+	if isCompound {
+		var ok bool
+		if opcode, ok = arithmeticOpcodes[op]; !ok {
+			return c.errorf(CodeUnsupportedOperator, "assignment operator %s not supported", node.Operator)
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok {
+			return diagnostic.Errorf(CodeUndefinedVariable, target.Span(), "undefined variable: %s", target.Value)
+		}
+		c.reference(target, symbol)
+
+		if !c.symbolTable.Assignable(symbol) {
+			return diagnostic.Errorf(CodeInvalidAssignment, target.Span(), "cannot assign to %s", target.Value)
+		}
+
+		if isCompound {
+			c.loadSymbol(symbol)
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		if isCompound {
+			c.emit(opcode)
+		}
+
+		c.storeSymbol(symbol)
+		return nil
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left()); err != nil {
+			return err
+		}
+
+		if err := c.Compile(target.Index()); err != nil {
+			return err
+		}
+
+		if isCompound {
+			// Keeping the collection and index around for the OpSetIndex, while
+			// using a copy of them to fetch the current value.
+			c.emit(code.OpDup2)
+			c.emit(code.OpIndex)
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		if isCompound {
+			c.emit(opcode)
+		}
+
+		c.emit(code.OpSetIndex)
+		return nil
+
+	default:
+		return c.errorf(CodeInvalidAssignment, "cannot assign to %s", node.Target.String())
+	}
+}
+
+func (c *Compiler) storeSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, symbol.Index)
+	default:
+		panic(fmt.Sprintf("symbol.Scope is not supported: %+v", symbol))
+	}
+}
+
+// captureSymbol pushes what a closure keeps of the symbol: the cell of local
+// and free variables, so that assignments are shared between the closure and
+// where the variable was defined.
+func (c *Compiler) captureSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case LocalScope:
+		c.emit(code.OpGetLocalCell, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpGetFreeCell, symbol.Index)
+	default:
+		c.loadSymbol(symbol)
+	}
+}
+
+// keepBranchValue makes the branch of an if expression that was just compiled
+// leave its value on the stack: the value of the last expression, or null when
+// it ends with anything else (a let, a loop, or no statements at all).
+func (c *Compiler) keepBranchValue() {
+	if c.scope().LastInstructionIs(code.OpPop) {
+		c.scope().RemoveLastInstruction()
+	} else {
+		c.emit(code.OpNull)
+	}
+}
+
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
-		c.scope().Instructions,
-		c.constants,
+		Instructions: c.scope().Instructions,
+		Constants:    c.constants,
+		SourceMap:    c.scope().SourceMap,
 	}
 }
 
+// RecordReferences makes the compiler record every identifier it compiles,
+// for References to return. Compiling then goes on past the statements that
+// fail, as far as it can.
+func (c *Compiler) RecordReferences() {
+	c.recordReferences = true
+}
+
+// References returns the identifiers compiled since RecordReferences was
+// called, in the order they were compiled. Identifiers without a location,
+// such as those produced by macros, are left out.
+func (c *Compiler) References() []Reference {
+	return c.references
+}
+
+// define defines the variable named by ident in the current scope.
+func (c *Compiler) define(ident *ast.Identifier) Symbol {
+	symbol := c.symbolTable.DefineAt(ident.Value, ident.Span())
+	c.reference(ident, symbol)
+	return symbol
+}
+
+func (c *Compiler) reference(ident *ast.Identifier, symbol Symbol) {
+	if !c.recordReferences || !ident.Span().IsValid() {
+		return
+	}
+
+	definition, _ := c.symbolTable.DefinitionSpan(ident.Value)
+	c.references = append(c.references, Reference{
+		Name:       ident.Value,
+		Span:       ident.Span(),
+		Scope:      symbol.Scope,
+		Definition: definition,
+	})
+}
+
+// GlobalNames returns the names of the global variables defined so far,
+// indexed by their slot.
+func (c *Compiler) GlobalNames() []string {
+	return c.symbolTable.GlobalNames()
+}
+
 func (c *Compiler) addConstant(obj object.Object) int {
 	c.constants = append(c.constants, obj)
 	return len(c.constants) - 1
 }
 
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
-	return c.scope().Emit(op, operands...)
+	scope := c.scope()
+	pos := scope.Emit(op, operands...)
+	scope.SourceMap = scope.SourceMap.Add(pos, c.span)
+	return pos
 }
 
 func (c *Compiler) enterScope() {
@@ -1,10 +1,12 @@
 package compiler_test
 
 import (
+	"errors"
 	"fmt"
 	"monkey/ast"
 	"monkey/code"
 	"monkey/compiler"
+	"monkey/diagnostic"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
@@ -84,6 +86,32 @@ func TestIntegerArithmetic(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestFloatArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1.5 * 2",
+			expectedConstants: []any{1.5, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMul),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "-2.5e-3",
+			expectedConstants: []any{2.5e-3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestBooleanExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -122,6 +150,26 @@ func TestBooleanExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "1 >= 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterThanOrEqual),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 <= 2",
+			expectedConstants: []any{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterThanOrEqual),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "1 == 1",
 			expectedConstants: []any{1, 1},
@@ -231,6 +279,225 @@ func TestConditionals(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestConditionalsWithoutValue(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `if (true) { let x = 1; }`,
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull), // the consequence has no value of its own
+				// 0011
+				code.Make(code.OpJump, 15),
+				// 0014
+				code.Make(code.OpNull),
+				// 0015
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `while (true) { 1; }`,
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 11),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpPop),
+				// 0008
+				code.Make(code.OpJump, 0),
+			},
+		},
+		{
+			input:             `while (true) { break; continue; }`,
+			expectedConstants: []any{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 13),
+				// 0004
+				code.Make(code.OpJump, 13), // break
+				// 0007
+				code.Make(code.OpJump, 0), // continue
+				// 0010
+				code.Make(code.OpJump, 0),
+			},
+		},
+		{
+			input:             `for (x in [1]) { x; }`,
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpIterator),
+				// 0007
+				code.Make(code.OpSetGlobal, 0), // the hidden iterator
+				// 0010
+				code.Make(code.OpGetGlobal, 0),
+				// 0013
+				code.Make(code.OpIterNext, 26),
+				// 0016
+				code.Make(code.OpSetGlobal, 1), // x
+				// 0019
+				code.Make(code.OpGetGlobal, 1),
+				// 0022
+				code.Make(code.OpPop),
+				// 0023
+				code.Make(code.OpJump, 10),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `let x = 1; x = 2;`,
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			input:             `let x = 1; x -= 2;`,
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSub),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			input:             `let a = [1]; a[0] *= 2;`,
+			expectedConstants: []any{1, 0, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpDup2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpMul),
+				code.Make(code.OpSetIndex),
+			},
+		},
+		{
+			input: `fn() { let n = 0; fn() { n = 1; } }`,
+			expectedConstants: []any{
+				0,
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSetFree, 0),
+					code.Make(code.OpReturn),
+				},
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocalCell, 0),
+					code.Make(code.OpClosure, 2, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestAssignmentErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x = 1;`, "1:1: undefined variable: x"},
+		{`len = 1;`, "1:1: cannot assign to len"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			err := compiler.New().Compile(parse(t, tt.input))
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+
+			if err.Error() != tt.expected {
+				t.Errorf("wrong error. got = %q, want = %q", err.Error(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompilerErrors(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode diagnostic.Code
+		expectedErr  string
+	}{
+		{"let a = 1;\na + b", compiler.CodeUndefinedVariable, "2:5: undefined variable: b"},
+		{
+			"fn() { macro(x) { x } }",
+			compiler.CodeUnsupportedNode,
+			"1:8: a macro definition outside of the top level can't be compiled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			err := compiler.New().Compile(parse(t, tt.input))
+
+			var d diagnostic.Diagnostic
+			if !errors.As(err, &d) {
+				t.Fatalf("expected a diagnostic, got = %v", err)
+			}
+
+			if d.Code != tt.expectedCode {
+				t.Errorf("wrong code. got = %q, want = %q", d.Code, tt.expectedCode)
+			}
+
+			if d.Error() != tt.expectedErr {
+				t.Errorf("wrong error. got = %q, want = %q", d.Error(), tt.expectedErr)
+			}
+		})
+	}
+}
+
 func TestNull(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -789,7 +1056,7 @@ func TestClosures(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 0, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -819,13 +1086,13 @@ func TestClosures(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetFree, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetFreeCell, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 0, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 1, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -872,15 +1139,15 @@ func TestClosures(t *testing.T) {
 				[]code.Instructions{
 					code.Make(code.OpConstant, 2),
 					code.Make(code.OpSetLocal, 0),
-					code.Make(code.OpGetFree, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetFreeCell, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 4, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
 					code.Make(code.OpConstant, 1),
 					code.Make(code.OpSetLocal, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 5, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -901,6 +1168,43 @@ func TestClosures(t *testing.T) {
 	}
 }
 
+func TestReferences(t *testing.T) {
+	input := `let total = 0;
+let add = fn(x) {
+  total = total + x;
+  add
+};
+add(len([1]));`
+
+	comp := compiler.New()
+	comp.RecordReferences()
+	if err := comp.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	// Each reference as "name@line:col -> definition (scope)".
+	expected := []string{
+		"total@1:5 -> 1:5 (GLOBAL)",
+		"add@2:5 -> 2:5 (GLOBAL)",
+		"x@2:14 -> 2:14 (LOCAL)",
+		"total@3:3 -> 1:5 (GLOBAL)",
+		"total@3:11 -> 1:5 (GLOBAL)",
+		"x@3:19 -> 2:14 (LOCAL)",
+		"add@4:3 -> 2:5 (FUNCTION)",
+		"add@6:1 -> 2:5 (GLOBAL)",
+		"len@6:5 -> <unknown> (BUILTIN)",
+	}
+
+	got := []string{}
+	for _, ref := range comp.References() {
+		got = append(got, fmt.Sprintf("%s@%s -> %s (%s)", ref.Name, ref.Span, ref.Definition, ref.Scope))
+	}
+
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong references.\n got = %s\nwant = %s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
 func TestRecursiveFunctions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -1058,6 +1362,12 @@ func testConstants(expected []any, actual []object.Object) error {
 				return fmt.Errorf("constant %d - testIntegerObject failed: %s", i, err)
 			}
 
+		case float64:
+			float, ok := actual[i].(*object.Float)
+			if !ok || float.Value != constant {
+				return fmt.Errorf("constant %d - not a float of value %g: %s", i, constant, actual[i].Inspect())
+			}
+
 		case string:
 			if err := testStringObject(constant, actual[i]); err != nil {
 				return fmt.Errorf("constant %d - testStringObject failed: %s", i, err)
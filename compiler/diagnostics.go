@@ -0,0 +1,13 @@
+package compiler
+
+import "monkey/diagnostic"
+
+// Codes of the diagnostics reported by the compiler.
+const (
+	CodeUnsupportedNode        diagnostic.Code = "C0001"
+	CodeUnsupportedOperator    diagnostic.Code = "C0002"
+	CodeUndefinedVariable      diagnostic.Code = "C0003"
+	CodeInvalidAssignment      diagnostic.Code = "C0004"
+	CodeLoopControlOutsideLoop diagnostic.Code = "C0005"
+	CodeImportUnavailable      diagnostic.Code = "C0006"
+)
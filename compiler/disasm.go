@@ -0,0 +1,240 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"monkey/code"
+	"monkey/object"
+	"sort"
+)
+
+// Disassemble writes a listing of the bytecode to out: the top level
+// instructions first, then every function they create, followed by the
+// functions those create and so on.
+//
+// Operands are annotated with what they refer to, and jump targets are shown
+// as labels. globals names the global slots of the main program by index, it
+// may be nil when the names are unknown, as for bytecode loaded from a .mkc
+// file. The globals of imported modules are never named.
+func Disassemble(out io.Writer, bytecode *Bytecode, globals []string) {
+	d := &disassembler{
+		out:       out,
+		constants: bytecode.Constants,
+		globals:   globals,
+		numFree:   map[int]int{},
+		listed:    map[int]bool{},
+	}
+
+	fmt.Fprintf(out, "== main ==\n")
+	d.names = globals
+	d.list(bytecode.Instructions)
+	d.listPending()
+
+	// Functions that are never turned into closures can't be reached, but
+	// they're listed anyway so that nothing in the bytecode is hidden.
+	for index, constant := range d.constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok && !d.listed[index] {
+			d.listed[index] = true
+			d.names = nil
+			fmt.Fprintf(out, "\n== fn %d (locals: %d, parameters: %d, unreferenced) ==\n",
+				index, fn.NumLocals, fn.NumParameters)
+			d.list(fn.Instructions)
+			d.listPending()
+		}
+	}
+}
+
+type disassembler struct {
+	out       io.Writer
+	constants []object.Object
+	globals   []string
+
+	// numFree is the number of free variables of each function constant,
+	// which is only known from the OpClosure creating it.
+	numFree map[int]int
+	// pending are the function constants yet to be listed, in the order they
+	// were found.
+	pending []pendingFunction
+	listed  map[int]bool
+
+	// names are the names of the globals of the code being listed, which
+	// are those of the main program or unknown.
+	names []string
+}
+
+type pendingFunction struct {
+	index    int
+	isModule bool
+	names    []string
+}
+
+func (d *disassembler) listPending() {
+	for len(d.pending) > 0 {
+		pending := d.pending[0]
+		d.pending = d.pending[1:]
+
+		fn := d.constants[pending.index].(*object.CompiledFunction)
+		if pending.isModule {
+			fmt.Fprintf(d.out, "\n== module fn %d (globals: %d) ==\n", pending.index, fn.NumGlobals)
+		} else {
+			fmt.Fprintf(d.out, "\n== fn %d (locals: %d, parameters: %d, free: %d) ==\n",
+				pending.index, fn.NumLocals, fn.NumParameters, d.numFree[pending.index])
+		}
+
+		d.names = pending.names
+		d.list(fn.Instructions)
+	}
+}
+
+type instruction struct {
+	offset   int
+	def      *code.Definition
+	op       code.Opcode
+	operands []int
+}
+
+func (d *disassembler) list(ins code.Instructions) {
+	decoded, err := decode(ins)
+
+	labels := jumpLabels(decoded)
+	for _, in := range decoded {
+		if label, ok := labels[in.offset]; ok {
+			fmt.Fprintf(d.out, "%s:\n", label)
+		}
+
+		line := fmt.Sprintf("%04d %s", in.offset, in.def.Name)
+		for _, operand := range in.operands {
+			line += fmt.Sprintf(" %d", operand)
+		}
+
+		if comment := d.annotate(in, labels); comment != "" {
+			line = fmt.Sprintf("%-32s ; %s", line, comment)
+		}
+		fmt.Fprintf(d.out, "%s\n", line)
+	}
+
+	if label, ok := labels[len(ins)]; ok {
+		fmt.Fprintf(d.out, "%s:\n", label)
+	}
+
+	if err != nil {
+		fmt.Fprintf(d.out, "ERROR: %s\n", err)
+	}
+}
+
+func (d *disassembler) annotate(in instruction, labels map[int]string) string {
+	//exhaustive:ignore
+	switch in.op {
+	case code.OpConstant:
+		return d.describeConstant(in.operands[0])
+
+	case code.OpClosure:
+		index, free := in.operands[0], in.operands[1]
+		if fn, ok := d.constant(index).(*object.CompiledFunction); ok && fn != nil {
+			d.numFree[index] = free
+			if !d.listed[index] {
+				d.listed[index] = true
+				d.pending = append(d.pending, pendingFunction{index, false, d.names})
+			}
+		}
+		return fmt.Sprintf("%s, %d free", d.describeConstant(index), free)
+
+	case code.OpImport:
+		index, slot := in.operands[0], in.operands[1]
+		if fn, ok := d.constant(index).(*object.CompiledFunction); ok && fn != nil && !d.listed[index] {
+			d.listed[index] = true
+			d.pending = append(d.pending, pendingFunction{index, true, nil})
+		}
+		if slot < len(d.globals) && d.globals[slot] != "" {
+			return fmt.Sprintf("%s, %s", d.describeConstant(index), d.globals[slot])
+		}
+		return d.describeConstant(index)
+
+	case code.OpGetGlobal, code.OpSetGlobal:
+		if index := in.operands[0]; index < len(d.names) && d.names[index] != "" {
+			return d.names[index]
+		}
+
+	case code.OpGetBuiltin:
+		if index := in.operands[0]; index < len(object.Builtins) {
+			return object.Builtins[index].Name
+		}
+
+	case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext:
+		return "-> " + labels[in.operands[0]]
+	}
+
+	return ""
+}
+
+func (d *disassembler) constant(index int) object.Object {
+	if index >= len(d.constants) {
+		return nil
+	}
+	return d.constants[index]
+}
+
+func (d *disassembler) describeConstant(index int) string {
+	switch constant := d.constant(index).(type) {
+	case nil:
+		return "<missing constant>"
+	case *object.String:
+		return fmt.Sprintf("%q", constant.Value)
+	case *object.CompiledFunction:
+		return fmt.Sprintf("fn %d", index)
+	default:
+		return constant.Inspect()
+	}
+}
+
+// decode splits ins into its instructions, stopping at the first one that
+// can't be decoded.
+func decode(ins code.Instructions) ([]instruction, error) {
+	decoded := []instruction{}
+
+	for offset := 0; offset < len(ins); {
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			return decoded, fmt.Errorf("%04d: %w", offset, err)
+		}
+
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if offset+1+width > len(ins) {
+			return decoded, fmt.Errorf("%04d: %s is truncated", offset, def.Name)
+		}
+
+		operands, read := code.ReadOperands(def, ins[offset+1:])
+		decoded = append(decoded, instruction{offset, def, code.Opcode(ins[offset]), operands})
+		offset += 1 + read
+	}
+
+	return decoded, nil
+}
+
+// jumpLabels names every jump target, L0 being the first one in ins.
+func jumpLabels(decoded []instruction) map[int]string {
+	targets := []int{}
+	seen := map[int]bool{}
+
+	for _, in := range decoded {
+		//exhaustive:ignore
+		switch in.op {
+		case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext:
+			if target := in.operands[0]; !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	sort.Ints(targets)
+
+	labels := make(map[int]string, len(targets))
+	for i, target := range targets {
+		labels[target] = fmt.Sprintf("L%d", i)
+	}
+	return labels
+}
@@ -0,0 +1,82 @@
+package compiler_test
+
+import (
+	"bytes"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/object"
+	"strings"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	input := `let n = 2; let f = fn(a) { fn() { if (a) { "yes" } else { n } } }; f(true)()`
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected := `== main ==
+0000 OpConstant 0                ; 2
+0003 OpSetGlobal 0               ; n
+0006 OpClosure 3 0               ; fn 3, 0 free
+0010 OpSetGlobal 1               ; f
+0013 OpGetGlobal 1               ; f
+0016 OpTrue
+0017 OpCall 1
+0019 OpCall 0
+0021 OpPop
+
+== fn 3 (locals: 1, parameters: 1, free: 0) ==
+0000 OpGetLocalCell 0
+0002 OpClosure 2 1               ; fn 2, 1 free
+0006 OpReturnValue
+
+== fn 2 (locals: 0, parameters: 0, free: 1) ==
+0000 OpGetFree 0
+0002 OpJumpNotTruthy 11          ; -> L0
+0005 OpConstant 1                ; "yes"
+0008 OpJump 14                   ; -> L1
+L0:
+0011 OpGetGlobal 0               ; n
+L1:
+0014 OpReturnValue
+`
+
+	var out bytes.Buffer
+	compiler.Disassemble(&out, comp.Bytecode(), comp.GlobalNames())
+
+	if out.String() != expected {
+		t.Errorf("wrong disassembly.\n want = ```\n%s```\n got = ```\n%s```", expected, out.String())
+	}
+}
+
+func TestDisassembleWithoutNames(t *testing.T) {
+	bytecode := &compiler.Bytecode{ //nolint:exhaustruct
+		Instructions: concatInstructions([]code.Instructions{
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpGetBuiltin, 0),
+			code.Make(code.OpPop),
+			// A truncated OpConstant.
+			{byte(code.OpConstant), 0},
+		}),
+		Constants: []object.Object{
+			&object.CompiledFunction{Instructions: code.Make(code.OpReturn)}, //nolint:exhaustruct
+		},
+	}
+
+	var out bytes.Buffer
+	compiler.Disassemble(&out, bytecode, nil)
+
+	for _, line := range []string{
+		"0000 OpGetGlobal 0\n",
+		"0003 OpGetBuiltin 0              ; len\n",
+		"ERROR: 0006: OpConstant is truncated\n",
+		"== fn 0 (locals: 0, parameters: 0, unreferenced) ==\n0000 OpReturn\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("disassembly is missing %q. got = ```\n%s```", line, out.String())
+		}
+	}
+}
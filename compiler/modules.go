@@ -0,0 +1,113 @@
+package compiler
+
+import (
+	"monkey/ast"
+	"monkey/code"
+	"monkey/module"
+	"monkey/object"
+)
+
+// Modules are the modules imported by a program. Compilers that share them,
+// and the symbol table of the globals (as the ones of a REPL session do),
+// compile and run each module only once.
+type Modules struct {
+	loader   *module.Loader
+	compiled map[string]compiledModule
+}
+
+type compiledModule struct {
+	// constant is the index of the module's body, a function constant.
+	constant int
+	// slot is the global that keeps the module's exports once it ran.
+	slot int
+}
+
+func NewModules(loader *module.Loader) *Modules {
+	return &Modules{
+		loader:   loader,
+		compiled: map[string]compiledModule{},
+	}
+}
+
+// SetModules allows the compiled code to import modules.
+func (c *Compiler) SetModules(modules *Modules) {
+	c.modules = modules
+}
+
+func (c *Compiler) compileImport(node *ast.ImportExpression) error {
+	if c.modules == nil {
+		return c.errorf(CodeImportUnavailable, "cannot import %q: modules are not available here", node.Path())
+	}
+
+	mod, err := c.modules.loader.Load(node.Path(), node.Span())
+	if err != nil {
+		return err
+	}
+
+	compiled, ok := c.modules.compiled[mod.Path]
+	if !ok {
+		if compiled, err = c.compileModule(mod); err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpImport, compiled.constant, compiled.slot)
+	return nil
+}
+
+// compileModule compiles the body of a module into a function, which returns
+// a hash of the module's exports. The module has globals of its own, so it's
+// compiled with a symbol table of its own, sharing the builtins of the program.
+func (c *Compiler) compileModule(mod *module.Module) (compiledModule, error) {
+	symbolTable := NewSymbolTable()
+	for _, builtin := range c.globals.builtins() {
+		symbolTable.DefineBuiltin(builtin.Index, builtin.Name)
+	}
+
+	outerSymbolTable := c.symbolTable
+	c.symbolTable = symbolTable
+	c.scopes = append(c.scopes, NewCompilationScope())
+	c.scopeIndex++
+
+	leave := func() (code.Instructions, code.SourceMap) {
+		scope := c.scope()
+		c.scopes = c.scopes[:len(c.scopes)-1]
+		c.scopeIndex--
+		c.symbolTable = outerSymbolTable
+		return scope.Instructions, scope.SourceMap
+	}
+
+	if err := c.Compile(mod.Program); err != nil {
+		leave()
+		return compiledModule{}, err
+	}
+
+	exported := 0
+	for _, name := range mod.Exports {
+		symbol, ok := symbolTable.Resolve(name)
+		if !ok {
+			continue
+		}
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: name}))
+		c.loadSymbol(symbol)
+		exported++
+	}
+	c.emit(code.OpHash, exported*2)
+	c.emit(code.OpReturnValue)
+
+	instructions, sourceMap := leave()
+
+	body := &object.CompiledFunction{ //nolint:exhaustruct
+		Instructions: instructions,
+		NumGlobals:   symbolTable.numDefintions,
+		SourceMap:    sourceMap,
+	}
+
+	// The slot is named after the module, no identifier can start with a "$".
+	compiled := compiledModule{
+		constant: c.addConstant(body),
+		slot:     c.globals.Define("$module " + mod.Path).Index,
+	}
+	c.modules.compiled[mod.Path] = compiled
+	return compiled, nil
+}
@@ -0,0 +1,390 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"monkey/code"
+	"monkey/object"
+	"monkey/token"
+)
+
+// The layout of serialized bytecode (.mkc files) is:
+//
+//	magic         "MKC\x00"
+//	version       uint16
+//	builtins      uvarint, the number of builtins the bytecode may refer to
+//	files         uvarint count, each the uvarint length and the path of a
+//	              source file the source maps refer to
+//	instructions  uvarint length, followed by the raw instructions
+//	source map    the source map of the instructions
+//	constants     uvarint count, each a tag byte followed by its value
+//	checksum      uint32 CRC-32 (IEEE) of everything before it
+//
+// A source map is its uvarint number of mappings, each made of the uvarint
+// distance of its offset from the previous one's, and of the uvarint index of
+// its file plus one. A zero index stands for a mapping without a location,
+// any other is followed by the offset, line and column of the start and of
+// the end of the span, as uvarints.
+//
+// Fixed size integers are big endian.
+const (
+	BytecodeMagic = "MKC\x00"
+
+	// BytecodeVersion must be bumped whenever the format, or the meaning of
+	// the opcodes, changes.
+	BytecodeVersion uint16 = 4
+)
+
+// Tags of the serialized constants.
+const (
+	constantInteger          byte = 'i'
+	constantFloat            byte = 'f'
+	constantString           byte = 's'
+	constantCompiledFunction byte = 'F'
+)
+
+var ErrNotBytecode = errors.New("not monkey bytecode")
+
+// IsBytecode reports whether data starts like serialized bytecode, without
+// checking that it actually is valid.
+func IsBytecode(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(BytecodeMagic))
+}
+
+// MarshalBinary serializes the bytecode, so it can be run later without
+// compiling the source again.
+func (b *Bytecode) MarshalBinary() ([]byte, error) {
+	// The files are only known once the source maps are written, the body
+	// is written first for them to be collected.
+	body := &encoder{fileIndex: map[string]int{}}
+	body.writeBytes(b.Instructions)
+	body.writeSourceMap(b.SourceMap)
+
+	body.writeUvarint(uint64(len(b.Constants)))
+	for i, constant := range b.Constants {
+		if err := body.writeConstant(constant); err != nil {
+			return nil, fmt.Errorf("constant %d: %w", i, err)
+		}
+	}
+
+	e := &encoder{}
+	e.buf.WriteString(BytecodeMagic)
+	e.buf.Write(binary.BigEndian.AppendUint16(nil, BytecodeVersion))
+	e.writeUvarint(uint64(len(object.Builtins)))
+
+	e.writeUvarint(uint64(len(body.files)))
+	for _, file := range body.files {
+		e.writeBytes([]byte(file))
+	}
+	e.buf.Write(body.buf.Bytes())
+
+	e.buf.Write(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(e.buf.Bytes())))
+	return e.buf.Bytes(), nil
+}
+
+// UnmarshalBinary deserializes bytecode serialized by MarshalBinary, checking
+// that it's intact and that this build of monkey is able to run it.
+func (b *Bytecode) UnmarshalBinary(data []byte) error {
+	if !IsBytecode(data) {
+		return ErrNotBytecode
+	}
+
+	if len(data) < len(BytecodeMagic)+2+4 {
+		return errors.New("bytecode is truncated")
+	}
+
+	payload, checksum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return errors.New("bytecode is corrupted: checksum mismatch")
+	}
+
+	d := &decoder{data: payload[len(BytecodeMagic):]}
+
+	if version := d.readUint16(); version != BytecodeVersion {
+		return fmt.Errorf("unsupported bytecode version %d, this build of monkey runs version %d", version, BytecodeVersion)
+	}
+
+	// Builtins are only ever appended, so bytecode compiled against fewer of
+	// them still refers to the right ones.
+	if builtins := d.readUvarint(); d.err == nil && builtins > uint64(len(object.Builtins)) {
+		return fmt.Errorf("bytecode refers to %d builtins, this build of monkey has only %d", builtins, len(object.Builtins))
+	}
+
+	numFiles := d.readUvarint()
+	for i := uint64(0); i < numFiles && d.err == nil; i++ {
+		d.files = append(d.files, string(d.readBytes()))
+	}
+
+	instructions := d.readBytes()
+	sourceMap := d.readSourceMap()
+
+	count := d.readUvarint()
+	constants := []object.Object{}
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		constant := d.readConstant()
+		if d.err != nil {
+			return fmt.Errorf("constant %d: %w", i, d.err)
+		}
+		constants = append(constants, constant)
+	}
+
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) != 0 {
+		return fmt.Errorf("bytecode has %d unexpected trailing bytes", len(d.data))
+	}
+
+	b.Instructions = instructions
+	b.Constants = constants
+	b.SourceMap = sourceMap
+	return nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+
+	// files are the source files the source maps written so far refer to,
+	// fileIndex has the index of each.
+	files     []string
+	fileIndex map[string]int
+}
+
+func (e *encoder) writeUvarint(v uint64) {
+	e.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (e *encoder) writeBytes(b []byte) {
+	e.writeUvarint(uint64(len(b)))
+	e.buf.Write(b)
+}
+
+func (e *encoder) writeConstant(constant object.Object) error {
+	switch constant := constant.(type) {
+	case *object.Integer:
+		e.buf.WriteByte(constantInteger)
+		e.buf.Write(binary.AppendVarint(nil, constant.Value))
+
+	case *object.Float:
+		e.buf.WriteByte(constantFloat)
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(constant.Value)))
+
+	case *object.String:
+		e.buf.WriteByte(constantString)
+		e.writeBytes([]byte(constant.Value))
+
+	case *object.CompiledFunction:
+		e.buf.WriteByte(constantCompiledFunction)
+		e.writeBytes([]byte(constant.Name))
+		e.writeBytes(constant.Instructions)
+		e.writeUvarint(uint64(constant.NumLocals))
+		e.writeUvarint(uint64(constant.NumParameters))
+		e.writeUvarint(uint64(constant.NumGlobals))
+		e.writeSourceMap(constant.SourceMap)
+
+	default:
+		return fmt.Errorf("constants of type %s cannot be serialized", constant.Type())
+	}
+
+	return nil
+}
+
+func (e *encoder) writeSourceMap(sourceMap code.SourceMap) {
+	e.writeUvarint(uint64(len(sourceMap)))
+
+	previous := 0
+	for _, mapping := range sourceMap {
+		e.writeUvarint(uint64(mapping.Offset - previous))
+		previous = mapping.Offset
+
+		span := mapping.Span
+		if !span.IsValid() {
+			e.writeUvarint(0)
+			continue
+		}
+
+		index, ok := e.fileIndex[span.File]
+		if !ok {
+			index = len(e.files)
+			e.files = append(e.files, span.File)
+			e.fileIndex[span.File] = index
+		}
+		e.writeUvarint(uint64(index + 1))
+
+		for _, v := range []int{
+			span.Start.Offset, span.Start.Line, span.Start.Column,
+			span.End.Offset, span.End.Line, span.End.Column,
+		} {
+			e.writeUvarint(uint64(v))
+		}
+	}
+}
+
+// decoder reads the serialized bytecode. Once a read fails, err is set and
+// every read that follows returns a zero value.
+type decoder struct {
+	data []byte
+	err  error
+
+	// files are the source files the source maps refer to.
+	files []string
+}
+
+func (d *decoder) fail(format string, args ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (d *decoder) take(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.data)) {
+		d.fail("bytecode is truncated")
+		return nil
+	}
+
+	taken := d.data[:n]
+	d.data = d.data[n:]
+	return taken
+}
+
+func (d *decoder) readByte() byte {
+	b := d.take(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) readUint16() uint16 {
+	b := d.take(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (d *decoder) readUint64() uint64 {
+	b := d.take(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (d *decoder) readUvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(d.data)
+	if n == 0 {
+		d.fail("bytecode is truncated")
+		return 0
+	}
+	if n < 0 {
+		d.fail("bytecode has an invalid varint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) readVarint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(d.data)
+	if n == 0 {
+		d.fail("bytecode is truncated")
+		return 0
+	}
+	if n < 0 {
+		d.fail("bytecode has an invalid varint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) readBytes() []byte {
+	b := d.take(d.readUvarint())
+	if b == nil {
+		return nil
+	}
+	return bytes.Clone(b)
+}
+
+func (d *decoder) readInt() int {
+	v := d.readUvarint()
+	if v > math.MaxInt32 {
+		d.fail("bytecode has an out of range value %d", v)
+		return 0
+	}
+	return int(v)
+}
+
+func (d *decoder) readConstant() object.Object {
+	switch tag := d.readByte(); tag {
+	case constantInteger:
+		return &object.Integer{Value: d.readVarint()}
+
+	case constantFloat:
+		return &object.Float{Value: math.Float64frombits(d.readUint64())}
+
+	case constantString:
+		return &object.String{Value: string(d.readBytes())}
+
+	case constantCompiledFunction:
+		return &object.CompiledFunction{ //nolint:exhaustruct
+			Name:          string(d.readBytes()),
+			Instructions:  code.Instructions(d.readBytes()),
+			NumLocals:     d.readInt(),
+			NumParameters: d.readInt(),
+			NumGlobals:    d.readInt(),
+			SourceMap:     d.readSourceMap(),
+		}
+
+	default:
+		d.fail("unknown constant tag %q", tag)
+		return nil
+	}
+}
+
+func (d *decoder) readSourceMap() code.SourceMap {
+	count := d.readUvarint()
+	sourceMap := code.SourceMap{}
+
+	offset := 0
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		offset += d.readInt()
+
+		index := d.readUvarint()
+		if index == 0 {
+			sourceMap = sourceMap.Add(offset, token.Span{}) //nolint:exhaustruct
+			continue
+		}
+		if index > uint64(len(d.files)) {
+			d.fail("source map refers to unknown file %d", index-1)
+			return nil
+		}
+
+		span := token.Span{File: d.files[index-1]} //nolint:exhaustruct
+		for _, v := range []*int{
+			&span.Start.Offset, &span.Start.Line, &span.Start.Column,
+			&span.End.Offset, &span.End.Line, &span.End.Column,
+		} {
+			*v = d.readInt()
+		}
+		sourceMap = sourceMap.Add(offset, span)
+	}
+
+	return sourceMap
+}
@@ -0,0 +1,164 @@
+package compiler_test
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/object"
+	"monkey/vm"
+	"reflect"
+	"testing"
+)
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	input := `
+	let rate = 0.25;
+	let greeting = "héllo";
+	let apply = fn(f, x) { f(x) };
+	let scale = fn(factor) { fn(x) { x * factor * rate } };
+	[greeting, apply(scale(-8), 10), len(range(3))]
+	`
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	original := comp.Bytecode()
+
+	data, err := original.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %s", err)
+	}
+
+	if !compiler.IsBytecode(data) {
+		t.Fatalf("serialized bytecode is not recognized as such")
+	}
+
+	decoded := &compiler.Bytecode{} //nolint:exhaustruct
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %s", err)
+	}
+
+	if err := testInstructions([]code.Instructions{original.Instructions}, decoded.Instructions); err != nil {
+		t.Fatalf("wrong instructions: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded.SourceMap, original.SourceMap) {
+		t.Errorf("wrong source map. got = %v, want = %v", decoded.SourceMap, original.SourceMap)
+	}
+
+	if len(decoded.Constants) != len(original.Constants) {
+		t.Fatalf("wrong number of constants. got = %d, want = %d", len(decoded.Constants), len(original.Constants))
+	}
+
+	for i, constant := range original.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			if decoded.Constants[i].Inspect() != constant.Inspect() {
+				t.Errorf("constant %d wrong. got = %s, want = %s", i, decoded.Constants[i].Inspect(), constant.Inspect())
+			}
+			continue
+		}
+
+		decodedFn, ok := decoded.Constants[i].(*object.CompiledFunction)
+		if !ok {
+			t.Fatalf("constant %d is not a function. got = %T", i, decoded.Constants[i])
+		}
+		if decodedFn.NumLocals != fn.NumLocals || decodedFn.NumParameters != fn.NumParameters {
+			t.Errorf("constant %d has wrong locals or parameters. got = %d/%d, want = %d/%d",
+				i, decodedFn.NumLocals, decodedFn.NumParameters, fn.NumLocals, fn.NumParameters)
+		}
+		if !reflect.DeepEqual(decodedFn.SourceMap, fn.SourceMap) {
+			t.Errorf("constant %d has wrong source map. got = %v, want = %v", i, decodedFn.SourceMap, fn.SourceMap)
+		}
+		if decodedFn.Name != fn.Name {
+			t.Errorf("constant %d has wrong name. got = %q, want = %q", i, decodedFn.Name, fn.Name)
+		}
+		if err := testInstructions([]code.Instructions{fn.Instructions}, decodedFn.Instructions); err != nil {
+			t.Errorf("constant %d has wrong instructions: %s", i, err)
+		}
+	}
+
+	machine := vm.New(decoded)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("running the decoded bytecode failed: %s", err)
+	}
+
+	if result := machine.LastPoppedStackElem().Inspect(); result != "[héllo, -20.0, 3]" {
+		t.Errorf("wrong result. got = %s", result)
+	}
+}
+
+func TestBytecodeUnmarshalErrors(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(t, `let f = fn(x) { x + 1 }; f(2)`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	valid, err := comp.Bytecode().MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %s", err)
+	}
+
+	// withChecksum fixes the checksum of tampered data, so the tampering is
+	// what gets reported.
+	withChecksum := func(data []byte) []byte {
+		payload := data[:len(data)-4]
+		return binary.BigEndian.AppendUint32(payload, crc32.ChecksumIEEE(payload))
+	}
+
+	modified := func(modify func(data []byte)) []byte {
+		data := append([]byte{}, valid...)
+		modify(data)
+		return data
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"not bytecode", []byte("let x = 1;"), compiler.ErrNotBytecode.Error()},
+		{"truncated header", []byte(compiler.BytecodeMagic), "bytecode is truncated"},
+		{
+			"corrupted",
+			modified(func(data []byte) { data[len(data)-6] ^= 0xff }),
+			"bytecode is corrupted: checksum mismatch",
+		},
+		{
+			"other version",
+			withChecksum(modified(func(data []byte) { data[5] = 99 })),
+			"unsupported bytecode version 99, this build of monkey runs version 4",
+		},
+		{
+			"truncated body",
+			withChecksum(append(append([]byte{}, valid[:len(valid)-10]...), 0, 0, 0, 0)),
+			"constant 1: bytecode is truncated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&compiler.Bytecode{}).UnmarshalBinary(tt.data) //nolint:exhaustruct
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+
+			if err.Error() != tt.expected {
+				t.Errorf("wrong error. got = %q, want = %q", err.Error(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestBytecodeMarshalUnsupportedConstant(t *testing.T) {
+	bytecode := &compiler.Bytecode{ //nolint:exhaustruct
+		Constants: []object.Object{&object.Integer{Value: 1}, &object.Array{}},
+	}
+
+	_, err := bytecode.MarshalBinary()
+	if err == nil || err.Error() != "constant 1: constants of type ARRAY cannot be serialized" {
+		t.Errorf("wrong error. got = %v", err)
+	}
+}
@@ -1,5 +1,11 @@
 package compiler
 
+import (
+	"maps"
+	"monkey/token"
+	"slices"
+)
+
 type SymbolScope string
 
 const (
@@ -27,6 +33,9 @@ type SymbolTable struct {
 	numDefintions int
 	parent_       *SymbolTable
 	isEnclosed    bool
+
+	// spans are where the names defined with DefineAt were defined.
+	spans map[string]token.Span
 }
 
 func NewSymbolTable() *SymbolTable {
@@ -37,7 +46,7 @@ func newEnclosedSymbolTable(parent *SymbolTable) *SymbolTable {
 	store := map[string]Symbol{}
 	freeSymbols := []Symbol{}
 	numDefinitions := 0
-	return &SymbolTable{store, freeSymbols, numDefinitions, parent, parent != nil}
+	return &SymbolTable{store, freeSymbols, numDefinitions, parent, parent != nil, map[string]token.Span{}}
 }
 
 func (s *SymbolTable) Define(name string) Symbol {
@@ -55,12 +64,46 @@ func (s *SymbolTable) Define(name string) Symbol {
 	return symbol
 }
 
+// DefineAt defines name as Define does, recording that it was defined at span.
+func (s *SymbolTable) DefineAt(name string, span token.Span) Symbol {
+	s.spans[name] = span
+	return s.Define(name)
+}
+
+// DefinitionSpan returns where the variable name resolves to was defined with
+// DefineAt. Builtins have no definition.
+func (s *SymbolTable) DefinitionSpan(name string) (token.Span, bool) {
+	for table := s; table != nil; table = table.parent_ {
+		symbol, ok := table.store[name]
+		// Free variables and the name of the function being compiled are
+		// defined further up.
+		if !ok || symbol.Scope == FreeScope || symbol.Scope == FunctionScope {
+			continue
+		}
+
+		span, ok := table.spans[name]
+		return span, ok && symbol.Scope != BuiltinScope
+	}
+	return token.Span{}, false //nolint:exhaustruct
+}
+
 func (s *SymbolTable) DefineBuiltin(idx int, name string) Symbol {
 	symbol := NewSymbol(name, BuiltinScope, idx)
 	s.store[name] = symbol
 	return symbol
 }
 
+// builtins returns the builtins defined in this very table.
+func (s *SymbolTable) builtins() []Symbol {
+	builtins := []Symbol{}
+	for _, symbol := range s.store {
+		if symbol.Scope == BuiltinScope {
+			builtins = append(builtins, symbol)
+		}
+	}
+	return builtins
+}
+
 func (s *SymbolTable) DefineFree(original Symbol) Symbol {
 	s.FreeSymbols = append(s.FreeSymbols, original)
 	symbol := Symbol{
@@ -98,12 +141,82 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	return free, true
 }
 
+// Copy returns a copy of the table, which the definitions made in s afterwards
+// don't change.
+func (s *SymbolTable) Copy() *SymbolTable {
+	return &SymbolTable{
+		maps.Clone(s.store),
+		slices.Clone(s.FreeSymbols),
+		s.numDefintions,
+		s.parent_,
+		s.isEnclosed,
+		maps.Clone(s.spans),
+	}
+}
+
+// Rollback undoes the definitions of the globals made since snapshot, a Copy
+// of s, for which defined is false: their names resolve as they did in
+// snapshot again. Their slots aren't reused.
+func (s *SymbolTable) Rollback(snapshot *SymbolTable, defined func(symbol Symbol) bool) {
+	for name, symbol := range s.store {
+		before, existed := snapshot.store[name]
+		if symbol.Scope != GlobalScope || (existed && before == symbol) || defined(symbol) {
+			continue
+		}
+
+		delete(s.store, name)
+		delete(s.spans, name)
+		if existed {
+			s.store[name] = before
+			if span, ok := snapshot.spans[name]; ok {
+				s.spans[name] = span
+			}
+		}
+	}
+}
+
+// Assignable reports whether symbol, as resolved in s, can be assigned to:
+// builtins and the name of the function being compiled can't be, even from
+// the functions nested in it.
+func (s *SymbolTable) Assignable(symbol Symbol) bool {
+	switch symbol.Scope {
+	case GlobalScope, LocalScope:
+		return true
+	case FreeScope:
+		return s.parent_.Assignable(s.FreeSymbols[symbol.Index])
+	default:
+		return false
+	}
+}
+
 func (s *SymbolTable) DefineFunctionName(name string) Symbol {
 	symbol := NewSymbol(name, FunctionScope, 0)
 	s.store[name] = symbol
 	return symbol
 }
 
+// GlobalNames returns the names of the global variables, indexed by their
+// slot. Slots of globals that were shadowed by a later definition are empty.
+func (s *SymbolTable) GlobalNames() []string {
+	return s.names(GlobalScope)
+}
+
+// LocalNames returns the names of the local variables of a function, indexed
+// by their slot, as GlobalNames does for globals.
+func (s *SymbolTable) LocalNames() []string {
+	return s.names(LocalScope)
+}
+
+func (s *SymbolTable) names(scope SymbolScope) []string {
+	names := make([]string, s.numDefintions)
+	for name, symbol := range s.store {
+		if symbol.Scope == scope {
+			names[symbol.Index] = name
+		}
+	}
+	return names
+}
+
 func (s *SymbolTable) parent() (*SymbolTable, bool) {
 	return s.parent_, s.parent_ != nil
 }
@@ -1,9 +0,0 @@
-package compiler
-
-func getKeys[K comparable, V any](m map[K]V) []K {
-	keys := []K{}
-	for k := range m {
-		keys = append(keys, k)
-	}
-	return keys
-}
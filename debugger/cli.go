@@ -0,0 +1,215 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"monkey/vm"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const cliPrompt = "(monkey) "
+
+const cliHelp = `Commands:
+  break LINE | FILE:LINE | FUNCTION   set a breakpoint (b)
+  clear LINE | FILE:LINE | FUNCTION   remove a breakpoint
+  continue                            run until the next breakpoint (c)
+  step                                step to the next line, into calls (s)
+  next                                step to the next line, over calls (n)
+  out                                 step out of the current call (o)
+  backtrace                           show the calls in progress (bt)
+  locals                              show the local variables
+  free                                show the variables the function captured
+  globals                             show the global variables
+  print NAME                          show the value of a variable (p)
+  list                                show the source around the current line (l)
+  quit                                stop the program (q)
+`
+
+// cli drives a Debugger from a terminal, reading commands from in whenever
+// the program pauses.
+type cli struct {
+	debugger *Debugger
+	in       *bufio.Scanner
+	out      io.Writer
+
+	// sources are the lines of the files shown, by path.
+	sources map[string][]string
+	// last is the last command, repeated by an empty line.
+	last string
+}
+
+// RunCLI runs the program of d, reading commands from in, one per line,
+// whenever it pauses. d should stop on entry for breakpoints to be set before
+// the program runs. Reaching the end of in stops the program.
+func RunCLI(d *Debugger, in io.Reader, out io.Writer) error {
+	c := &cli{
+		debugger: d,
+		in:       bufio.NewScanner(in),
+		out:      out,
+		sources:  map[string][]string{},
+		last:     "",
+	}
+	d.OnStop = c.stopped
+
+	return d.Run()
+}
+
+func (c *cli) stopped(stop Stop) {
+	location := stop.Location
+	fmt.Fprintf(c.out, "Stopped at %s in %s (%s)\n", location.Span, location.Function, stop.Reason)
+	c.printLines(location.Span.File, location.Span.Start.Line, location.Span.Start.Line)
+
+	for {
+		fmt.Fprint(c.out, cliPrompt)
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			c.debugger.Stop()
+			return
+		}
+
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			line = c.last
+		}
+		c.last = line
+
+		if resume := c.run(line); resume {
+			return
+		}
+	}
+}
+
+// run runs a command, reporting whether the program should resume.
+func (c *cli) run(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	command, args := fields[0], fields[1:]
+
+	switch command {
+	case "break", "b", "clear":
+		if len(args) != 1 {
+			fmt.Fprintf(c.out, "usage: %s LINE | FILE:LINE | FUNCTION\n", command)
+			return false
+		}
+		c.breakpoint(command == "clear", args[0])
+
+	case "continue", "c":
+		c.debugger.Continue()
+		return true
+	case "step", "s":
+		c.debugger.StepIn()
+		return true
+	case "next", "n":
+		c.debugger.StepOver()
+		return true
+	case "out", "o":
+		c.debugger.StepOut()
+		return true
+
+	case "backtrace", "bt":
+		for i, frame := range c.debugger.StackTrace() {
+			fmt.Fprintf(c.out, "#%d %s\n", i, frame)
+		}
+
+	case "locals":
+		c.printVariables(c.debugger.Locals(0))
+	case "free":
+		c.printVariables(c.debugger.Free(0))
+	case "globals":
+		c.printVariables(c.debugger.Globals())
+
+	case "print", "p":
+		if len(args) != 1 {
+			fmt.Fprintf(c.out, "usage: %s NAME\n", command)
+			return false
+		}
+		if value, ok := c.debugger.Lookup(0, args[0]); ok {
+			fmt.Fprintf(c.out, "%s = %s\n", args[0], value.Inspect())
+		} else {
+			fmt.Fprintf(c.out, "no variable named %s\n", args[0])
+		}
+
+	case "list", "l":
+		span := c.debugger.StackTrace()[0].Span
+		c.printLines(span.File, span.Start.Line-5, span.Start.Line+5)
+
+	case "quit", "q":
+		c.debugger.Stop()
+		return true
+
+	case "help", "h":
+		fmt.Fprint(c.out, cliHelp)
+
+	default:
+		fmt.Fprintf(c.out, "unknown command %q, try help\n", command)
+	}
+
+	return false
+}
+
+// breakpoint sets, or clears, the breakpoint described by arg: a line of the
+// main file, a line of another file or the name of a function.
+func (c *cli) breakpoint(clear bool, arg string) {
+	file, line := c.debugger.MainFile(), arg
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		file, line = arg[:i], arg[i+1:]
+	}
+
+	number, err := strconv.Atoi(line)
+	if err != nil {
+		if clear && !c.debugger.ClearFunctionBreakpoint(arg) {
+			fmt.Fprintf(c.out, "no breakpoint on function %s\n", arg)
+		} else if !clear {
+			c.debugger.SetFunctionBreakpoint(arg)
+			fmt.Fprintf(c.out, "Breakpoint on function %s\n", arg)
+		}
+		return
+	}
+
+	switch {
+	case clear && !c.debugger.ClearBreakpoint(file, number):
+		fmt.Fprintf(c.out, "no breakpoint at %s:%d\n", file, number)
+	case clear:
+	case c.debugger.SetBreakpoint(file, number):
+		fmt.Fprintf(c.out, "Breakpoint at %s:%d\n", file, number)
+	default:
+		fmt.Fprintf(c.out, "Breakpoint at %s:%d, which has no code: it will never be hit\n", file, number)
+	}
+}
+
+func (c *cli) printVariables(variables []vm.Variable) {
+	if len(variables) == 0 {
+		fmt.Fprintln(c.out, "(none)")
+	}
+	for _, variable := range variables {
+		fmt.Fprintf(c.out, "%s = %s\n", variable.Name, variable.Value.Inspect())
+	}
+}
+
+// printLines prints the lines from first to last of file, marking the one the
+// program is paused on.
+func (c *cli) printLines(file string, first int, last int) {
+	lines, ok := c.sources[file]
+	if !ok {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return
+		}
+		lines = strings.Split(string(source), "\n")
+		c.sources[file] = lines
+	}
+
+	current := c.debugger.StackTrace()[0].Span.Start.Line
+	for number := max(first, 1); number <= last && number <= len(lines); number++ {
+		marker := " "
+		if number == current {
+			marker = ">"
+		}
+		fmt.Fprintf(c.out, "%s %4d | %s\n", marker, number, lines[number-1])
+	}
+}
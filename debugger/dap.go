@@ -0,0 +1,460 @@
+package debugger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/compiler"
+	"monkey/object"
+	"monkey/vm"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// LaunchFunc compiles the program a debugging session is launched for. If it
+// fails, it writes why to out and returns false.
+type LaunchFunc func(program string, out io.Writer) (*compiler.Bytecode, []string, bool)
+
+// The program runs as a single thread.
+const dapThreadID = 1
+
+// Each call in progress has three scopes of variables, their references are
+// derived from the depth of the call.
+const (
+	scopeLocals = iota
+	scopeFree
+	scopeGlobals
+	numScopes
+)
+
+// DAPServer lets an editor debug a program through the Debug Adapter Protocol
+// (https://microsoft.github.io/debug-adapter-protocol/). The program is given
+// by the "program" argument of the launch request, and paused on its first
+// line if "stopOnEntry" is set.
+type DAPServer struct {
+	launch LaunchFunc
+	in     *bufio.Reader
+
+	// mu guards out and seq, messages being sent from both the goroutine
+	// serving requests and the one running the program.
+	mu  sync.Mutex
+	out io.Writer
+	seq int
+
+	// exec is what the builtins of the program reach outside of it through.
+	exec *object.ExecContext
+
+	debugger *Debugger
+	// resume is sent to once the paused program should resume.
+	resume chan struct{}
+	// paused is set while the program is paused, guarded by pausedMu.
+	pausedMu sync.Mutex
+	paused   bool
+}
+
+func NewDAPServer(in io.Reader, out io.Writer, launch LaunchFunc) *DAPServer {
+	return &DAPServer{
+		launch:   launch,
+		in:       bufio.NewReader(in),
+		mu:       sync.Mutex{},
+		out:      out,
+		seq:      0,
+		exec:     nil,
+		debugger: nil,
+		resume:   make(chan struct{}),
+		pausedMu: sync.Mutex{},
+		paused:   false,
+	}
+}
+
+// SetExecContext sets what the builtins of the programs launched reach outside
+// of them through. Programs mustn't print to the stdout of the server, that of
+// the protocol, but to Output instead.
+func (s *DAPServer) SetExecContext(exec *object.ExecContext) {
+	s.exec = exec
+}
+
+type dapMessage struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type dapResponse struct {
+	Seq        int    `json:"seq"`
+	Type       string `json:"type"`
+	RequestSeq int    `json:"request_seq"`
+	Success    bool   `json:"success"`
+	Command    string `json:"command"`
+	Message    string `json:"message,omitempty"`
+	Body       any    `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int    `json:"seq"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	Body  any    `json:"body,omitempty"`
+}
+
+type dapSource struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type dapBreakpoint struct {
+	Verified bool `json:"verified"`
+	Line     int  `json:"line,omitempty"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// Serve serves requests until the client disconnects, or in is closed.
+func (s *DAPServer) Serve() error {
+	for {
+		request, err := s.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if disconnect := s.handle(request); disconnect {
+			return nil
+		}
+	}
+}
+
+// Output shows text in the debug console of the editor, category being one of
+// "console", "stdout" or "stderr".
+func (s *DAPServer) Output(category string, text string) {
+	s.event("output", map[string]string{"category": category, "output": text})
+}
+
+func (s *DAPServer) read() (*dapMessage, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %w", err)
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(s.in, content); err != nil {
+		return nil, err
+	}
+
+	message := &dapMessage{} //nolint:exhaustruct
+	if err := json.Unmarshal(content, message); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	return message, nil
+}
+
+// send writes a message, with its sequence number set by setSeq.
+func (s *DAPServer) send(setSeq func(seq int) any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	content, err := json.Marshal(setSeq(s.seq))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(content), content)
+}
+
+func (s *DAPServer) respond(request *dapMessage, body any) {
+	s.send(func(seq int) any {
+		return dapResponse{seq, "response", request.Seq, true, request.Command, "", body}
+	})
+}
+
+func (s *DAPServer) fail(request *dapMessage, format string, args ...any) {
+	s.send(func(seq int) any {
+		return dapResponse{seq, "response", request.Seq, false, request.Command, fmt.Sprintf(format, args...), nil}
+	})
+}
+
+func (s *DAPServer) event(event string, body any) {
+	s.send(func(seq int) any {
+		return dapEvent{seq, "event", event, body}
+	})
+}
+
+// handle handles a request, reporting whether the client disconnected.
+func (s *DAPServer) handle(request *dapMessage) bool {
+	switch request.Command {
+	case "initialize":
+		s.respond(request, map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsEvaluateForHovers":        true,
+		})
+		return false
+
+	case "launch":
+		s.handleLaunch(request)
+		return false
+
+	case "disconnect", "terminate":
+		if s.debugger != nil {
+			s.debugger.Stop()
+			s.resumeIfPaused()
+		}
+		s.respond(request, nil)
+		return request.Command == "disconnect"
+	}
+
+	if s.debugger == nil {
+		s.fail(request, "no program was launched")
+		return false
+	}
+
+	switch request.Command {
+	case "setBreakpoints":
+		s.handleSetBreakpoints(request)
+	case "setFunctionBreakpoints":
+		s.handleSetFunctionBreakpoints(request)
+	case "setExceptionBreakpoints":
+		s.respond(request, map[string]any{"breakpoints": []dapBreakpoint{}})
+
+	case "configurationDone":
+		s.respond(request, nil)
+		go s.run()
+
+	case "threads":
+		s.respond(request, map[string]any{
+			"threads": []map[string]any{{"id": dapThreadID, "name": "main"}},
+		})
+
+	case "continue", "next", "stepIn", "stepOut":
+		if !s.isPaused() {
+			s.fail(request, "the program is not paused")
+			return false
+		}
+
+		switch request.Command {
+		case "continue":
+			s.debugger.Continue()
+		case "next":
+			s.debugger.StepOver()
+		case "stepIn":
+			s.debugger.StepIn()
+		case "stepOut":
+			s.debugger.StepOut()
+		}
+		s.respond(request, map[string]bool{"allThreadsContinued": true})
+		s.resumeIfPaused()
+
+	case "pause":
+		s.debugger.Pause()
+		s.respond(request, nil)
+
+	case "stackTrace", "scopes", "variables", "evaluate":
+		if !s.isPaused() {
+			s.fail(request, "the program is not paused")
+			return false
+		}
+		s.handleInspection(request)
+
+	default:
+		s.fail(request, "unsupported request %q", request.Command)
+	}
+
+	return false
+}
+
+func (s *DAPServer) handleLaunch(request *dapMessage) {
+	var args struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(request.Arguments, &args); err != nil || args.Program == "" {
+		s.fail(request, "launch needs the path of the program to debug")
+		return
+	}
+
+	var out bytes.Buffer
+	bytecode, globals, ok := s.launch(args.Program, &out)
+	if !ok {
+		s.Output("stderr", out.String())
+		s.fail(request, "cannot compile %s", args.Program)
+		return
+	}
+
+	s.debugger = New(bytecode, globals, args.StopOnEntry)
+	s.debugger.OnStop = s.stopped
+	s.debugger.SetExecContext(s.exec)
+
+	s.respond(request, nil)
+	// Breakpoints are only taken once the program is known.
+	s.event("initialized", nil)
+}
+
+func (s *DAPServer) handleSetBreakpoints(request *dapMessage) {
+	var args struct {
+		Source      dapSource `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(request.Arguments, &args); err != nil {
+		s.fail(request, "invalid arguments: %s", err)
+		return
+	}
+
+	s.debugger.ClearBreakpoints(args.Source.Path)
+
+	breakpoints := []dapBreakpoint{}
+	for _, bp := range args.Breakpoints {
+		verified := s.debugger.SetBreakpoint(args.Source.Path, bp.Line)
+		breakpoints = append(breakpoints, dapBreakpoint{Verified: verified, Line: bp.Line})
+	}
+	s.respond(request, map[string]any{"breakpoints": breakpoints})
+}
+
+func (s *DAPServer) handleSetFunctionBreakpoints(request *dapMessage) {
+	var args struct {
+		Breakpoints []struct {
+			Name string `json:"name"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(request.Arguments, &args); err != nil {
+		s.fail(request, "invalid arguments: %s", err)
+		return
+	}
+
+	s.debugger.ClearFunctionBreakpoints()
+
+	breakpoints := []dapBreakpoint{}
+	for _, bp := range args.Breakpoints {
+		s.debugger.SetFunctionBreakpoint(bp.Name)
+		breakpoints = append(breakpoints, dapBreakpoint{Verified: true}) //nolint:exhaustruct
+	}
+	s.respond(request, map[string]any{"breakpoints": breakpoints})
+}
+
+// handleInspection handles the requests inspecting the paused program.
+func (s *DAPServer) handleInspection(request *dapMessage) {
+	var args struct {
+		FrameID            int    `json:"frameId"`
+		VariablesReference int    `json:"variablesReference"`
+		Expression         string `json:"expression"`
+	}
+	if err := json.Unmarshal(request.Arguments, &args); err != nil {
+		s.fail(request, "invalid arguments: %s", err)
+		return
+	}
+
+	switch request.Command {
+	case "stackTrace":
+		trace := s.debugger.StackTrace()
+
+		frames := []map[string]any{}
+		for depth, frame := range trace {
+			frames = append(frames, map[string]any{
+				"id":     depth,
+				"name":   frame.Function,
+				"source": dapSource{Name: filepath.Base(frame.Span.File), Path: absolute(frame.Span.File)},
+				"line":   frame.Span.Start.Line,
+				"column": frame.Span.Start.Column,
+			})
+		}
+		s.respond(request, map[string]any{"stackFrames": frames, "totalFrames": len(frames)})
+
+	case "scopes":
+		reference := args.FrameID*numScopes + 1
+		s.respond(request, map[string]any{"scopes": []map[string]any{
+			{"name": "Locals", "variablesReference": reference + scopeLocals, "expensive": false},
+			{"name": "Closure", "variablesReference": reference + scopeFree, "expensive": false},
+			{"name": "Globals", "variablesReference": reference + scopeGlobals, "expensive": false},
+		}})
+
+	case "variables":
+		depth, scope := (args.VariablesReference-1)/numScopes, (args.VariablesReference-1)%numScopes
+
+		var variables []vm.Variable
+		switch scope {
+		case scopeLocals:
+			variables = s.debugger.Locals(depth)
+		case scopeFree:
+			variables = s.debugger.Free(depth)
+		case scopeGlobals:
+			variables = s.debugger.Globals()
+		}
+
+		result := []dapVariable{}
+		for _, variable := range variables {
+			result = append(result, dapVariable{variable.Name, variable.Value.Inspect(), 0})
+		}
+		s.respond(request, map[string]any{"variables": result})
+
+	case "evaluate":
+		value, ok := s.debugger.Lookup(args.FrameID, args.Expression)
+		if !ok {
+			s.fail(request, "no variable named %s", args.Expression)
+			return
+		}
+		s.respond(request, map[string]any{"result": value.Inspect(), "variablesReference": 0})
+	}
+}
+
+// run runs the program, from its own goroutine.
+func (s *DAPServer) run() {
+	exitCode := 0
+	if err := s.debugger.Run(); err != nil && !errors.Is(err, ErrStopped) {
+		s.Output("stderr", err.Error()+"\n")
+		exitCode = 1
+	}
+
+	s.event("exited", map[string]int{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+// stopped is the Debugger's OnStop, it blocks until a request resumes the
+// program.
+func (s *DAPServer) stopped(stop Stop) {
+	s.pausedMu.Lock()
+	s.paused = true
+	s.pausedMu.Unlock()
+
+	s.event("stopped", map[string]any{
+		"reason":            string(stop.Reason),
+		"threadId":          dapThreadID,
+		"allThreadsStopped": true,
+	})
+	<-s.resume
+}
+
+func (s *DAPServer) isPaused() bool {
+	s.pausedMu.Lock()
+	defer s.pausedMu.Unlock()
+
+	return s.paused
+}
+
+func (s *DAPServer) resumeIfPaused() {
+	s.pausedMu.Lock()
+	paused := s.paused
+	s.paused = false
+	s.pausedMu.Unlock()
+
+	if paused {
+		s.resume <- struct{}{}
+	}
+}
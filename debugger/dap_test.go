@@ -0,0 +1,221 @@
+package debugger_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/compiler"
+	"monkey/debugger"
+	"monkey/object"
+	"net/textproto"
+	"strconv"
+	"testing"
+)
+
+// dapClient talks to a DAPServer as an editor would.
+type dapClient struct {
+	t   *testing.T
+	in  io.Writer
+	out *bufio.Reader
+	seq int
+}
+
+type dapMessage struct {
+	Type       string         `json:"type"`
+	Command    string         `json:"command"`
+	Event      string         `json:"event"`
+	RequestSeq int            `json:"request_seq"`
+	Success    bool           `json:"success"`
+	Message    string         `json:"message"`
+	Body       map[string]any `json:"body"`
+}
+
+func (c *dapClient) send(command string, arguments any) int {
+	c.t.Helper()
+
+	c.seq++
+	content, err := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": arguments})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	return c.seq
+}
+
+func (c *dapClient) read() dapMessage {
+	c.t.Helper()
+
+	header, err := textproto.NewReader(c.out).ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatalf("reading a message failed: %s", err)
+	}
+	length, _ := strconv.Atoi(header.Get("Content-Length"))
+	content := make([]byte, length)
+	if _, err := io.ReadFull(c.out, content); err != nil {
+		c.t.Fatalf("reading a message failed: %s", err)
+	}
+
+	var message dapMessage
+	if err := json.Unmarshal(content, &message); err != nil {
+		c.t.Fatalf("invalid message %s: %s", content, err)
+	}
+	return message
+}
+
+// request sends a request and returns its response, failing if the request
+// did.
+func (c *dapClient) request(command string, arguments any) dapMessage {
+	c.t.Helper()
+
+	seq := c.send(command, arguments)
+	response := c.read()
+	if response.Type != "response" || response.RequestSeq != seq {
+		c.t.Fatalf("expected the response to %s, got = %+v", command, response)
+	}
+	if !response.Success {
+		c.t.Fatalf("%s failed: %s", command, response.Message)
+	}
+	return response
+}
+
+func (c *dapClient) expectEvent(event string) dapMessage {
+	c.t.Helper()
+
+	message := c.read()
+	if message.Type != "event" || message.Event != event {
+		c.t.Fatalf("expected a %s event, got = %+v", event, message)
+	}
+	return message
+}
+
+func TestDAPSession(t *testing.T) {
+	bytecode, globals, file := compile(t, program)
+	launch := func(path string, out io.Writer) (*compiler.Bytecode, []string, bool) {
+		if path != file {
+			fmt.Fprintf(out, "unknown program %s", path)
+			return nil, nil, false
+		}
+		return bytecode, globals, true
+	}
+
+	clientIn, serverIn := io.Pipe()
+	serverOut, clientOut := io.Pipe()
+
+	server := debugger.NewDAPServer(clientIn, clientOut, launch)
+	served := make(chan error)
+	go func() { served <- server.Serve() }()
+
+	c := &dapClient{t: t, in: serverIn, out: bufio.NewReader(serverOut), seq: 0}
+
+	c.request("initialize", map[string]any{"adapterID": "monkey"})
+	c.request("launch", map[string]any{"program": file})
+	c.expectEvent("initialized")
+
+	breakpoints := c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": file},
+		"breakpoints": []map[string]any{{"line": 2}, {"line": 100}},
+	})
+	if got := fmt.Sprint(breakpoints.Body["breakpoints"]); got != "[map[line:2 verified:true] map[line:100 verified:false]]" {
+		t.Errorf("wrong breakpoints. got = %s", got)
+	}
+
+	c.request("configurationDone", nil)
+	stopped := c.expectEvent("stopped")
+	if stopped.Body["reason"] != "breakpoint" {
+		t.Errorf("wrong reason. got = %v", stopped.Body["reason"])
+	}
+
+	trace := c.request("stackTrace", map[string]any{"threadId": 1})
+	frames := trace.Body["stackFrames"].([]any)
+	top := frames[0].(map[string]any)
+	if len(frames) != 2 || top["name"] != "add" || top["line"] != 2.0 {
+		t.Errorf("wrong stack trace. got = %v", frames)
+	}
+
+	scopes := c.request("scopes", map[string]any{"frameId": 0}).Body["scopes"].([]any)
+	locals := scopes[0].(map[string]any)["variablesReference"]
+	variables := c.request("variables", map[string]any{"variablesReference": locals})
+	if got := fmt.Sprint(variables.Body["variables"]); got != "[map[name:a value:1 variablesReference:0] map[name:b value:2 variablesReference:0]]" {
+		t.Errorf("wrong variables. got = %s", got)
+	}
+
+	c.request("next", map[string]any{"threadId": 1})
+	c.expectEvent("stopped")
+
+	evaluated := c.request("evaluate", map[string]any{"expression": "sum", "frameId": 0})
+	if evaluated.Body["result"] != "3" {
+		t.Errorf("wrong evaluation. got = %v", evaluated.Body["result"])
+	}
+
+	c.request("continue", map[string]any{"threadId": 1})
+	if exited := c.expectEvent("exited"); exited.Body["exitCode"] != 0.0 {
+		t.Errorf("wrong exit code. got = %v", exited.Body["exitCode"])
+	}
+	c.expectEvent("terminated")
+
+	c.request("disconnect", nil)
+	if err := <-served; err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
+
+func TestDAPLaunchFailure(t *testing.T) {
+	launch := func(path string, out io.Writer) (*compiler.Bytecode, []string, bool) {
+		fmt.Fprintf(out, "cannot read %s\n", path)
+		return nil, nil, false
+	}
+
+	clientIn, serverIn := io.Pipe()
+	serverOut, clientOut := io.Pipe()
+	go debugger.NewDAPServer(clientIn, clientOut, launch).Serve() //nolint:errcheck
+
+	c := &dapClient{t: t, in: serverIn, out: bufio.NewReader(serverOut), seq: 0}
+	c.request("initialize", nil)
+
+	seq := c.send("launch", map[string]any{"program": "missing.monkey"})
+	if output := c.expectEvent("output"); output.Body["output"] != "cannot read missing.monkey\n" {
+		t.Errorf("wrong output. got = %v", output.Body)
+	}
+	if response := c.read(); response.RequestSeq != seq || response.Success {
+		t.Errorf("launch should have failed. got = %+v", response)
+	}
+}
+
+// outputWriter forwards what the program prints to the client.
+type outputWriter struct {
+	server *debugger.DAPServer
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.server.Output("stdout", string(p))
+	return len(p), nil
+}
+
+func TestDAPProgramOutput(t *testing.T) {
+	bytecode, globals, file := compile(t, `puts(6); puts(30);`)
+	launch := func(string, io.Writer) (*compiler.Bytecode, []string, bool) {
+		return bytecode, globals, true
+	}
+
+	clientIn, serverIn := io.Pipe()
+	serverOut, clientOut := io.Pipe()
+	server := debugger.NewDAPServer(clientIn, clientOut, launch)
+	exec := object.DefaultExecContext()
+	exec.Stdout = outputWriter{server}
+	server.SetExecContext(exec)
+	go server.Serve() //nolint:errcheck
+
+	c := &dapClient{t: t, in: serverIn, out: bufio.NewReader(serverOut), seq: 0}
+	c.request("initialize", nil)
+	c.request("launch", map[string]any{"program": file})
+	c.expectEvent("initialized")
+	c.request("configurationDone", nil)
+
+	for _, want := range []string{"6\n", "30\n"} {
+		if output := c.expectEvent("output"); output.Body["output"] != want || output.Body["category"] != "stdout" {
+			t.Errorf("wrong output. got = %v, want = %q", output.Body, want)
+		}
+	}
+	c.expectEvent("exited")
+}
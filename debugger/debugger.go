@@ -0,0 +1,380 @@
+// debugger runs compiled monkey programs under control: pausing them on
+// breakpoints, stepping through them line by line and inspecting their
+// variables while they're paused.
+//
+// The Debugger is driven either interactively from a terminal (see RunCLI),
+// or by an editor speaking the Debug Adapter Protocol (see DAPServer).
+package debugger
+
+import (
+	"errors"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/object"
+	"monkey/vm"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrStopped is returned by Debugger.Run when the program was stopped before
+// it finished, with Debugger.Stop.
+var ErrStopped = errors.New("the program was stopped")
+
+// StopReason tells why the program paused.
+type StopReason string
+
+const (
+	ReasonEntry              StopReason = "entry"
+	ReasonBreakpoint         StopReason = "breakpoint"
+	ReasonFunctionBreakpoint StopReason = "function breakpoint"
+	ReasonStep               StopReason = "step"
+	ReasonPause              StopReason = "pause"
+)
+
+// Stop is a pause of the program, at the first instruction of a line.
+type Stop struct {
+	Reason   StopReason
+	Location vm.TraceFrame
+}
+
+type stepMode int
+
+const (
+	modeContinue stepMode = iota
+	modeStepIn
+	modeStepOver
+	modeStepOut
+)
+
+// Debugger runs a program, pausing it whenever it reaches a breakpoint or is
+// done with a step.
+//
+// Execution only ever pauses at the first instruction of a line. While it's
+// paused, OnStop is called from the goroutine running the program, and the
+// program resumes once it returns: as set by the last of Continue, StepIn,
+// StepOver or StepOut called.
+type Debugger struct {
+	// OnStop is called whenever the program pauses. The program's state can
+	// be inspected until it returns.
+	OnStop func(Stop)
+
+	machine *vm.VM
+	// bytecode is kept to know which lines breakpoints can be set on.
+	bytecode *compiler.Bytecode
+	globals  []string
+
+	// mu guards the breakpoints, which may be changed while the program runs.
+	mu                  sync.Mutex
+	breakpoints         map[string]map[int]bool
+	functionBreakpoints map[string]bool
+
+	mode      stepMode
+	stepDepth int
+	entry     bool
+
+	// lines are the lines each call in progress is on, the first being that
+	// of the top level. A zero line stands for a call that didn't get to a
+	// line yet.
+	lines []int
+	// files are the absolute paths of the files of the program, by the path
+	// they were compiled with.
+	files map[string]string
+
+	pause atomic.Bool
+	stop  atomic.Bool
+}
+
+// New returns a debugger running bytecode, globals naming its global slots as
+// given by compiler.Compiler.GlobalNames. The program pauses on its first line
+// if stopOnEntry is set.
+func New(bytecode *compiler.Bytecode, globals []string, stopOnEntry bool) *Debugger {
+	d := &Debugger{
+		OnStop:              func(Stop) {},
+		machine:             vm.New(bytecode),
+		bytecode:            bytecode,
+		globals:             globals,
+		breakpoints:         map[string]map[int]bool{},
+		functionBreakpoints: map[string]bool{},
+		mode:                modeContinue,
+		entry:               stopOnEntry,
+		lines:               []int{},
+		files:               map[string]string{},
+	}
+	d.machine.SetHook(d.hook)
+	d.machine.SetGlobalNames(globals)
+	return d
+}
+
+// SetExecContext sets what the builtins of the program reach outside of it
+// through.
+func (d *Debugger) SetExecContext(exec *object.ExecContext) {
+	d.machine.SetExecContext(exec)
+}
+
+// Run runs the program until it finishes, fails or is stopped.
+func (d *Debugger) Run() error {
+	return d.machine.Run()
+}
+
+// MainFile returns the file the top level of the program was compiled from,
+// or an empty string if the bytecode has no source map.
+func (d *Debugger) MainFile() string {
+	for _, mapping := range d.bytecode.SourceMap {
+		if mapping.Span.IsValid() {
+			return mapping.Span.File
+		}
+	}
+	return ""
+}
+
+// SetBreakpoint sets a breakpoint on a line of file. It reports whether any
+// code was compiled from that line, a breakpoint set elsewhere is never hit.
+func (d *Debugger) SetBreakpoint(file string, line int) bool {
+	file = absolute(file)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.breakpoints[file] == nil {
+		d.breakpoints[file] = map[int]bool{}
+	}
+	d.breakpoints[file][line] = true
+
+	return d.hasCode(file, line)
+}
+
+// ClearBreakpoint removes the breakpoint on a line of file, reporting whether
+// there was one.
+func (d *Debugger) ClearBreakpoint(file string, line int) bool {
+	file = absolute(file)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.breakpoints[file][line] {
+		return false
+	}
+	delete(d.breakpoints[file], line)
+	return true
+}
+
+// ClearBreakpoints removes every breakpoint set on lines of file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.breakpoints, absolute(file))
+}
+
+// SetFunctionBreakpoint sets a breakpoint on the first line of every function
+// bound to name.
+func (d *Debugger) SetFunctionBreakpoint(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.functionBreakpoints[name] = true
+}
+
+// ClearFunctionBreakpoint removes the breakpoint on functions bound to name,
+// reporting whether there was one.
+func (d *Debugger) ClearFunctionBreakpoint(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.functionBreakpoints[name] {
+		return false
+	}
+	delete(d.functionBreakpoints, name)
+	return true
+}
+
+// ClearFunctionBreakpoints removes every function breakpoint.
+func (d *Debugger) ClearFunctionBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.functionBreakpoints = map[string]bool{}
+}
+
+// Continue resumes the program until the next breakpoint.
+func (d *Debugger) Continue() {
+	d.mode = modeContinue
+}
+
+// StepIn resumes the program until the next line, including those of the
+// functions it calls.
+func (d *Debugger) StepIn() {
+	d.mode = modeStepIn
+}
+
+// StepOver resumes the program until the next line of the current call, or
+// of its callers if it returns first.
+func (d *Debugger) StepOver() {
+	d.mode = modeStepOver
+	d.stepDepth = d.machine.Depth()
+}
+
+// StepOut resumes the program until it's back in the caller of the current
+// call.
+func (d *Debugger) StepOut() {
+	d.mode = modeStepOut
+	d.stepDepth = d.machine.Depth()
+}
+
+// Pause pauses the program on its next line. Unlike the other methods, it's
+// meant to be called while the program runs, from any goroutine.
+func (d *Debugger) Pause() {
+	d.pause.Store(true)
+}
+
+// Stop stops the program, Run returning ErrStopped. It may be called from
+// any goroutine, while the program runs or is paused.
+func (d *Debugger) Stop() {
+	d.stop.Store(true)
+}
+
+// StackTrace returns the calls in progress, the most recent one first.
+func (d *Debugger) StackTrace() []vm.TraceFrame {
+	return d.machine.StackTrace()
+}
+
+// Locals returns the local variables of the call at depth, 0 being the most
+// recent call as in StackTrace.
+func (d *Debugger) Locals(depth int) []vm.Variable {
+	return d.machine.Locals(depth)
+}
+
+// Free returns the variables captured by the function of the call at depth.
+func (d *Debugger) Free(depth int) []vm.Variable {
+	return d.machine.Free(depth)
+}
+
+// Globals returns the globals of the program that are set.
+func (d *Debugger) Globals() []vm.Variable {
+	return d.machine.Globals(d.globals)
+}
+
+// Lookup finds the value of the variable name as seen from the call at depth:
+// a local variable, a free one or a global, in that order.
+func (d *Debugger) Lookup(depth int, name string) (object.Object, bool) {
+	for _, variables := range [][]vm.Variable{d.Locals(depth), d.Free(depth), d.Globals()} {
+		for _, variable := range variables {
+			if variable.Name == name {
+				return variable.Value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (d *Debugger) hook(machine *vm.VM) error {
+	if d.stop.Load() {
+		return ErrStopped
+	}
+
+	depth := machine.Depth()
+	if len(d.lines) > depth {
+		d.lines = d.lines[:depth]
+	}
+	for len(d.lines) < depth {
+		d.lines = append(d.lines, 0)
+	}
+
+	location := machine.Location()
+	if !location.Span.IsValid() {
+		return nil
+	}
+
+	line := location.Span.Start.Line
+	previous := d.lines[depth-1]
+	if line == previous {
+		return nil
+	}
+	d.lines[depth-1] = line
+
+	reason, ok := d.shouldStop(depth, location, previous == 0)
+	if !ok {
+		return nil
+	}
+
+	d.mode = modeContinue
+	d.OnStop(Stop{Reason: reason, Location: location})
+
+	if d.stop.Load() {
+		return ErrStopped
+	}
+	return nil
+}
+
+// shouldStop tells whether the program should pause, now that it's at the
+// first instruction of a line.
+func (d *Debugger) shouldStop(depth int, location vm.TraceFrame, firstLine bool) (StopReason, bool) {
+	if d.entry {
+		d.entry = false
+		return ReasonEntry, true
+	}
+
+	file, ok := d.files[location.Span.File]
+	if !ok {
+		file = absolute(location.Span.File)
+		d.files[location.Span.File] = file
+	}
+
+	d.mu.Lock()
+	breakpoint := d.breakpoints[file][location.Span.Start.Line]
+	functionBreakpoint := firstLine && depth > 1 && d.functionBreakpoints[location.Function]
+	d.mu.Unlock()
+
+	switch {
+	case breakpoint:
+		return ReasonBreakpoint, true
+	case functionBreakpoint:
+		return ReasonFunctionBreakpoint, true
+	}
+
+	switch d.mode {
+	case modeStepIn:
+		return ReasonStep, true
+	case modeStepOver:
+		if depth <= d.stepDepth {
+			return ReasonStep, true
+		}
+	case modeStepOut:
+		if depth < d.stepDepth {
+			return ReasonStep, true
+		}
+	case modeContinue:
+	}
+
+	if d.pause.Swap(false) {
+		return ReasonPause, true
+	}
+	return "", false
+}
+
+// hasCode reports whether any instruction was compiled from line of file.
+func (d *Debugger) hasCode(file string, line int) bool {
+	sourceMaps := []code.SourceMap{d.bytecode.SourceMap}
+	for _, constant := range d.bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			sourceMaps = append(sourceMaps, fn.SourceMap)
+		}
+	}
+
+	for _, sourceMap := range sourceMaps {
+		for _, mapping := range sourceMap {
+			if mapping.Span.IsValid() && mapping.Span.Start.Line == line && absolute(mapping.Span.File) == file {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func absolute(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return file
+}
@@ -0,0 +1,162 @@
+package debugger_test
+
+import (
+	"monkey/compiler"
+	"monkey/debugger"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let makeCounter = fn() {
+  let count = 0;
+  fn() {
+    count = count + 1;
+    count
+  }
+};
+let counter = makeCounter();
+counter();
+let total = add(1, 2);
+total
+`
+
+// compile compiles source as the file script.monkey of a temporary directory.
+func compile(t *testing.T, source string) (*compiler.Bytecode, []string, string) {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "script.monkey")
+	if err := os.WriteFile(file, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := parser.New(lexer.NewWithFilename(file, source))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parser errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	return comp.Bytecode(), comp.GlobalNames(), file
+}
+
+func TestStepping(t *testing.T) {
+	bytecode, globals, _ := compile(t, program)
+	d := debugger.New(bytecode, globals, true)
+
+	// Each stop is described by its reason and line, and the commands to run
+	// there follow.
+	stops := []struct {
+		expected string
+		commands func()
+	}{
+		{"entry 1", func() { d.SetBreakpoint(d.MainFile(), 13); d.Continue() }},
+		{"breakpoint 13", d.StepIn},
+		{"step 8", d.StepOver},
+		{"step 9", d.StepOut},
+		{"step 14", d.StepIn},
+		{"step 2", d.StepOver},
+		{"step 3", d.StepOver},
+		{"step 15", d.Continue},
+	}
+
+	got := []string{}
+	d.OnStop = func(stop debugger.Stop) {
+		if len(got) >= len(stops) {
+			t.Fatalf("unexpected stop: %+v", stop)
+		}
+		got = append(got, string(stop.Reason)+" "+strconv.Itoa(stop.Location.Span.Start.Line))
+		stops[len(got)-1].commands()
+	}
+
+	if err := d.Run(); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+
+	expected := []string{}
+	for _, stop := range stops {
+		expected = append(expected, stop.expected)
+	}
+	if strings.Join(got, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("wrong stops.\n got = %s\nwant = %s", strings.Join(got, ", "), strings.Join(expected, ", "))
+	}
+}
+
+func TestFunctionBreakpointAndVariables(t *testing.T) {
+	bytecode, globals, _ := compile(t, program)
+	d := debugger.New(bytecode, globals, false)
+	d.SetFunctionBreakpoint("add")
+
+	stopped := false
+	d.OnStop = func(stop debugger.Stop) {
+		stopped = true
+		if stop.Reason != debugger.ReasonFunctionBreakpoint || stop.Location.Function != "add" {
+			t.Errorf("wrong stop: %+v", stop)
+		}
+
+		if a, ok := d.Lookup(0, "a"); !ok || a.Inspect() != "1" {
+			t.Errorf("wrong a: %v", a)
+		}
+		if _, ok := d.Lookup(0, "sum"); ok {
+			t.Errorf("sum should not be defined yet")
+		}
+		if trace := d.StackTrace(); len(trace) != 2 || trace[1].Function != "<main>" {
+			t.Errorf("wrong stack trace: %v", trace)
+		}
+
+		names := []string{}
+		for _, global := range d.Globals() {
+			names = append(names, global.Name)
+		}
+		if strings.Join(names, " ") != "add makeCounter counter" {
+			t.Errorf("wrong globals: %v", names)
+		}
+	}
+
+	if err := d.Run(); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	if !stopped {
+		t.Errorf("the breakpoint was not hit")
+	}
+}
+
+func TestCLI(t *testing.T) {
+	bytecode, globals, file := compile(t, program)
+	d := debugger.New(bytecode, globals, true)
+
+	commands := strings.Join([]string{"b 8", "b 100", "c", "free", "p count", "bt", "q"}, "\n")
+
+	var out strings.Builder
+	err := debugger.RunCLI(d, strings.NewReader(commands), &out)
+	if err != debugger.ErrStopped {
+		t.Fatalf("expected the program to be stopped, got = %v", err)
+	}
+
+	expected := `Stopped at FILE:1:11 in <main> (entry)
+>    1 | let add = fn(a, b) {
+(monkey) Breakpoint at FILE:8
+(monkey) Breakpoint at FILE:100, which has no code: it will never be hit
+(monkey) Stopped at FILE:8:13 in <anonymous> (breakpoint)
+>    8 |     count = count + 1;
+(monkey) count = 0
+(monkey) count = 0
+(monkey) #0 at <anonymous> (FILE:8:13) [0000]
+#1 at <main> (FILE:13:8) [0025]
+(monkey) `
+	if got := strings.ReplaceAll(out.String(), file, "FILE"); got != expected {
+		t.Errorf("wrong output.\n got = %s\nwant = %s", got, expected)
+	}
+}
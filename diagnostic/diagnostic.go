@@ -0,0 +1,123 @@
+// diagnostic contains the structured representation of problems found in monkey
+// source code, shared by every stage that reports them (lexing, parsing, compiling...).
+package diagnostic
+
+import (
+	"encoding/json"
+	"fmt"
+	"monkey/token"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota + 1
+	SeverityWarning
+	SeverityInfo
+	SeverityHint
+)
+
+var severityNames = map[Severity]string{
+	SeverityError:   "error",
+	SeverityWarning: "warning",
+	SeverityInfo:    "info",
+	SeverityHint:    "hint",
+}
+
+func (s Severity) String() string {
+	name, ok := severityNames[s]
+	if !ok {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return name
+}
+
+// MarshalJSON encodes the severity by its name rather than by its number, so
+// tools consuming the output don't need to know about our enum values.
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	for severity, severityName := range severityNames {
+		if severityName == name {
+			*s = severity
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown severity %q", name)
+}
+
+// Code is a stable identifier of the kind of the diagnostic, e.g. "P0001".
+//
+// Unlike the message, codes never change, and thus are what tooling should
+// match on.
+type Code string
+
+// Suggestion is a proposed fix: replacing the source at Span with Replacement.
+type Suggestion struct {
+	Message     string     `json:"message"`
+	Span        token.Span `json:"span"`
+	Replacement string     `json:"replacement"`
+}
+
+type Diagnostic struct {
+	Severity    Severity     `json:"severity"`
+	Code        Code         `json:"code"`
+	Span        token.Span   `json:"span"`
+	Message     string       `json:"message"`
+	Notes       []string     `json:"notes,omitempty"`
+	Suggestions []Suggestion `json:"suggestions,omitempty"`
+}
+
+func New(severity Severity, code Code, span token.Span, format string, args ...any) Diagnostic {
+	return Diagnostic{
+		Severity:    severity,
+		Code:        code,
+		Span:        span,
+		Message:     fmt.Sprintf(format, args...),
+		Notes:       nil,
+		Suggestions: nil,
+	}
+}
+
+func Errorf(code Code, span token.Span, format string, args ...any) Diagnostic {
+	return New(SeverityError, code, span, format, args...)
+}
+
+func Warningf(code Code, span token.Span, format string, args ...any) Diagnostic {
+	return New(SeverityWarning, code, span, format, args...)
+}
+
+func (d Diagnostic) WithNote(format string, args ...any) Diagnostic {
+	d.Notes = append(d.Notes[:len(d.Notes):len(d.Notes)], fmt.Sprintf(format, args...))
+	return d
+}
+
+func (d Diagnostic) WithSuggestion(s Suggestion) Diagnostic {
+	d.Suggestions = append(d.Suggestions[:len(d.Suggestions):len(d.Suggestions)], s)
+	return d
+}
+
+// Error formats the diagnostic on a single line as "file:line:col: message",
+// which is what most editors and CI systems know how to pick up.
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s", d.Span, d.Message)
+}
+
+// HasErrors reports whether any of the diagnostics is severe enough to stop
+// the program from running.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package diagnostic_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"monkey/diagnostic"
+	"monkey/token"
+	"testing"
+)
+
+func span(line, startCol, endCol int) token.Span {
+	return token.Span{
+		File:  "script.monkey",
+		Start: token.Position{Offset: 0, Line: line, Column: startCol},
+		End:   token.Position{Offset: 0, Line: line, Column: endCol},
+	}
+}
+
+func TestFprint(t *testing.T) {
+	source := "let x = 1;\nlet = 5;\n"
+	d := diagnostic.Errorf("P0001", span(2, 5, 6), "expected next token to be IDENT, got ASSIGN instead").
+		WithNote("a name is needed").
+		WithSuggestion(diagnostic.Suggestion{Message: "name the binding", Span: span(2, 5, 5), Replacement: "x "})
+
+	expected := `error[P0001]: expected next token to be IDENT, got ASSIGN instead
+ --> script.monkey:2:5
+  |
+2 | let = 5;
+  |     ^
+  = note: a name is needed
+  = help: name the binding
+`
+
+	var out bytes.Buffer
+	diagnostic.Fprint(&out, source, d)
+
+	if out.String() != expected {
+		t.Errorf("wrong rendering.\ngot:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
+
+func TestFprintUnderlinesWholeSpanAndKeepsTabs(t *testing.T) {
+	source := "\tlet x = foobar;"
+	d := diagnostic.Warningf("X0001", span(1, 10, 16), "unused")
+
+	expected := "warning[X0001]: unused\n" +
+		" --> script.monkey:1:10\n" +
+		"  |\n" +
+		"1 | \tlet x = foobar;\n" +
+		"  | \t        ^^^^^^\n"
+
+	var out bytes.Buffer
+	diagnostic.Fprint(&out, source, d)
+
+	if out.String() != expected {
+		t.Errorf("wrong rendering.\ngot:\n%q\nwant:\n%q", out.String(), expected)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	d := diagnostic.Errorf("P0002", span(1, 1, 2), "bad").WithNote("note")
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("marshal failed: %s", err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("unmarshal into map failed: %s", err)
+	}
+
+	if fields["severity"] != "error" {
+		t.Errorf("severity should be encoded by name. got = %v", fields["severity"])
+	}
+
+	var decoded diagnostic.Diagnostic
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %s", err)
+	}
+
+	if decoded.Error() != d.Error() || decoded.Severity != d.Severity || decoded.Code != d.Code {
+		t.Errorf("round trip mismatch. got = %+v, want = %+v", decoded, d)
+	}
+}
+
+func TestWithNoteDoesNotAlias(t *testing.T) {
+	base := diagnostic.Errorf("P0001", span(1, 1, 1), "msg").WithNote("first")
+
+	a := base.WithNote("a")
+	b := base.WithNote("b")
+
+	if a.Notes[1] != "a" || b.Notes[1] != "b" {
+		t.Errorf("notes alias each other. a = %q, b = %q", a.Notes, b.Notes)
+	}
+}
@@ -0,0 +1,94 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Fprint renders the diagnostic for humans, quoting the offending line of
+// source and pointing at the problem with carets:
+//
+//	error[P0001]: expected next token to be IDENT, got ASSIGN instead
+//	 --> script.monkey:1:5
+//	  |
+//	1 | let = 5;
+//	  |     ^
+//
+// source is the full text the diagnostic's span points into. If the line can't
+// be found in it, the snippet is simply left out.
+func Fprint(out io.Writer, source string, d Diagnostic) {
+	if d.Code != "" {
+		fmt.Fprintf(out, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+	} else {
+		fmt.Fprintf(out, "%s: %s\n", d.Severity, d.Message)
+	}
+
+	line, hasLine := sourceLine(source, d.Span.Start.Line)
+	gutter := strings.Repeat(" ", len(strconv.Itoa(d.Span.Start.Line)))
+
+	fmt.Fprintf(out, "%s--> %s\n", gutter, d.Span)
+
+	if d.Span.IsValid() && hasLine {
+		fmt.Fprintf(out, "%s |\n", gutter)
+		fmt.Fprintf(out, "%d | %s\n", d.Span.Start.Line, line)
+		fmt.Fprintf(out, "%s | %s\n", gutter, caretLine(line, d))
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(out, "%s = note: %s\n", gutter, note)
+	}
+
+	for _, suggestion := range d.Suggestions {
+		fmt.Fprintf(out, "%s = help: %s\n", gutter, suggestion.Message)
+	}
+}
+
+// FprintAll renders every one of the diagnostics, separated by blank lines.
+func FprintAll(out io.Writer, source string, diagnostics []Diagnostic) {
+	for i, d := range diagnostics {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		Fprint(out, source, d)
+	}
+}
+
+func sourceLine(source string, line int) (string, bool) {
+	if line < 1 {
+		return "", false
+	}
+
+	lines := strings.Split(source, "\n")
+	if line > len(lines) {
+		return "", false
+	}
+
+	return strings.TrimRight(lines[line-1], "\r"), true
+}
+
+// caretLine builds the line that goes under the quoted source, with carets
+// under the span. Tabs in the source are kept so the carets stay aligned no
+// matter how wide the terminal renders them.
+func caretLine(line string, d Diagnostic) string {
+	var out strings.Builder
+
+	chars := []rune(line)
+	start := d.Span.Start.Column - 1
+	for i := 0; i < start; i++ {
+		if i < len(chars) && chars[i] == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+
+	width := 1
+	if d.Span.End.Line == d.Span.Start.Line && d.Span.End.Column > d.Span.Start.Column {
+		width = d.Span.End.Column - d.Span.Start.Column
+	}
+	out.WriteString(strings.Repeat("^", width))
+
+	return out.String()
+}
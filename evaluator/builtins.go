@@ -11,3 +11,21 @@ var builtins = func() map[string]*object.Builtin {
 	}
 	return bm
 }()
+
+// LookupBuiltin returns the standard builtin function called name.
+func LookupBuiltin(name string) (object.Object, bool) {
+	builtin, ok := builtins[name]
+	if !ok {
+		return nil, false
+	}
+	return builtin, true
+}
+
+// lookupBuiltin returns the builtin called name for code running in env.
+func lookupBuiltin(env *object.Environment, name string) (*object.Builtin, bool) {
+	if registry := env.Builtins(); registry != nil {
+		return registry.Lookup(name)
+	}
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
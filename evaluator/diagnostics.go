@@ -0,0 +1,10 @@
+package evaluator
+
+import "monkey/diagnostic"
+
+// Codes of the diagnostics reported while expanding macros.
+const (
+	CodeMacroExpansionFailed diagnostic.Code = "M0001"
+	CodeMacroResultNotQuote  diagnostic.Code = "M0002"
+	CodeMacroArguments       diagnostic.Code = "M0003"
+)
@@ -1,6 +1,7 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
 	"monkey/ast"
 	"monkey/object"
@@ -14,6 +15,63 @@ func isError(obj object.Object) bool {
 }
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	budget := env.Budget()
+	if budget != nil {
+		if err := budget.Step(); err != nil {
+			return budgetError(err)
+		}
+	}
+
+	result := evalNode(node, env)
+
+	if budget != nil && allocates(node) {
+		if err := budget.Allocate(result); err != nil {
+			return budgetError(err)
+		}
+	}
+
+	// The innermost node an error bubbles out of is the one that caused it,
+	// so only the first node to see the error gets to stamp its location.
+	if err, ok := result.(*object.Error); ok && !err.Span.IsValid() && node != nil {
+		err.Span = node.Span()
+	}
+
+	return result
+}
+
+// EvalContext evaluates node as Eval does, within limits. It stops once ctx
+// is done, failing with the error of ctx, and fails with an
+// *object.LimitExceededError once a limit is exceeded.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits object.Limits) (object.Object, error) {
+	budget := object.NewBudget(ctx, limits)
+	env.SetBudget(budget)
+	defer env.SetBudget(nil)
+
+	result := Eval(node, env)
+	if err := budget.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// allocates reports whether evaluating node may create a value the budget
+// counts. Calls are counted as they're applied, since functions may return
+// values that already exist.
+func allocates(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.ArrayLiteral, *ast.HashLiteral, *ast.FunctionLiteral, *ast.InfixExpression:
+		return true
+	}
+	return false
+}
+
+// budgetError stops the evaluation once the budget failed, the budget itself
+// keeping why.
+func budgetError(err error) *object.Error {
+	return newError("%s", err)
+}
+
+func evalNode(node ast.Node, env *object.Environment) object.Object {
 	// make sure all branches return a value
 	switch v := node.(type) {
 	case *ast.Program:
@@ -25,6 +83,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IntegerLiteral:
 		return evalIntegerLiteral(v)
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: v.Value}
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(v.Value())
 
@@ -64,13 +125,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
-		env.Set(v.Name.Value, val)
+		env.Define(v.Name.Value, val)
 		return &object.CONST_NULL
 
 	case *ast.FunctionLiteral:
-		params := v.Parameters()
-		body := v.Body()
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		name, _ := v.Name()
+		return &object.Function{Name: name, Parameters: v.Parameters(), Env: env, Body: v.Body()}
 
 	case *ast.CallExpression:
 		// Handle the "quote" magic case
@@ -93,7 +153,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			}
 			args = append(args, res)
 		}
-		return applyFunction(function, args)
+
+		budget := env.Budget()
+		if budget == nil {
+			return applyFunction(env.ExecContext(), function, args)
+		}
+		return applyWithBudget(budget, env.ExecContext(), function, args)
 
 	case *ast.PrefixExpression:
 		right := Eval(v.Right, env)
@@ -119,20 +184,42 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IfExpression:
 		return evalIfExpression(v, env)
 
+	case *ast.AssignStatement:
+		return evalAssignStatement(v, env)
+
+	case *ast.WhileStatement:
+		return evalWhileStatement(v, env)
+
+	case *ast.ForStatement:
+		return evalForStatement(v, env)
+
+	case *ast.BreakStatement:
+		return &object.Break{}
+
+	case *ast.ContinueStatement:
+		return &object.Continue{}
+
 	case *ast.ReturnStatement:
 		val := Eval(v.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+
+	case *ast.ImportExpression:
+		importer := env.Importer()
+		if importer == nil {
+			return newError("cannot import %q: modules are not available here", v.Path())
+		}
+		return importer.Import(v.Path(), v.Span())
 	}
 	return newError("Cannot handle node of type %T", node)
 }
 
 func evalHashLiteral(h *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := map[object.HashKey]object.HashPair{}
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range h.Pairs() {
+	for _, keyNode := range h.Keys() {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
@@ -140,24 +227,26 @@ func evalHashLiteral(h *ast.HashLiteral, env *object.Environment) object.Object
 
 		hashed, err := key.HashKey()
 		if err != nil {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError("%s", err)
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(h.Pairs()[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashed, object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalIndexExpression(left object.Object, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -170,10 +259,10 @@ func evalHashIndexExpression(left object.Object, index object.Object) object.Obj
 
 	key, err := index.HashKey()
 	if err != nil {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError("%s", err)
 	}
 
-	pair, ok := hashObject.Pairs[key]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return &object.CONST_NULL
 	}
@@ -192,7 +281,25 @@ func evalArrayIndexExpression(left object.Object, index object.Object) object.Ob
 	return arr.Elements[idx]
 }
 
+func evalStringIndexExpression(left object.Object, index object.Object) object.Object {
+	str := left.(*object.String)
+	idx := index.(*object.Integer).Value
+
+	char, ok := str.CharAt(idx)
+	if !ok {
+		return &object.CONST_NULL
+	}
+	return char
+}
+
 func evalProgram(p *ast.Program, env *object.Environment) object.Object {
+	// Programs run without limits still have their calls counted, so that
+	// they fail rather than overflow the stack.
+	if env.Budget() == nil {
+		env.SetBudget(object.NewBudget(context.Background(), object.Limits{})) //nolint:exhaustruct
+		defer env.SetBudget(nil)
+	}
+
 	var result object.Object
 
 	for _, statement := range p.Statements {
@@ -217,7 +324,7 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -242,6 +349,172 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+func evalAssignStatement(as *ast.AssignStatement, env *object.Environment) object.Object {
+	switch target := as.Target.(type) {
+	case *ast.Identifier:
+		if !assignable(target.Value, env) {
+			err := newError("cannot assign to %s", target.Value)
+			err.Span = target.Span()
+			return err
+		}
+
+		var current object.Object
+		op, isCompound := as.BinaryOperator()
+		if isCompound {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+
+		value := Eval(as.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		if isCompound {
+			value = evalInfixExpression(op, current, value)
+			if isError(value) {
+				return value
+			}
+		}
+
+		if _, ok := env.Set(target.Value, value); !ok {
+			return newError("identifier not found: %s", target.Value)
+		}
+		return &object.CONST_NULL
+
+	case *ast.IndexExpression:
+		collection := Eval(target.Left(), env)
+		if isError(collection) {
+			return collection
+		}
+
+		index := Eval(target.Index(), env)
+		if isError(index) {
+			return index
+		}
+
+		var current object.Object
+		op, isCompound := as.BinaryOperator()
+		if isCompound {
+			current = evalIndexExpression(collection, index)
+			if isError(current) {
+				return current
+			}
+		}
+
+		value := Eval(as.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		if isCompound {
+			value = evalInfixExpression(op, current, value)
+			if isError(value) {
+				return value
+			}
+		}
+
+		result := evalIndexAssignment(collection, index, value)
+		if budget := env.Budget(); budget != nil && !isError(result) {
+			if err := budget.CheckSize(collection); err != nil {
+				return budgetError(err)
+			}
+		}
+		return result
+
+	default:
+		return newError("cannot assign to %s", as.Target.String())
+	}
+}
+
+func evalIndexAssignment(collection object.Object, index object.Object, value object.Object) object.Object {
+	switch collection := collection.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+
+		if idx.Value < 0 || idx.Value >= int64(len(collection.Elements)) {
+			return newError("index out of range: %d", idx.Value)
+		}
+
+		collection.Elements[idx.Value] = value
+		return &object.CONST_NULL
+
+	case *object.Hash:
+		key, err := index.HashKey()
+		if err != nil {
+			return newError("%s", err)
+		}
+
+		collection.Set(key, object.HashPair{Key: index, Value: value})
+		return &object.CONST_NULL
+
+	default:
+		return newError("index assignment not supported: %s", collection.Type())
+	}
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition(), env)
+		if isError(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			return &object.CONST_NULL
+		}
+
+		if result, done := evalLoopBody(ws.Body(), env); done {
+			return result
+		}
+	}
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable(), env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	iterator, err := object.NewIterator(iterable)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	for element, ok := iterator.Next(); ok; element, ok = iterator.Next() {
+		env.Define(fs.Variable().Value, element)
+
+		if result, done := evalLoopBody(fs.Body(), env); done {
+			return result
+		}
+	}
+
+	return &object.CONST_NULL
+}
+
+// evalLoopBody runs a single iteration of a loop, and reports whether the loop
+// is done - and if so with what result.
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	result := Eval(body, env)
+	if result == nil {
+		return nil, false
+	}
+
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return &object.CONST_NULL, true
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case &object.CONST_NULL:
@@ -277,7 +550,7 @@ func evalIdentifier(i *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(i.Value); ok {
 		return val
 	}
-	if builtin, ok := builtins[i.Value]; ok {
+	if builtin, ok := lookupBuiltin(env, i.Value); ok {
 		return builtin
 	}
 	return newError("identifier not found: %s", i.Value)
@@ -294,24 +567,100 @@ func evalPrefixExpression(op string, right object.Object) object.Object {
 	}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// Apply calls fn, a function or a builtin, with the given arguments from
+// outside of any evaluation, e.g. to call back into a script from Go.
+// Builtins are called with the default context.
+func Apply(fn object.Object, args ...object.Object) object.Object {
+	return ApplyWith(object.DefaultExecContext(), fn, args...)
+}
+
+// ApplyWith calls fn as Apply does, builtins being called with exec.
+func ApplyWith(exec *object.ExecContext, fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(exec, fn, args)
+}
+
+// applyFunction calls fn with args. Functions run in their own environment,
+// builtins with exec, through which they may call back the functions they're
+// given.
+func applyFunction(exec *object.ExecContext, fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want = %d, got = %d", len(fn.Parameters), len(args))
+		}
+
 		extendedEnv := extendFunctionEnv(fn, args)
 		evaluated := Eval(fn.Body, extendedEnv)
+		// A body that doesn't evaluate to anything, as an empty one, returns
+		// null as on the compiled engine, so that builtins never get nil.
+		if evaluated == nil {
+			return &object.CONST_NULL
+		}
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return fn.Fn(exec.WithCaller(caller{exec}), args...)
 	default:
 		return newError("trying to call what is not a function: %s", fn.Type())
 	}
 }
 
+// caller calls back the functions builtins are given.
+type caller struct {
+	exec *object.ExecContext
+}
+
+func (c caller) Call(fn object.Object, args ...object.Object) object.Object {
+	if function, ok := fn.(*object.Function); ok {
+		if budget := function.Env.Budget(); budget != nil {
+			return applyWithBudget(budget, c.exec, fn, args)
+		}
+	}
+	return applyFunction(c.exec, fn, args)
+}
+
+// applyWithBudget applies fn as applyFunction does, counting the call and the
+// values builtins return against budget, which builtins are given to check
+// what they create against.
+func applyWithBudget(budget *object.Budget, exec *object.ExecContext, fn object.Object, args []object.Object) object.Object {
+	if err := budget.Enter(); err != nil {
+		return budgetError(err)
+	}
+	if _, ok := fn.(*object.Builtin); ok {
+		exec = exec.WithBudget(budget)
+	}
+	result := applyFunction(exec, fn, args)
+	budget.Leave()
+
+	if _, ok := fn.(*object.Builtin); ok && result != nil {
+		if err := budget.Allocate(result); err != nil {
+			return budgetError(err)
+		}
+	}
+	return result
+}
+
+// assignable reports whether name can be assigned to in env: builtins and the
+// name of the function being called can't be.
+func assignable(name string, env *object.Environment) bool {
+	if _, ok := env.Get(name); !ok {
+		_, isBuiltin := lookupBuiltin(env, name)
+		return !isBuiltin
+	}
+	return !env.Fixed(name)
+}
+
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := fn.Env.NewScoped()
 
+	// Within its body, the function is called by its name whatever the name
+	// is rebound to elsewhere, and the name can't be assigned to, as with
+	// the compiled engine.
+	if fn.Name != "" {
+		env.DefineFixed(fn.Name, fn)
+	}
+
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		env.Define(param.Value, args[paramIdx])
 	}
 
 	return env
@@ -329,6 +678,8 @@ func evalInfixExpression(op string, left object.Object, right object.Object) obj
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(op, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return evalFloatInfixExpression(op, left, right)
 	case op == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case op == "!=":
@@ -355,6 +706,9 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -373,6 +727,38 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	}
 }
 
+// evalFloatInfixExpression evaluates an operation between two numbers at least
+// one of which is a float, the other one being promoted to a float as well.
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal, _ := object.ToFloat(left)
+	rightVal, _ := object.ToFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
 func evalBangOperatorExpression(right object.Object) object.Object {
 	switch right {
 	case &object.CONST_TRUE:
@@ -400,15 +786,20 @@ func evalStringInfixExpression(op string, left object.Object, right object.Objec
 	case object.INTEGER_OBJ:
 		rightVal := right.(*object.Integer).Value
 		return &object.String{Value: fmt.Sprintf("%s%d", leftVal, rightVal)}
+	case object.FLOAT_OBJ:
+		return &object.String{Value: leftVal + right.Inspect()}
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
 	}
 }
 
 func evalMinusOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
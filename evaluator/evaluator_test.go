@@ -1,10 +1,16 @@
 package evaluator_test
 
 import (
+	"context"
+	"errors"
+	"monkey/evaluator"
 	. "monkey/evaluator/internal/evaluatortest"
+	"monkey/lexer"
 	"monkey/object"
+	"monkey/parser"
 	"monkey/testutils"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -196,6 +202,36 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorLocations(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedLocation string
+	}{
+		{"5 + true;", "script.monkey:1:3"},
+		{"let a = 1;\nlet b = a * c;", "script.monkey:2:13"},
+		{"let f = fn(x) {\n  x - \"one\"\n};\nf(1);", "script.monkey:2:5"},
+		{"len(1)", "script.monkey:1:4"},
+		{"let a = 4;\na / 0", "script.monkey:2:3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := parser.New(lexer.NewWithFilename("script.monkey", tt.input))
+			program := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("unexpected parser errors: %v", p.Errors())
+			}
+
+			evaluated := evaluator.Eval(program, object.NewEnvironment())
+			errObj := testutils.CheckIsA[object.Error](t, evaluated, "evaluated is not an error object.")
+
+			if errObj.Span.String() != tt.expectedLocation {
+				t.Errorf("wrong error location. expected = %q, got = %q", tt.expectedLocation, errObj.Span.String())
+			}
+		})
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -291,7 +327,8 @@ func TestBuiltinFunction(t *testing.T) {
 		// Tests for `len`
 		{`len("")`, NewResultInInt(0)},
 		{`len("four")`, NewResultInInt(4)},
-		{`len(1)`, NewResultInError("argument to `len` not supported, got INTEGER")},
+		{`len("héllo 😀")`, NewResultInInt(7)},
+		{`len(1)`, NewResultInError("argument to `len` must be STRING, ARRAY, RANGE or HASH, got INTEGER")},
 		{`len("one", "two")`, NewResultInError("wrong number of arguments. got = 2, want = 1")},
 		{`len([1, 2, 3])`, NewResultInInt(3)},
 
@@ -301,7 +338,7 @@ func TestBuiltinFunction(t *testing.T) {
 		{`first([])`, NewResultInNil()},
 		{`first([], [])`, NewResultInError("wrong number of arguments. got = 2, want = 1")},
 		{`first([], [], [])`, NewResultInError("wrong number of arguments. got = 3, want = 1")},
-		{`first(123)`, NewResultInError("argument to `first` must be an ARRAY, got INTEGER")},
+		{`first(123)`, NewResultInError("argument to `first` must be ARRAY, got INTEGER")},
 
 		// Tests for `last`
 		{`last([1, 2])`, NewResultInInt(2)},
@@ -309,13 +346,13 @@ func TestBuiltinFunction(t *testing.T) {
 		{`last([])`, NewResultInNil()},
 		{`last([], [])`, NewResultInError("wrong number of arguments. got = 2, want = 1")},
 		{`last([], [], [])`, NewResultInError("wrong number of arguments. got = 3, want = 1")},
-		{`last(123)`, NewResultInError("argument to `last` must be an ARRAY, got INTEGER")},
+		{`last(123)`, NewResultInError("argument to `last` must be ARRAY, got INTEGER")},
 
 		// Tests for `rest`
 		{`rest([1, 2, 3])`, NewResultInArray(NewResultInInt(2), NewResultInInt(3))},
 		{`rest([3, 2, 1])`, NewResultInArray(NewResultInInt(2), NewResultInInt(1))},
 		{`rest([])`, NewResultInNil()},
-		{`rest(123)`, NewResultInError("argument to `rest` must be an ARRAY, got INTEGER")},
+		{`rest(123)`, NewResultInError("argument to `rest` must be ARRAY, got INTEGER")},
 
 		// Tests for `push`
 		{`push([], 1)`, NewResultInArray(NewResultInInt(1))},
@@ -333,8 +370,9 @@ func TestBuiltinFunction(t *testing.T) {
 		{`sprintf("%s %s", "hello", "world")`, NewResultInString("hello world")},
 		{`sprintf("hello %s", "world")`, NewResultInString("hello world")},
 		{`sprintf("1 %d", 2)`, NewResultInString("1 2")},
-		{`sprintf()`, NewResultInError("sprintf function requires at least a single argument")},
-		{`sprintf(2)`, NewResultInError("first argument to `sprintf` must be STRING, got = INTEGER")},
+		{`sprintf("%.2f%%", 12.345)`, NewResultInString("12.35%")},
+		{`sprintf()`, NewResultInError("wrong number of arguments. got = 0, want = at least 1")},
+		{`sprintf(2)`, NewResultInError("first argument to `sprintf` must be STRING, got INTEGER")},
 	}
 
 	for _, tt := range tests {
@@ -393,6 +431,18 @@ func TestIndexExpression(t *testing.T) {
 			"[1, 2, 3][-1]",
 			NewResultInNil(),
 		},
+		{
+			`"héllo"[1]`,
+			NewResultInString("é"),
+		},
+		{
+			`"a😀b"[2]`,
+			NewResultInString("b"),
+		},
+		{
+			`"abc"[3]`,
+			NewResultInNil(),
+		},
 	}
 
 	for _, tt := range tests {
@@ -473,3 +523,346 @@ func TestHashIndexExpression(t *testing.T) {
 		})
 	}
 }
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected CheckEvaluated
+	}{
+		{`fn() { for (x in range(10)) { if (x == 3) { return x * 10; } } }()`, NewResultInInt(30)},
+		{`fn() { for (x in [1, 2, 3, 4]) { if (x == 3) { break; } let seen = x; }; seen }()`, NewResultInInt(2)},
+		{`fn() { for (x in [1, 2, 3, 4]) { if (x == 4) { continue; } let seen = x; }; seen }()`, NewResultInInt(3)},
+		{`fn() { for (x in range(10, 0, -3)) { let last = x; }; last }()`, NewResultInInt(1)},
+		{`fn() { for (c in "hé😀") { let last = c; }; last }()`, NewResultInString("😀")},
+		{`fn() { for (pair in {"b": 2, "a": 1}) { let last = pair; }; last }()`, NewResultInArray(NewResultInString("a"), NewResultInInt(1))},
+		{`fn() { while (true) { break; }; 5 }()`, NewResultInInt(5)},
+		{`while (false) { 1 }`, NewResultInNil()},
+		{`for (x in []) { x }`, NewResultInNil()},
+		{`for (x in 5) { x }`, NewResultInError("cannot iterate over INTEGER")},
+		{`range(1, 2, 0)`, NewResultInError("`range` step must not be zero")},
+		{`range("a")`, NewResultInError("first argument to `range` must be INTEGER, got STRING")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := DoEval(tt.input)
+			tt.expected.CheckEvaluated(t, evaluated)
+		})
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected CheckEvaluated
+	}{
+		{`let x = 1; x = 2; x`, NewResultInInt(2)},
+		{`let x = 1; x = 2`, NewResultInNil()},
+		{`let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x`, NewResultInInt(6)},
+		{`let s = "a"; s += "b"; s`, NewResultInString("ab")},
+		{`let i = 0; while (i < 5) { i += 1; }; i`, NewResultInInt(5)},
+		{`let x = 1; let f = fn() { x = 2; }; f(); x`, NewResultInInt(2)},
+		{`let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c(); c()`, NewResultInInt(3)},
+		{`let a = [1, 2, 3]; a[1] = 5; a`, NewResultInArray(NewResultInInt(1), NewResultInInt(5), NewResultInInt(3))},
+		{`let a = [1, 2, 3]; a[2] *= 10; a[2]`, NewResultInInt(30)},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] += 1; h["a"] + h["b"]`, NewResultInInt(4)},
+		{`let a = [1]; let b = push(a, 2); a[0] = 9; b[0]`, NewResultInInt(1)},
+		{`y = 1`, NewResultInError("identifier not found: y")},
+		{`let x = 1; x += "a"`, NewResultInError("type mismatch: INTEGER + STRING")},
+		{`let a = [1]; a[1] = 2`, NewResultInError("index out of range: 1")},
+		{`let a = [1]; a["0"] = 2`, NewResultInError("array index must be INTEGER, got STRING")},
+		{`let h = {}; h[fn() {}] = 2`, NewResultInError("unusable as hash key: FUNCTION")},
+		{`let s = "ab"; s[0] = "c"`, NewResultInError("index assignment not supported: STRING")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := DoEval(tt.input)
+			tt.expected.CheckEvaluated(t, evaluated)
+		})
+	}
+}
+
+func TestFloats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected CheckEvaluated
+	}{
+		{`1.5`, NewResultInFloat(1.5)},
+		{`-2.5e-1`, NewResultInFloat(-0.25)},
+		{`1.5 + 1`, NewResultInFloat(2.5)},
+		{`3 / 2.0`, NewResultInFloat(1.5)},
+		{`3 / 2`, NewResultInInt(1)},
+		{`0.1 * 3 - 0.3 < 1e-9`, NewResultInBool(true)},
+		{`1 == 1.0`, NewResultInBool(true)},
+		{`2.5 >= 3`, NewResultInBool(false)},
+		{`let x = 100; x *= 0.15; x`, NewResultInFloat(15)},
+		{`{1: "one"}[1.0]`, NewResultInString("one")},
+		{`{0.5: "half"}[0.5]`, NewResultInString("half")},
+		{`"ratio: " + 0.5`, NewResultInString("ratio: 0.5")},
+		{`floor(2.7)`, NewResultInInt(2)},
+		{`floor(-2.5)`, NewResultInInt(-3)},
+		{`ceil(2.1)`, NewResultInInt(3)},
+		{`round(2.5)`, NewResultInInt(3)},
+		{`round(7)`, NewResultInInt(7)},
+		{`round(12.3456, 2)`, NewResultInFloat(12.35)},
+		{`float(3)`, NewResultInFloat(3)},
+		{`float(" 1.25 ")`, NewResultInFloat(1.25)},
+		{`int(-3.9)`, NewResultInInt(-3)},
+		{`int("12")`, NewResultInInt(12)},
+		{`floor("a")`, NewResultInError("argument to `floor` must be INTEGER or FLOAT, got STRING")},
+		{`float("abc")`, NewResultInError(`could not parse "abc" as float`)},
+		{`int(1e300)`, NewResultInError("result of `int` does not fit in an INTEGER: 1e+300")},
+		{`1.5 + true`, NewResultInError("type mismatch: FLOAT + BOOLEAN")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := DoEval(tt.input)
+			tt.expected.CheckEvaluated(t, evaluated)
+		})
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected CheckEvaluated
+	}{
+		{`1 / 0`, NewResultInError("division by zero")},
+		{`let f = fn(x) { 10 / x }; f(0)`, NewResultInError("division by zero")},
+		{`1.0 / 0 > 1000`, NewResultInBool(true)},
+		{`fn(a, b) { a }(1)`, NewResultInError("wrong number of arguments: want = 2, got = 1")},
+		{`fn() { 1 }(1, 2)`, NewResultInError("wrong number of arguments: want = 0, got = 2")},
+		{`5[0]`, NewResultInError("index operator not supported: INTEGER")},
+		{`quote(unquote(1 + true))`, NewResultInError("type mismatch: INTEGER + BOOLEAN")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := DoEval(tt.input)
+			tt.expected.CheckEvaluated(t, evaluated)
+		})
+	}
+}
+
+func TestEvalContextLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   object.Limits
+		expected object.Limit
+	}{
+		{`while (true) {}`, object.Limits{MaxInstructions: 1000}, object.InstructionLimit},
+		{`let f = fn(n) { f(n + 1) }; f(0)`, object.Limits{MaxDepth: 100}, object.DepthLimit},
+		{`let xs = []; for (i in range(100)) { xs = push(xs, i) }`, object.Limits{MaxAllocations: 50}, object.AllocationLimit},
+		{`let s = "a"; while (true) { s = s + s }`, object.Limits{MaxStringLength: 1000}, object.StringLengthLimit},
+		{`let h = {}; for (i in range(100)) { h[i] = i }`, object.Limits{MaxArrayLength: 10}, object.ArrayLengthLimit},
+		{`push([1, 2, 3], 4)`, object.Limits{MaxArrayLength: 3}, object.ArrayLengthLimit},
+		{`repeat("ab", 1000)`, object.Limits{MaxStringLength: 100}, object.StringLengthLimit},
+		{`chars("abcdef")`, object.Limits{MaxArrayLength: 3}, object.ArrayLengthLimit},
+		{`chars("abcdef")`, object.Limits{MaxAllocations: 3}, object.AllocationLimit},
+		{`split("a,b,c,d", ",")`, object.Limits{MaxArrayLength: 3}, object.ArrayLengthLimit},
+		{`replace("aaaa", "a", "bbbb")`, object.Limits{MaxStringLength: 10}, object.StringLengthLimit},
+		{`join(["abc", "def"], "-")`, object.Limits{MaxStringLength: 5}, object.StringLengthLimit},
+		{`map(range(1000000000), fn(x) { x })`, object.Limits{MaxArrayLength: 100}, object.ArrayLengthLimit},
+		{`sort(range(1000000000))`, object.Limits{MaxArrayLength: 100}, object.ArrayLengthLimit},
+		{`zip(range(100), range(100))`, object.Limits{MaxAllocations: 50}, object.AllocationLimit},
+		{`let h = {}; for (i in range(40)) { h[i] = i }; items(h)`, object.Limits{MaxAllocations: 40}, object.AllocationLimit},
+		{`merge({1: 1, 2: 2}, {2: 2, 3: 3})`, object.Limits{MaxArrayLength: 2}, object.ArrayLengthLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program := parser.New(lexer.New(tt.input)).ParseProgram()
+			_, err := evaluator.EvalContext(context.Background(), program, object.NewEnvironment(), tt.limits)
+
+			var limitErr *object.LimitExceededError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("expected an *object.LimitExceededError, got = %T (%v)", err, err)
+			}
+			if limitErr.Limit != tt.expected {
+				t.Errorf("wrong limit exceeded. got = %q, want = %q", limitErr.Limit, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEvalContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	program := parser.New(lexer.New(`while (true) {}`)).ParseProgram()
+	_, err := evaluator.EvalContext(ctx, program, object.NewEnvironment(), object.Limits{}) //nolint:exhaustruct
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got = %v", err)
+	}
+}
+
+func TestEvalDefaultDepth(t *testing.T) {
+	input := `let f = fn() { f() }; f()`
+
+	evaluated := evaluator.Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "stack overflow" {
+		t.Errorf("expected a stack overflow, got = %T (%+v)", evaluated, evaluated)
+	}
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	_, err := evaluator.EvalContext(context.Background(), program, object.NewEnvironment(), object.Limits{}) //nolint:exhaustruct
+	if !errors.Is(err, object.ErrStackOverflow) {
+		t.Errorf("expected a stack overflow, got = %v", err)
+	}
+}
+
+func TestPuts(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`puts("hello")`, "hello\n"},
+		{`puts("a", 1, 2.5, [true, null])`, "a 1 2.5 [true, null]\n"},
+		{`puts()`, "\n"},
+		{`let f = fn(x) { puts("x =", x) }; f(1); f(2)`, "x = 1\nx = 2\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if _, out := DoEvalOutput(tt.input); out != tt.expected {
+				t.Errorf("wrong output. got = %q, want = %q", out, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected CheckEvaluated
+	}{
+		{`split("a,b,,c", ",")`, NewResultInArray(NewResultInString("a"), NewResultInString("b"), NewResultInString(""), NewResultInString("c"))},
+		{`split("hé", "")`, NewResultInArray(NewResultInString("h"), NewResultInString("é"))},
+		{`join(["a", 1, true], ", ")`, NewResultInString("a, 1, true")},
+		{`join([], "-")`, NewResultInString("")},
+		{`trim("  hi \n")`, NewResultInString("hi")},
+		{`trim("--hi--", "-")`, NewResultInString("hi")},
+		{`upper("héllo")`, NewResultInString("HÉLLO")},
+		{`lower("HeLLo")`, NewResultInString("hello")},
+		{`contains("monkey", "key")`, NewResultInBool(true)},
+		{`contains("monkey", "donkey")`, NewResultInBool(false)},
+		{`index_of("héllo", "llo")`, NewResultInInt(2)},
+		{`index_of("hello", "z")`, NewResultInInt(-1)},
+		{`replace("a-b-c", "-", "+")`, NewResultInString("a+b+c")},
+		{`replace("a-b-c", "-", "+", 1)`, NewResultInString("a+b-c")},
+		{`starts_with("monkey", "mon")`, NewResultInBool(true)},
+		{`ends_with("monkey", "mon")`, NewResultInBool(false)},
+		{`substr("héllo", 1, 3)`, NewResultInString("él")},
+		{`substr("hello", 2)`, NewResultInString("llo")},
+		{`substr("hello", -3, -1)`, NewResultInString("ll")},
+		{`substr("hello", 3, 100)`, NewResultInString("lo")},
+		{`substr("hello", 4, 2)`, NewResultInString("")},
+		{`repeat("ab", 3)`, NewResultInString("ababab")},
+		{`repeat("ab", -1)`, NewResultInError("`repeat` count must not be negative, got -1")},
+		{`chars("a😀")`, NewResultInArray(NewResultInString("a"), NewResultInString("😀"))},
+		{`format("{0} + {0} = {1}", 1, 2)`, NewResultInString("1 + 1 = 2")},
+		{`format("{{{0}}}", [1])`, NewResultInString("{[1]}")},
+		{`format("{1}", 1)`, NewResultInError("`format`: no value for {1}, got 1 values")},
+		{`format("{x}")`, NewResultInError("`format`: invalid placeholder {x}")},
+		{`format("{0")`, NewResultInError("`format`: unclosed { at 0")},
+		{`upper(1)`, NewResultInError("argument to `upper` must be STRING, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := DoEval(tt.input)
+			tt.expected.CheckEvaluated(t, evaluated)
+		})
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	ints := func(values ...int64) CheckEvaluated {
+		elements := make([]CheckEvaluated, len(values))
+		for i, value := range values {
+			elements[i] = NewResultInInt(value)
+		}
+		return NewResultInArray(elements...)
+	}
+
+	tests := []struct {
+		input    string
+		expected CheckEvaluated
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, ints(2, 4, 6)},
+		{`let k = 10; map(range(3), fn(x) { x + k })`, ints(10, 11, 12)},
+		{`map(["ab", "c"], len)`, ints(2, 1)},
+		{`filter(range(10), fn(x) { x / 3 * 3 == x })`, ints(0, 3, 6, 9)},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, NewResultInInt(10)},
+		{`reduce([1, 2, 3], fn(acc, x) { acc * x }, 10)`, NewResultInInt(60)},
+		{`reduce([], fn(acc, x) { acc + x })`, NewResultInNil()},
+		{`sort([3, 1.5, 2])`, NewResultInArray(NewResultInFloat(1.5), NewResultInInt(2), NewResultInInt(3))},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, ints(3, 2, 1)},
+		{`sort([1, "a"])`, NewResultInError("`sort`: cannot compare STRING with INTEGER, a function comparing them is needed")},
+		{`reverse([1, 2, 3])`, ints(3, 2, 1)},
+		{`reverse("hé!")`, NewResultInString("!éh")},
+		{`zip([1, 2, 3], "ab")`, NewResultInArray(
+			NewResultInArray(NewResultInInt(1), NewResultInString("a")),
+			NewResultInArray(NewResultInInt(2), NewResultInString("b")),
+		)},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, NewResultInBool(true)},
+		{`any([])`, NewResultInBool(false)},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, NewResultInBool(true)},
+		{`all([true, null])`, NewResultInBool(false)},
+		{`find([1, 2, 3, 4], fn(x) { x > 1 })`, NewResultInInt(2)},
+		{`find({"a": 1, "b": 2}, fn(p) { p[1] == 2 })[0]`, NewResultInString("b")},
+		{`find([1, 3], fn(x) { x > 3 })`, NewResultInNil()},
+		{`flat_map([1, 2], fn(x) { [x, x] })`, ints(1, 1, 2, 2)},
+		{`flat_map([1], fn(x) { x })`, NewResultInError("`flat_map`: fn must return ARRAY, got INTEGER")},
+		{`unique([1, 2, 1, 3, 2])`, ints(1, 2, 3)},
+		{`unique([[1]])`, NewResultInError("`unique`: unusable as hash key: ARRAY")},
+		{`map([1], fn(x) { x / 0 })`, NewResultInError("division by zero")},
+		{`filter([1], fn(x, y) { x })`, NewResultInError("wrong number of arguments: want = 2, got = 1")},
+		{`map(1, fn(x) { x })`, NewResultInError("first argument to `map` must be ARRAY, RANGE, STRING or HASH, got INTEGER")},
+		{`map([1, 2], fn(x) {})`, NewResultInArray(NewResultInNil(), NewResultInNil())},
+		{`filter([1, 2], fn(x) {})`, NewResultInArray()},
+		{`reduce([1, 2], fn(a, b) {})`, NewResultInNil()},
+		{`find([1], fn(x) {})`, NewResultInNil()},
+		{`sort([2, 1], fn(a, b) {})`, ints(2, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := DoEval(tt.input)
+			tt.expected.CheckEvaluated(t, evaluated)
+		})
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected CheckEvaluated
+	}{
+		{`keys({"b": 1, "a": 2, 3: 3})`, NewResultInArray(NewResultInString("b"), NewResultInString("a"), NewResultInInt(3))},
+		{`values({"b": 1, "a": 2})`, NewResultInArray(NewResultInInt(1), NewResultInInt(2))},
+		{`items({"b": 1})`, NewResultInArray(NewResultInArray(NewResultInString("b"), NewResultInInt(1)))},
+		{`has({"a": 1}, "a")`, NewResultInBool(true)},
+		{`has({"a": 1}, "b")`, NewResultInBool(false)},
+		{`has({}, [])`, NewResultInError("unusable as hash key: ARRAY")},
+		{`let h = {"a": 1, "b": 2}; let d = delete(h, "a"); [keys(d), len(h)]`, NewResultInArray(
+			NewResultInArray(NewResultInString("b")),
+			NewResultInInt(2),
+		)},
+		{`values(merge({"a": 1, "b": 2}, {"c": 3, "a": 4}))`, NewResultInArray(NewResultInInt(4), NewResultInInt(2), NewResultInInt(3))},
+		{`len({"a": 1, "b": 2})`, NewResultInInt(2)},
+		{`let h = {"z": 1}; h["a"] = 2; h["z"] = 3; join(items(h), " ")`, NewResultInString(`[z, 3] [a, 2]`)},
+		{`keys([])`, NewResultInError("argument to `keys` must be HASH, got ARRAY")},
+		{`merge({}, 1)`, NewResultInError("second argument to `merge` must be HASH, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := DoEval(tt.input)
+			tt.expected.CheckEvaluated(t, evaluated)
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"monkey/object"
 	"monkey/parser"
 	"monkey/testutils"
+	"strings"
 	"testing"
 )
 
@@ -16,6 +17,21 @@ func DoEval(input string) object.Object {
 	return evaluator.Eval(program, object.NewEnvironment())
 }
 
+// DoEvalOutput evaluates input as DoEval does, returning what it printed to
+// stdout along with the result.
+func DoEvalOutput(input string) (object.Object, string) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	var out strings.Builder
+	exec := object.DefaultExecContext()
+	exec.Stdout = &out
+
+	env := object.NewEnvironment()
+	env.SetExecContext(exec)
+	return evaluator.Eval(program, env), out.String()
+}
+
 func CheckIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result := testutils.CheckIsA[object.Integer](t, obj, "obj is not object.Integer")
 	if result.Value != expected {
@@ -26,6 +42,16 @@ func CheckIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func CheckFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result := testutils.CheckIsA[object.Float](t, obj, "obj is not object.Float")
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got = %g, expect = %g",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
 func CheckErrorObject(t *testing.T, obj object.Object, expectedMessage string) bool {
 	result := testutils.CheckIsA[object.Error](t, obj, "obj is not object.Error")
 	if result.Message != expectedMessage {
@@ -97,6 +123,30 @@ func (r *ResultInInt) CheckEvaluated(t *testing.T, obj object.Object) bool {
 	return CheckIntegerObject(t, obj, r.n)
 }
 
+type ResultInFloat struct {
+	f float64
+}
+
+func NewResultInFloat(f float64) *ResultInFloat {
+	return &ResultInFloat{f}
+}
+
+func (r *ResultInFloat) CheckEvaluated(t *testing.T, obj object.Object) bool {
+	return CheckFloatObject(t, obj, r.f)
+}
+
+type ResultInBool struct {
+	b bool
+}
+
+func NewResultInBool(b bool) *ResultInBool {
+	return &ResultInBool{b}
+}
+
+func (r *ResultInBool) CheckEvaluated(t *testing.T, obj object.Object) bool {
+	return CheckBooleanObject(t, obj, r.b)
+}
+
 type ResultInError struct {
 	message string
 }
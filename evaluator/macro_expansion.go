@@ -1,7 +1,10 @@
 package evaluator
 
 import (
+	"context"
+
 	"monkey/ast"
+	"monkey/diagnostic"
 	"monkey/object"
 )
 
@@ -49,11 +52,14 @@ func addMacro(node ast.Statement, env *object.Environment) {
 		Body:       macroLiteral.Body(),
 	}
 
-	env.Set(letStatement.Name.Value, macro)
+	env.Define(letStatement.Name.Value, macro)
 }
 
-func ExpandMacros(program *ast.Program, env *object.Environment) ast.Node {
-	node, err := ast.Modify(program, func(node ast.Node) (ast.Node, error) {
+// ExpandMacros replaces every call to a macro defined in env with the code the
+// macro returns. If a macro fails, the error is a diagnostic pointing at the
+// offending call.
+func ExpandMacros(program *ast.Program, env *object.Environment) (ast.Node, error) {
+	return ast.Modify(program, func(node ast.Node) (ast.Node, error) {
 		callExpression, ok := node.(*ast.CallExpression)
 		if !ok {
 			return node, nil
@@ -65,41 +71,75 @@ func ExpandMacros(program *ast.Program, env *object.Environment) ast.Node {
 		}
 
 		args := quoteArgs(callExpression)
+		if len(args) != len(macro.Parameters) {
+			return nil, diagnostic.Errorf(
+				CodeMacroArguments,
+				callExpression.Span(),
+				"wrong number of arguments to macro: want = %d, got = %d", len(macro.Parameters), len(args),
+			)
+		}
+
 		evalEnv := extendMacroEnv(macro, args)
+		// Macros run without limits still have their calls counted, as
+		// programs do.
+		if evalEnv.Budget() == nil {
+			evalEnv.SetBudget(object.NewBudget(context.Background(), object.Limits{})) //nolint:exhaustruct
+		}
 
 		evaluated := Eval(macro.Body, evalEnv)
 
-		quote, ok := evaluated.(*object.Quote)
-		if !ok {
-			panic("we only support returning AST-nodes from macros") // TODO: Change into proper error
+		switch evaluated := evaluated.(type) {
+		case *object.Quote:
+			return evaluated.Node, nil
+		case *object.Error:
+			return nil, diagnostic.Errorf(
+				CodeMacroExpansionFailed,
+				callExpression.Span(),
+				"macro expansion failed: %s", evaluated.Message,
+			)
+		default:
+			return nil, diagnostic.Errorf(
+				CodeMacroResultNotQuote,
+				callExpression.Span(),
+				"macro must return a quote, got %s", evaluated.Type(),
+			).WithNote("wrap the code the macro expands into with `quote(...)`")
 		}
-
-		return quote.Node, nil
 	})
-	if err != nil {
-		panic(err)
-	}
-
-	return node
 }
 
+// isMacroCall reports whether the call is to a macro, which is either defined
+// in env, or imported from a module as in `mod["name"](...)`.
 func isMacroCall(callExpression *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
-	ident, ok := callExpression.Function().(*ast.Identifier)
-	if !ok {
-		return nil, false
-	}
+	var obj object.Object
+	switch function := callExpression.Function().(type) {
+	case *ast.Identifier:
+		obj, _ = env.Get(function.Value)
 
-	obj, ok := env.Get(ident.Value)
-	if !ok {
-		return nil, false
-	}
+	case *ast.IndexExpression:
+		module, ok := function.Left().(*ast.Identifier)
+		if !ok {
+			return nil, false
+		}
+		name, ok := function.Index().(*ast.StringLiteral)
+		if !ok {
+			return nil, false
+		}
 
-	macro, ok := obj.(*object.Macro)
-	if !ok {
-		return nil, false
+		macros, ok := env.Get(module.Value)
+		if !ok {
+			return nil, false
+		}
+		hash, ok := macros.(*object.Hash)
+		if !ok {
+			return nil, false
+		}
+		key, _ := (&object.String{Value: name.Value}).HashKey()
+		pair, _ := hash.Get(key)
+		obj = pair.Value
 	}
 
-	return macro, true
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
 }
 
 func quoteArgs(callExpression *ast.CallExpression) []*object.Quote {
@@ -114,7 +154,7 @@ func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environme
 	extended := macro.Env.NewScoped()
 
 	for paramIdx, param := range macro.Parameters {
-		extended.Set(param.Value, args[paramIdx])
+		extended.Define(param.Value, args[paramIdx])
 	}
 
 	return extended
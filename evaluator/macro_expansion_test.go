@@ -1,7 +1,9 @@
 package evaluator_test
 
 import (
+	"errors"
 	"monkey/ast"
+	"monkey/diagnostic"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
@@ -125,7 +127,10 @@ func TestExpandMacros(t *testing.T) {
 
 			env := object.NewEnvironment()
 			evaluator.DefineMacros(program, env)
-			expanded := evaluator.ExpandMacros(program, env)
+			expanded, err := evaluator.ExpandMacros(program, env)
+			if err != nil {
+				t.Fatalf("unexpected expansion error: %s", err)
+			}
 
 			if expanded.String() != expected.String() {
 				t.Errorf("expanded not equal to expected, got = %q, want = %q", expanded.String(), expected.String())
@@ -133,3 +138,50 @@ func TestExpandMacros(t *testing.T) {
 		})
 	}
 }
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode diagnostic.Code
+		expectedErr  string
+	}{
+		{
+			"let five = macro() { 5 };\nfive();",
+			evaluator.CodeMacroResultNotQuote,
+			"2:5: macro must return a quote, got INTEGER",
+		},
+		{
+			"let broken = macro() { 1 + true };\nbroken();",
+			evaluator.CodeMacroExpansionFailed,
+			"2:7: macro expansion failed: type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"let one = macro(a) { quote(unquote(a)) };\none(1, 2);",
+			evaluator.CodeMacroArguments,
+			"2:4: wrong number of arguments to macro: want = 1, got = 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program := testParseProgram(t, tt.input)
+
+			env := object.NewEnvironment()
+			evaluator.DefineMacros(program, env)
+			_, err := evaluator.ExpandMacros(program, env)
+
+			var d diagnostic.Diagnostic
+			if !errors.As(err, &d) {
+				t.Fatalf("expected a diagnostic, got = %v", err)
+			}
+
+			if d.Code != tt.expectedCode {
+				t.Errorf("wrong code. got = %q, want = %q", d.Code, tt.expectedCode)
+			}
+
+			if d.Error() != tt.expectedErr {
+				t.Errorf("wrong error. got = %q, want = %q", d.Error(), tt.expectedErr)
+			}
+		})
+	}
+}
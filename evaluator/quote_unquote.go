@@ -1,17 +1,21 @@
 package evaluator
 
 import (
+	"errors"
 	"monkey/ast"
 	"monkey/object"
 )
 
 func quote(node ast.Node, env *object.Environment) object.Object {
-	node = evalUnquoteCalls(node, env)
+	node, err := evalUnquoteCalls(node, env)
+	if err != nil {
+		return newError("%s", err)
+	}
 	return &object.Quote{Node: node}
 }
 
-func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
-	resultNode, err := ast.Modify(quoted, func(node ast.Node) (ast.Node, error) {
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, error) {
+	return ast.Modify(quoted, func(node ast.Node) (ast.Node, error) {
 		if !isUnquotedCall(node) {
 			return node, nil
 		}
@@ -26,14 +30,13 @@ func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
 		}
 
 		evalRes := Eval(call.Arguments()[0], env)
+		if errObj, ok := evalRes.(*object.Error); ok {
+			return nil, errors.New(errObj.Message)
+		}
+
 		astNode, err := evalRes.Deval()
 		return astNode, err
 	})
-	if err != nil {
-		panic(err) // Don't know how to match this currently
-	}
-
-	return resultNode
 }
 
 func isUnquotedCall(node ast.Node) bool {
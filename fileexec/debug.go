@@ -0,0 +1,69 @@
+package fileexec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"monkey/compiler"
+	"monkey/debugger"
+	"monkey/object"
+	"os"
+)
+
+// DebugFile runs the script at filepath in the interactive debugger, paused
+// on its first line.
+func DebugFile(filepath string) {
+	bytecode, globals := compileFile(filepath)
+
+	d := debugger.New(bytecode, globals, true)
+	err := debugger.RunCLI(d, os.Stdin, os.Stdout)
+	switch {
+	case errors.Is(err, debugger.ErrStopped):
+		fmt.Fprintf(os.Stdout, "Program stopped.\n")
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Whoop! Failed execution with an error:\n%s\n", err)
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stdout, "Program finished.\n")
+	}
+}
+
+// ServeDAP serves the Debug Adapter Protocol over stdin and stdout, the
+// program being the one the client launches.
+func ServeDAP() {
+	server := debugger.NewDAPServer(os.Stdin, os.Stdout, launch)
+
+	// The protocol takes over stdin and stdout, so what the program prints is
+	// forwarded to the client instead, and it can't read.
+	exec := object.DefaultExecContext()
+	exec.Stdout = outputWriter{server, "stdout"}
+	exec.Stderr = outputWriter{server, "stderr"}
+	exec.Stdin = nil
+	server.SetExecContext(exec)
+
+	if err := server.Serve(); err != nil {
+		fmt.Fprintf(os.Stderr, "debug adapter failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func launch(program string, out io.Writer) (*compiler.Bytecode, []string, bool) {
+	buff, err := os.ReadFile(program)
+	if err != nil {
+		fmt.Fprintf(out, "failed reading file at the given path with an error:\n%v\n", err)
+		return nil, nil, false
+	}
+	return compileTo(out, program, buff)
+}
+
+// outputWriter forwards what's written to it to the client, as output of
+// category.
+type outputWriter struct {
+	server   *debugger.DAPServer
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.server.Output(w.category, string(p))
+	return len(p), nil
+}
@@ -2,48 +2,145 @@
 package fileexec
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"monkey/compiler"
+	"monkey/diagnostic"
 	"monkey/evaluator"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
 	"monkey/vm"
 	"os"
 )
 
-func ExecFileCompiled(filepath string) {
+// ExecFileCompiled compiles and runs the script at filepath. If dump isn't nil,
+// the disassembled bytecode is written to it before running. With debug set,
+// runtime errors come with a dump of the state of the VM.
+func ExecFileCompiled(filepath string, dump io.Writer, debug bool) {
+	bytecode, globals := compileFile(filepath)
+	if dump != nil {
+		compiler.Disassemble(dump, bytecode, globals)
+	}
+
+	runBytecode(bytecode, globals, debug)
+}
+
+// BuildFile compiles the script at filepath, and saves its bytecode to outPath
+// so it can be run later on with RunBytecodeFile.
+func BuildFile(filepath string, outPath string) {
+	bytecode, _ := compileFile(filepath)
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ouch! Failed serializing the bytecode:\n%s\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(outPath, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed writing the bytecode with an error:\n%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// RunBytecodeFile runs the bytecode saved by BuildFile. Source files are
+// compiled on the spot instead. debug is as for ExecFileCompiled.
+func RunBytecodeFile(filepath string, debug bool) {
+	bytecode, globals := loadFile(filepath)
+	runBytecode(bytecode, globals, debug)
+}
+
+// DisassembleFile writes the disassembled bytecode of a script, or of a
+// bytecode file, to out.
+func DisassembleFile(out io.Writer, filepath string) {
+	bytecode, globals := loadFile(filepath)
+	compiler.Disassemble(out, bytecode, globals)
+}
+
+// loadFile loads the bytecode saved at filepath by BuildFile, or compiles it
+// if it's a script. The names of the globals are only known in the latter case.
+func loadFile(filepath string) (*compiler.Bytecode, []string) {
 	buff, err := os.ReadFile(filepath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed reading file at the given path with an error:\n%v\n", err)
 		os.Exit(1)
 	}
 
-	parser := parser.New(lexer.New(string(buff)))
+	if !compiler.IsBytecode(buff) {
+		return compileSource(filepath, buff)
+	}
+
+	bytecode := &compiler.Bytecode{} //nolint:exhaustruct
+	if err := bytecode.UnmarshalBinary(buff); err != nil {
+		fmt.Fprintf(os.Stderr, "failed loading bytecode from %s:\n%v\n", filepath, err)
+		os.Exit(1)
+	}
+
+	return bytecode, nil
+}
+
+func runBytecode(bytecode *compiler.Bytecode, globals []string, debug bool) {
+	machine := vm.New(bytecode)
+	machine.SetGlobalNames(globals)
+	if err := machine.Run(); err != nil {
+		var runErr *vm.VmRunError
+		if debug && errors.As(err, &runErr) {
+			fmt.Fprintf(os.Stderr, "Whoop! Failed execution with an error:\n%s\n", runErr.Dump())
+		} else {
+			fmt.Fprintf(os.Stderr, "Whoop! Failed execution with an error:\n%s\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func compileFile(filepath string) (*compiler.Bytecode, []string) {
+	buff, err := os.ReadFile(filepath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed reading file at the given path with an error:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	return compileSource(filepath, buff)
+}
+
+func compileSource(filepath string, buff []byte) (*compiler.Bytecode, []string) {
+	bytecode, globals, ok := compileTo(os.Stderr, filepath, buff)
+	if !ok {
+		os.Exit(1)
+	}
+	return bytecode, globals
+}
+
+// compileTo compiles the script read from filepath, reporting errors to out.
+func compileTo(out io.Writer, filepath string, buff []byte) (*compiler.Bytecode, []string, bool) {
+	parser := parser.New(lexer.NewWithFilename(filepath, string(buff)))
 	program := parser.ParseProgram()
 	if len(parser.Errors()) > 0 {
-		printParserErrors(os.Stderr, parser.Errors())
+		printParserErrors(out, string(buff), parser.Diagnostics())
 		// If we have errors we cannot reliably continue to evaluate anything.
-		os.Exit(1)
+		return nil, nil, false
 	}
 
 	macroEnv := object.NewEnvironment()
+	loader := module.NewLoader(module.SearchPathsFromEnv()...)
 
-	evaluator.DefineMacros(program, macroEnv)
-	expandedProgram := evaluator.ExpandMacros(program, macroEnv)
+	expandedProgram, err := loader.Expand(program, macroEnv)
+	if err != nil {
+		fmt.Fprintf(out, "Ouch! Failed expanding macros and imports:\n")
+		printError(out, string(buff), err, loader)
+		return nil, nil, false
+	}
 
 	comp := compiler.New()
+	comp.SetModules(compiler.NewModules(loader))
 	if err := comp.Compile(expandedProgram); err != nil {
-		fmt.Fprintf(os.Stderr, "Ouch! Failed compiling program:\n%s\n", err)
-		os.Exit(1)
+		fmt.Fprintf(out, "Ouch! Failed compiling program:\n")
+		printError(out, string(buff), err, loader)
+		return nil, nil, false
 	}
 
-	machine := vm.New(comp.Bytecode())
-	if err := machine.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Whoop! Failed execution with an error:\n%s\n", err)
-		os.Exit(1)
-	}
+	return comp.Bytecode(), comp.GlobalNames(), true
 }
 
 func ExecFileTree(filepath string) {
@@ -53,19 +150,26 @@ func ExecFileTree(filepath string) {
 		os.Exit(1)
 	}
 
-	parser := parser.New(lexer.New(string(buff)))
+	parser := parser.New(lexer.NewWithFilename(filepath, string(buff)))
 	program := parser.ParseProgram()
 	if len(parser.Errors()) > 0 {
-		printParserErrors(os.Stderr, parser.Errors())
+		printParserErrors(os.Stderr, string(buff), parser.Diagnostics())
 		// If we have errors we cannot reliably continue to evaluate anything.
 		os.Exit(1)
 	}
 
-	env := object.NewEnvironment()
 	macroEnv := object.NewEnvironment()
+	loader := module.NewLoader(module.SearchPathsFromEnv()...)
 
-	evaluator.DefineMacros(program, macroEnv)
-	expandedProgram := evaluator.ExpandMacros(program, macroEnv)
+	env := object.NewEnvironment()
+	env.SetImporter(module.NewImporter(loader))
+
+	expandedProgram, err := loader.Expand(program, macroEnv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ouch! Failed expanding macros and imports:\n")
+		printError(os.Stderr, string(buff), err, loader)
+		os.Exit(1)
+	}
 
 	evaluationResult := evaluator.Eval(expandedProgram, env)
 
@@ -74,10 +178,28 @@ func ExecFileTree(filepath string) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	fmt.Fprintf(out, "%s", "Oops! We ran into some monkey business here!\n")
-	fmt.Fprintf(out, "%s", "parser errors:\n")
-	for _, msg := range errors {
-		fmt.Fprintf(out, "%s", "\t"+msg+"\n")
+// printError renders err with its source snippet if it's a diagnostic, or as
+// plain text otherwise. The source of diagnostics found in imported modules is
+// taken from the loader.
+func printError(out io.Writer, source string, err error, loader *module.Loader) {
+	var parseErr *module.ParseError
+	if errors.As(err, &parseErr) {
+		printParserErrors(out, parseErr.Source, parseErr.Diagnostics)
+		return
+	}
+
+	var d diagnostic.Diagnostic
+	if errors.As(err, &d) {
+		if moduleSource, ok := loader.Source(d.Span.File); ok {
+			source = moduleSource
+		}
+		diagnostic.Fprint(out, source, d)
+		return
 	}
+	fmt.Fprintf(out, "%s\n", err)
+}
+
+func printParserErrors(out io.Writer, source string, diagnostics []diagnostic.Diagnostic) {
+	fmt.Fprintf(out, "%s", "Oops! We ran into some monkey business here!\n")
+	diagnostic.FprintAll(out, source, diagnostics)
 }
@@ -0,0 +1,93 @@
+package fileexec
+
+import (
+	"fmt"
+	"io"
+	"monkey/format"
+	"os"
+)
+
+// FormatFiles formats the scripts at paths, or stdin if there are none. The
+// formatted scripts are written to stdout, unless write is set and they're
+// written back to their files instead. With check set, the scripts that
+// aren't formatted are listed rather than formatted, and the process exits
+// with an error if there are any.
+func FormatFiles(paths []string, write bool, check bool) {
+	failed := false
+
+	if len(paths) == 0 {
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed reading stdin with an error:\n%v\n", err)
+			os.Exit(1)
+		}
+		failed = !formatSource("<stdin>", source, func(formatted string) error {
+			_, err := fmt.Fprint(os.Stdout, formatted)
+			return err
+		}, check)
+	}
+
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed reading file at the given path with an error:\n%v\n", err)
+			failed = true
+			continue
+		}
+
+		output := func(formatted string) error {
+			_, err := fmt.Fprint(os.Stdout, formatted)
+			return err
+		}
+		if write {
+			output = func(formatted string) error {
+				if formatted == string(source) {
+					return nil
+				}
+				return writeFile(path, formatted)
+			}
+		}
+
+		if !formatSource(path, source, output, check) {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// formatSource formats the script read from path, passing it to output. With
+// check set, the path is listed instead if the script isn't formatted. It
+// reports whether all went well.
+func formatSource(path string, source []byte, output func(string) error, check bool) bool {
+	formatted, diagnostics := format.Source(path, string(source))
+	if len(diagnostics) > 0 {
+		printParserErrors(os.Stderr, string(source), diagnostics)
+		return false
+	}
+
+	if check {
+		if formatted == string(source) {
+			return true
+		}
+		fmt.Println(path)
+		return false
+	}
+
+	if err := output(formatted); err != nil {
+		fmt.Fprintf(os.Stderr, "failed writing %s with an error:\n%v\n", path, err)
+		return false
+	}
+	return true
+}
+
+// writeFile replaces the content of the file at path, keeping its permissions.
+func writeFile(path string, content string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), info.Mode().Perm())
+}
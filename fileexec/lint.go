@@ -0,0 +1,50 @@
+package fileexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"monkey/diagnostic"
+	"monkey/lint"
+	"os"
+)
+
+// LintFiles lints the scripts at paths, printing what it finds. With asJSON
+// set, the diagnostics are printed as a JSON array rather than rendered
+// against the source. The process exits with an error if anything is found.
+func LintFiles(paths []string, asJSON bool) {
+	all := []diagnostic.Diagnostic{}
+	failed := false
+
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed reading file at the given path with an error:\n%v\n", err)
+			failed = true
+			continue
+		}
+
+		diagnostics := lint.Source(path, string(source))
+		if len(diagnostics) > 0 {
+			failed = true
+		}
+
+		if asJSON {
+			all = append(all, diagnostics...)
+		} else {
+			diagnostic.FprintAll(os.Stdout, string(source), diagnostics)
+		}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(all); err != nil {
+			fmt.Fprintf(os.Stderr, "failed encoding the diagnostics with an error:\n%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
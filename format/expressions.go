@@ -0,0 +1,211 @@
+package format
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+// expression prints e, starting at column on a line indented to depth.
+func (p *printer) expression(e ast.Expression, depth int, column int) string {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		return e.Value
+	case *ast.IntegerLiteral:
+		return e.Token.Literal
+	case *ast.FloatLiteral:
+		return e.Token.Literal
+	case *ast.Boolean:
+		return e.TokenLiteral()
+	case *ast.StringLiteral:
+		return p.stringLiteral(e)
+	case *ast.ImportExpression:
+		return fmt.Sprintf("import(%s)", p.stringLiteral(e.PathLiteral()))
+
+	case *ast.PrefixExpression:
+		return e.Operator + p.operand(e.Right, parser.PREFIX, depth, column+len(e.Operator))
+
+	case *ast.InfixExpression:
+		// Operators are left associative, the right operand needs parentheses
+		// if it binds as tightly.
+		precedence := parser.InfixPrecedence(e.Token.Type)
+		left := p.operand(e.Left, precedence, depth, column) + " " + e.Operator + " "
+		return left + p.operand(e.Right, precedence+1, depth, advance(column, left))
+
+	case *ast.CallExpression:
+		function := p.operand(e.Function(), parser.CALL, depth, column)
+		items := []item{}
+		for _, argument := range e.Arguments() {
+			items = append(items, p.expressionItem(argument))
+		}
+		// A function passed last may span several lines, and still have the
+		// call on a single line.
+		trailing := false
+		if n := len(e.Arguments()); n > 0 {
+			_, trailing = e.Arguments()[n-1].(*ast.FunctionLiteral)
+		}
+		return function + p.list(e.Span().Start.Offset, "(", ")", items, trailing, depth, advance(column, function))
+
+	case *ast.IndexExpression:
+		left := p.operand(e.Left(), parser.CALL, depth, column) + "["
+		return left + p.expression(e.Index(), depth, advance(column, left)) + "]"
+
+	case *ast.ArrayLiteral:
+		items := []item{}
+		for _, element := range e.Elements {
+			items = append(items, p.expressionItem(element))
+		}
+		return p.list(e.Span().Start.Offset, "[", "]", items, false, depth, column)
+
+	case *ast.HashLiteral:
+		return p.hash(e, depth, column)
+
+	case *ast.IfExpression:
+		condition := p.expression(e.Condition(), depth, column+len("if ("))
+		out := fmt.Sprintf("if (%s) %s", condition, p.block(e.Consequence(), depth))
+		if alternative, ok := e.Alternative(); ok {
+			out += " else " + p.block(alternative, depth)
+		}
+		return out
+
+	case *ast.FunctionLiteral:
+		return fmt.Sprintf("fn(%s) %s", parameters(e.Parameters()), p.block(e.Body(), depth))
+
+	case *ast.MacroLiteral:
+		return fmt.Sprintf("macro(%s) %s", parameters(e.Parameters()), p.block(e.Body(), depth))
+	}
+
+	panic(fmt.Sprintf("format: unexpected expression %T", e))
+}
+
+// operand prints e as the operand of an operator, within parentheses if it
+// binds less tightly than precedence.
+func (p *printer) operand(e ast.Expression, precedence parser.Precedence, depth int, column int) string {
+	if precedenceOf(e) < precedence {
+		return "(" + p.expression(e, depth, column+1) + ")"
+	}
+	return p.expression(e, depth, column)
+}
+
+func precedenceOf(e ast.Expression) parser.Precedence {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.InfixPrecedence(e.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	}
+	return parser.INDEX
+}
+
+// stringLiteral prints s as it was written, escapes and all.
+func (p *printer) stringLiteral(s *ast.StringLiteral) string {
+	span := s.Span()
+	if !span.IsValid() || span.End.Offset > len(p.source) {
+		return fmt.Sprintf("%q", s.Value)
+	}
+	return p.source[span.Start.Offset:span.End.Offset]
+}
+
+func parameters(identifiers []*ast.Identifier) string {
+	names := []string{}
+	for _, identifier := range identifiers {
+		names = append(names, identifier.Value)
+	}
+	return strings.Join(names, ", ")
+}
+
+// item is an element of a list.
+type item struct {
+	// offset is where the element starts in the source.
+	offset int
+	// print prints the element, starting at column on a line indented to
+	// depth.
+	print func(depth int, column int) string
+}
+
+func (p *printer) expressionItem(e ast.Expression) item {
+	return item{start(e), func(depth int, column int) string {
+		return p.expression(e, depth, column)
+	}}
+}
+
+func (p *printer) hash(h *ast.HashLiteral, depth int, column int) string {
+	items := []item{}
+	for _, key := range h.Keys() {
+		key, value := key, h.Pairs()[key]
+		items = append(items, item{start(key), func(depth int, column int) string {
+			prefix := p.expression(key, depth, column) + ": "
+			return prefix + p.expression(value, depth, advance(column, prefix))
+		}})
+	}
+	return p.list(h.Span().Start.Offset, "{", "}", items, false, depth, column)
+}
+
+// list prints items between open and close, the brackets at offset, on a
+// single line if they fit. Otherwise each is on its own line, followed by a
+// comma. With trailing set, the last item may span several lines and still
+// leave the others on one. Lists with comments are always broken, to keep
+// the comments by the items they're next to.
+func (p *printer) list(at int, open string, close string, items []item, trailing bool, depth int, column int) string {
+	comments := p.comments[at]
+	if len(comments) > 0 {
+		return p.brokenList(open, close, items, comments, depth)
+	}
+	if len(items) == 0 {
+		return open + close
+	}
+
+	printed := []string{}
+	next := column + len(open)
+	for i, item := range items {
+		s := item.print(depth, next)
+		if strings.Contains(s, "\n") && (!trailing || i < len(items)-1) {
+			printed = nil
+			break
+		}
+		printed = append(printed, s)
+		next = advance(next, s+", ")
+	}
+	if printed != nil {
+		if single := open + strings.Join(printed, ", ") + close; fits(single, column) {
+			return single
+		}
+	}
+	return p.brokenList(open, close, items, nil, depth)
+}
+
+// brokenList prints items between open and close, each on its own line and
+// followed by a comma, along with the comments of the list: those following
+// an item on its line stay after it, the others get lines of their own.
+func (p *printer) brokenList(open string, close string, items []item, comments []token.Token, depth int) string {
+	indent := strings.Repeat(indentation, depth+1)
+	lines := []string{open}
+	// Whether the last line ends with a comment, which nothing can follow.
+	commented := false
+
+	addComments := func(before int) {
+		for len(comments) > 0 && comments[0].Span.Start.Offset < before {
+			comment := comments[0]
+			comments = comments[1:]
+			if len(lines) > 1 && !commented && p.followsCode(comment) {
+				lines[len(lines)-1] += " " + comment.Literal
+			} else {
+				lines = append(lines, indent+comment.Literal)
+			}
+			commented = true
+		}
+	}
+
+	for _, item := range items {
+		addComments(item.offset)
+		lines = append(lines, indent+item.print(depth+1, len(indent))+",")
+		commented = false
+	}
+	addComments(len(p.source))
+
+	return strings.Join(lines, "\n") + "\n" + strings.Repeat(indentation, depth) + close
+}
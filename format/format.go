@@ -0,0 +1,278 @@
+// Package format prints monkey programs in a canonical style, keeping their
+// comments and the blank lines between their statements.
+//
+// Blocks are indented by four spaces and statements end with a semicolon,
+// but for if expressions. Arrays, hashes and arguments that don't fit in
+// Width columns are broken into one element per line, each followed by a
+// comma. Nothing else is broken up: lines with long parameter lists or
+// chains of operators stay as long as they are. The pairs of hashes keep the
+// order they were written in.
+package format
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/diagnostic"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// Width is how many columns the lines holding arrays, hashes and arguments
+// are kept within, if they can be.
+const Width = 100
+
+const indentation = "    "
+
+// Source formats the program read from the file called filename. Programs
+// that don't parse are returned as they are, along with why.
+func Source(filename string, source string) (string, []diagnostic.Diagnostic) {
+	l := lexer.NewWithFilename(filename, source)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if diagnostic.HasErrors(p.Diagnostics()) {
+		return source, p.Diagnostics()
+	}
+
+	lines := newPrinter(source, program, l.Comments()).statements(program.Statements, -1, 0)
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+type printer struct {
+	source string
+
+	// comments are the comments directly within each block, or each list of
+	// arguments, elements or pairs, by the offset of the bracket opening it.
+	// Those out of any are the program's, at -1.
+	comments map[int][]token.Token
+}
+
+func newPrinter(source string, program *ast.Program, comments []token.Token) *printer {
+	closing := matchBrackets(source)
+
+	// The spans of blocks and lists start at their opening bracket.
+	blocks := []int{}
+	var visit ast.ModifierFunc
+	visit = func(node ast.Node) (ast.Node, error) {
+		switch node := node.(type) {
+		case *ast.BlockStatement, *ast.CallExpression, *ast.ArrayLiteral, *ast.HashLiteral:
+			blocks = append(blocks, node.Span().Start.Offset)
+		case *ast.MacroLiteral:
+			// Modify doesn't walk into macros.
+			_, _ = ast.Modify(node.Body(), visit)
+		}
+		return node, nil
+	}
+	_, _ = ast.Modify(program, visit)
+
+	p := &printer{source: source, comments: map[int][]token.Token{}}
+	for _, comment := range comments {
+		offset := comment.Span.Start.Offset
+
+		owner := -1
+		for _, block := range blocks {
+			if block < offset && offset < closing[block] && block > owner {
+				owner = block
+			}
+		}
+		p.comments[owner] = append(p.comments[owner], comment)
+	}
+	return p
+}
+
+// matchBrackets returns the offset of the bracket closing each "{", "[" and
+// "(" of source, by the offset of the latter.
+func matchBrackets(source string) map[int]int {
+	pairs := map[token.TokenType]token.TokenType{
+		token.RBRACE:   token.LBRACE,
+		token.RBRACKET: token.LBRACKET,
+		token.RPAREN:   token.LPAREN,
+	}
+	closing := map[int]int{}
+	open := []token.Token{}
+
+	l := lexer.New(source)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LBRACE, token.LBRACKET, token.LPAREN:
+			open = append(open, tok)
+		case token.RBRACE, token.RBRACKET, token.RPAREN:
+			if len(open) > 0 && open[len(open)-1].Type == pairs[tok.Type] {
+				closing[open[len(open)-1].Span.Start.Offset] = tok.Span.Start.Offset
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return closing
+}
+
+// statements prints the statements of the block opening at offset block (-1
+// for the program) one after the other, along with the comments of the
+// block. The lines are indented to depth, blank ones are empty.
+func (p *printer) statements(statements []ast.Statement, block int, depth int) []string {
+	rendered := make([]string, len(statements))
+	for i, stmt := range statements {
+		rendered[i] = p.statement(stmt, depth)
+	}
+	// An if expression followed by something that could continue it, as a
+	// call or an index would, needs its semicolon.
+	for i, stmt := range statements {
+		if _, ok := stmt.(*ast.ExpressionStatement); ok && i+1 < len(rendered) && !strings.HasSuffix(rendered[i], ";") &&
+			strings.ContainsAny(rendered[i+1][:1], "([-") {
+			rendered[i] += ";"
+		}
+	}
+
+	indent := strings.Repeat(indentation, depth)
+	lines := []string{}
+	// Whether the last line ends with a comment, which nothing can follow.
+	commented := false
+
+	add := func(offset int, text string) {
+		if len(lines) > 0 && p.blankLineBefore(offset) {
+			lines = append(lines, "")
+		}
+		lines = append(lines, indent+text)
+	}
+	addComment := func(comment token.Token) {
+		if len(lines) > 0 && !commented && lines[len(lines)-1] != "" && p.followsCode(comment) {
+			lines[len(lines)-1] += " " + comment.Literal
+		} else {
+			add(comment.Span.Start.Offset, comment.Literal)
+		}
+		commented = true
+	}
+
+	comments := p.comments[block]
+	for i, stmt := range statements {
+		offset := start(stmt)
+		for len(comments) > 0 && comments[0].Span.Start.Offset < offset {
+			addComment(comments[0])
+			comments = comments[1:]
+		}
+
+		add(offset, rendered[i])
+		commented = false
+	}
+	for _, comment := range comments {
+		addComment(comment)
+	}
+
+	return lines
+}
+
+func (p *printer) statement(stmt ast.Statement, depth int) string {
+	column := depth * len(indentation)
+
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		prefix := fmt.Sprintf("let %s = ", stmt.Name.Value)
+		return prefix + p.expression(stmt.Value, depth, column+len(prefix)) + ";"
+
+	case *ast.ReturnStatement:
+		return "return " + p.expression(stmt.ReturnValue, depth, column+len("return ")) + ";"
+
+	case *ast.AssignStatement:
+		prefix := fmt.Sprintf("%s %s ", p.expression(stmt.Target, depth, column), stmt.Operator)
+		return prefix + p.expression(stmt.Value, depth, advance(column, prefix)) + ";"
+
+	case *ast.ExpressionStatement:
+		expression := p.expression(stmt.Expression, depth, column)
+		if _, ok := stmt.Expression.(*ast.IfExpression); ok {
+			return expression
+		}
+		return expression + ";"
+
+	case *ast.WhileStatement:
+		condition := p.expression(stmt.Condition(), depth, column+len("while ("))
+		return fmt.Sprintf("while (%s) %s", condition, p.block(stmt.Body(), depth))
+
+	case *ast.ForStatement:
+		prefix := fmt.Sprintf("for (%s in ", stmt.Variable().Value)
+		iterable := p.expression(stmt.Iterable(), depth, column+len(prefix))
+		return fmt.Sprintf("%s%s) %s", prefix, iterable, p.block(stmt.Body(), depth))
+
+	case *ast.BreakStatement:
+		return "break;"
+
+	case *ast.ContinueStatement:
+		return "continue;"
+	}
+
+	panic(fmt.Sprintf("format: unexpected statement %T", stmt))
+}
+
+func (p *printer) block(block *ast.BlockStatement, depth int) string {
+	lines := p.statements(block.Statements(), block.Span().Start.Offset, depth+1)
+	if len(lines) == 0 {
+		return "{}"
+	}
+	return "{\n" + strings.Join(lines, "\n") + "\n" + strings.Repeat(indentation, depth) + "}"
+}
+
+// blankLineBefore reports whether there's a blank line right before offset.
+func (p *printer) blankLineBefore(offset int) bool {
+	newlines := 0
+	for i := offset - 1; i >= 0; i-- {
+		switch p.source[i] {
+		case '\n':
+			newlines++
+		case ' ', '\t', '\r':
+		default:
+			return newlines > 1
+		}
+	}
+	return false
+}
+
+// followsCode reports whether comment comes after some code on its line, and
+// so comments on it.
+func (p *printer) followsCode(comment token.Token) bool {
+	for i := comment.Span.Start.Offset - 1; i >= 0 && p.source[i] != '\n'; i-- {
+		if p.source[i] != ' ' && p.source[i] != '\t' {
+			return true
+		}
+	}
+	return false
+}
+
+// start returns the offset in the source where node starts, which isn't
+// always that of its span.
+func start(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.AssignStatement:
+		return start(node.Target)
+	case *ast.InfixExpression:
+		return start(node.Left)
+	case *ast.CallExpression:
+		return start(node.Function())
+	case *ast.IndexExpression:
+		return start(node.Left())
+	}
+	return node.Span().Start.Offset
+}
+
+// advance returns the column after s, if s starts at column.
+func advance(column int, s string) int {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return utf8.RuneCountInString(s[i+1:])
+	}
+	return column + utf8.RuneCountInString(s)
+}
+
+// fits reports whether all the lines of s are within Width, if s starts at
+// column.
+func fits(s string, column int) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if column+utf8.RuneCountInString(line) > Width {
+			return false
+		}
+		column = 0
+	}
+	return true
+}
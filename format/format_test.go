@@ -0,0 +1,146 @@
+package format_test
+
+import (
+	"monkey/format"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"statements",
+			"let x=1\nlet y = x*2+3\nreturn y",
+			"let x = 1;\nlet y = x * 2 + 3;\nreturn y;\n",
+		},
+		{
+			"parentheses are kept where needed only",
+			"((a + b)) * (c - (d - e)) - ((f - g) - h); -(a + b); (-a)(1); (fn(x) { x })(2)",
+			"(a + b) * (c - (d - e)) - (f - g - h);\n-(a + b);\n(-a)(1);\nfn(x) {\n    x;\n}(2);\n",
+		},
+		{
+			"literals are kept as written",
+			"let s = \"a\\tb\" + `raw`; let f = 1.50; let i = import(\"lib.monkey\");",
+			"let s = \"a\\tb\" + `raw`;\nlet f = 1.50;\nlet i = import(\"lib.monkey\");\n",
+		},
+		{
+			"blocks",
+			"let f = fn(a,b) { if (a) { b } else { while (b) { b -= 1; break } } }; for (x in [1]) {}",
+			"let f = fn(a, b) {\n" +
+				"    if (a) {\n" +
+				"        b;\n" +
+				"    } else {\n" +
+				"        while (b) {\n" +
+				"            b -= 1;\n" +
+				"            break;\n" +
+				"        }\n" +
+				"    }\n" +
+				"};\n" +
+				"for (x in [1]) {}\n",
+		},
+		{
+			"an if needs its semicolon before what would continue it",
+			"if (a) { 1 }; [1, 2]; if (b) { 2 } let c = 3;",
+			"if (a) {\n    1;\n};\n[1, 2];\nif (b) {\n    2;\n}\nlet c = 3;\n",
+		},
+		{
+			"hashes keep their order",
+			`{"z": 1, "a": 2, 3: "m", true: fn() { 1 }}`,
+			"{\n    \"z\": 1,\n    \"a\": 2,\n    3: \"m\",\n    true: fn() {\n        1;\n    },\n};\n",
+		},
+		{
+			"long lists are broken",
+			`let long = ["aaaaaaaaaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbbbbbbb", "ccccccccccccccccccccccccc", {"d": 1}];` + "\n" +
+				`puts(long, "aaaaaaaaaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbbbbbbb", "ccccccccccccccccccccccccc", [1,2]);`,
+			"let long = [\n" +
+				"    \"aaaaaaaaaaaaaaaaaaaaaaaaa\",\n" +
+				"    \"bbbbbbbbbbbbbbbbbbbbbbbbb\",\n" +
+				"    \"ccccccccccccccccccccccccc\",\n" +
+				"    {\"d\": 1},\n" +
+				"];\n" +
+				"puts(\n" +
+				"    long,\n" +
+				"    \"aaaaaaaaaaaaaaaaaaaaaaaaa\",\n" +
+				"    \"bbbbbbbbbbbbbbbbbbbbbbbbb\",\n" +
+				"    \"ccccccccccccccccccccccccc\",\n" +
+				"    [1, 2],\n" +
+				");\n",
+		},
+		{
+			"a function passed last keeps the call on a line",
+			"map(xs, fn(x) { x * 2 }); reduce(fn(a, b) { a + b }, 0);",
+			"map(xs, fn(x) {\n    x * 2;\n});\nreduce(\n    fn(a, b) {\n        a + b;\n    },\n    0,\n);\n",
+		},
+		{
+			"comments and blank lines",
+			"// header\n\n\n/* about x */\nlet x = 1; // one\n\nlet f = fn() { // no arguments\n  x /* x */\n\n  // done\n};\n// the end\n",
+			"// header\n\n/* about x */\nlet x = 1; // one\n\nlet f = fn() {\n    // no arguments\n    x; /* x */\n\n    // done\n};\n// the end\n",
+		},
+		{
+			"comments within lists stay by their items",
+			"let a = [1, // one\n  fn() { 2 }]; // list\nlet b = 2;",
+			"let a = [\n    1, // one\n    fn() {\n        2;\n    },\n]; // list\nlet b = 2;\n",
+		},
+		{
+			"comments within arguments and hashes",
+			"puts(\n  // first\n  1, /* two */ 2, // second\n  3\n  // last\n);\n" +
+				"let h = {\"a\": 1, // a\n  \"b\": f(x, // x\n  y)};\nf( // nothing\n);",
+			"puts(\n    // first\n    1, /* two */\n    2, // second\n    3,\n    // last\n);\n" +
+				"let h = {\n    \"a\": 1, // a\n    \"b\": f(\n        x, // x\n        y,\n    ),\n};\nf(\n    // nothing\n);\n",
+		},
+		{
+			"nothing",
+			"  \n\n",
+			"",
+		},
+		{
+			"only comments",
+			"// a\n\n// b",
+			"// a\n\n// b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted, diagnostics := format.Source("", tt.input)
+			if len(diagnostics) != 0 {
+				t.Fatalf("unexpected diagnostics: %v", diagnostics)
+			}
+			if formatted != tt.expected {
+				t.Fatalf("wrong output.\n got = %q\nwant = %q", formatted, tt.expected)
+			}
+
+			again, _ := format.Source("", formatted)
+			if again != formatted {
+				t.Errorf("formatting is not idempotent.\n got = %q\nwant = %q", again, formatted)
+			}
+
+			if !strings.Contains(tt.input, "{\"") {
+				before, _ := parser.Parse(tt.input)
+				after, _ := parser.Parse(formatted)
+				if before.String() != after.String() {
+					t.Errorf("formatting changed the program.\n got = %s\nwant = %s", after, before)
+				}
+			}
+		})
+	}
+}
+
+func TestSourceThatDoesNotParse(t *testing.T) {
+	input := "let = 1;"
+	formatted, diagnostics := format.Source("script.monkey", input)
+	if len(diagnostics) == 0 {
+		t.Fatalf("expected diagnostics")
+	}
+	if formatted != input {
+		t.Errorf("expected the source back. got = %q", formatted)
+	}
+	if diagnostics[0].Span.File != "script.monkey" {
+		t.Errorf("wrong file. got = %q", diagnostics[0].Span.File)
+	}
+}
@@ -0,0 +1,11 @@
+package lexer
+
+import "monkey/diagnostic"
+
+// Codes of the diagnostics reported by the lexer.
+const (
+	CodeUnterminatedBlockComment diagnostic.Code = "L0001"
+	CodeUnterminatedString       diagnostic.Code = "L0002"
+	CodeUnknownEscape            diagnostic.Code = "L0003"
+	CodeInvalidUnicodeEscape     diagnostic.Code = "L0004"
+)
@@ -1,18 +1,37 @@
 package lexer
 
 import (
+	"monkey/diagnostic"
 	"monkey/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
+	file         string // name of the file the input came from, used for spans only
 	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	position     int  // current position in input, in bytes (points to current char)
+	readPosition int  // current reading position in input, in bytes (after current char)
+	ch           rune // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char, counted in chars rather than bytes
+
+	// comments is the trivia channel: comments never make it into the token
+	// stream the parser sees, but are kept here for tools that need them.
+	comments    []token.Token
+	diagnostics []diagnostic.Diagnostic
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input} //nolint:exhaustruct
+	return NewWithFilename("", input)
+}
+
+// NewWithFilename creates a lexer whose tokens carry spans pointing into the
+// given file.
+func NewWithFilename(filename string, input string) *Lexer {
+	l := &Lexer{file: filename, input: input, line: 1} //nolint:exhaustruct
 	l.readChar()
 	return l
 }
@@ -24,35 +43,110 @@ func (l *Lexer) isOverflow() bool {
 
 // Consume the current token and move on to the next one.
 // If there are no more tokens available, the current reading
-// byte will be of value ” (EOF).
+// char will be of value 0 (EOF).
+//
+// The input is decoded as UTF-8. Invalid bytes are read one at a time, as
+// utf8.RuneError.
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
+	width := 1
 	if l.isOverflow() {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.isOverflow() {
 		return 0
 	}
-	return l.input[l.readPosition]
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return ch
 }
 
-func newToken(tokenType token.TokenType, literal byte) token.Token {
+// peekCharAt returns the char n chars after the next one, so that
+// peekCharAt(0) is the same as peekChar().
+func (l *Lexer) peekCharAt(n int) rune {
+	position := l.readPosition
+	for ; n > 0 && position < len(l.input); n-- {
+		_, width := utf8.DecodeRuneInString(l.input[position:])
+		position += width
+	}
+
+	if position >= len(l.input) {
+		return 0
+	}
+	ch, _ := utf8.DecodeRuneInString(l.input[position:])
+	return ch
+}
+
+func newToken(tokenType token.TokenType, literal rune) token.Token {
 	return token.Token{
 		Type:    tokenType,
 		Literal: string(literal),
 	}
 }
 
+// Comments returns every comment read so far, in source order.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// Diagnostics returns the problems found while reading the input so far.
+func (l *Lexer) Diagnostics() []diagnostic.Diagnostic {
+	return l.diagnostics
+}
+
+// newTwoCharToken reads a token made of the current char and the one after it.
+func (l *Lexer) newTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	return token.Token{
+		Type:    tokenType,
+		Literal: string(ch) + string(l.ch),
+	}
+}
+
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	l.skipTrivia()
+
+	start := l.currentPosition()
+	tok := l.nextToken()
+
+	end := l.currentPosition()
+	if tok.Type == token.EOF {
+		// the lexer keeps "reading" past the end of the input, but there is
+		// nothing there for the EOF token to span over.
+		end = start
+	}
+
+	tok.Span = token.Span{
+		File:  l.file,
+		Start: start,
+		End:   end,
+	}
+
+	return tok
+}
 
-	l.skipWhitespace()
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Offset: l.position,
+		Line:   l.line,
+		Column: l.column,
+	}
+}
+
+func (l *Lexer) nextToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
@@ -68,9 +162,17 @@ func (l *Lexer) NextToken() token.Token {
 		}
 
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -83,9 +185,17 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '*':
-		tok = newToken(token.ASTERIX, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.ASTERIX_ASSIGN)
+		} else {
+			tok = newToken(token.ASTERIX, l.ch)
+		}
 	case '<':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -128,7 +238,18 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch)
 	case '"':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		literal, terminated := l.readString()
+		tok.Literal = literal
+		if !terminated {
+			return tok
+		}
+	case '`':
+		tok.Type = token.STRING
+		literal, terminated := l.readRawString()
+		tok.Literal = literal
+		if !terminated {
+			return tok
+		}
 
 	case 0:
 		tok.Literal = ""
@@ -139,11 +260,15 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			// Using the raw input rather than l.ch, which for invalid UTF-8
+			// would be the replacement character instead of what's actually there.
+			tok = token.Token{
+				Type:    token.ILLEGAL,
+				Literal: l.input[l.position:l.readPosition],
+			}
 		}
 	}
 	l.readChar()
@@ -151,16 +276,145 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
-	for {
-		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+// readString reads a double quoted string, processing its escape sequences.
+// It stops on the closing quote, and reports whether one was found at all.
+func (l *Lexer) readString() (string, bool) {
+	start := l.currentPosition()
+	var out strings.Builder
+
+	l.readChar()
+	for l.ch != '"' {
+		switch l.ch {
+		case 0:
+			l.pushUnterminatedStringError(start, `"`)
+			return out.String(), false
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
+			l.readChar()
 		}
 	}
 
-	return l.input[position:l.position]
+	return out.String(), true
+}
+
+// readRawString reads a backtick quoted string. Raw strings have no escape
+// sequences and may span multiple lines, same as in Go. Also same as in Go,
+// carriage returns are dropped so the value doesn't depend on the line endings
+// of the file.
+func (l *Lexer) readRawString() (string, bool) {
+	start := l.currentPosition()
+	var out strings.Builder
+
+	l.readChar()
+	for l.ch != '`' {
+		if l.ch == 0 {
+			l.pushUnterminatedStringError(start, "`")
+			return out.String(), false
+		}
+
+		if l.ch != '\r' {
+			out.WriteRune(l.ch)
+		}
+		l.readChar()
+	}
+
+	return out.String(), true
+}
+
+var simpleEscapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+}
+
+// readEscape reads an escape sequence starting at the current backslash, and
+// writes the char it stands for. It leaves the lexer on the char right after
+// the sequence.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	start := l.currentPosition()
+	l.readChar()
+
+	if ch, ok := simpleEscapes[l.ch]; ok {
+		out.WriteRune(ch)
+		l.readChar()
+		return
+	}
+
+	switch l.ch {
+	case 'u':
+		l.readChar()
+		l.readUnicodeEscape(start, out)
+	case 0:
+		// Left for the string to report as unterminated.
+	default:
+		l.readChar()
+		l.pushError(
+			diagnostic.Errorf(
+				CodeUnknownEscape,
+				l.spanFrom(start),
+				`unknown escape sequence "%s"`, l.input[start.Offset:l.position],
+			).WithNote(`supported escapes are \n, \t, \r, \0, \\, \" and \u{...}`),
+		)
+	}
+}
+
+// readUnicodeEscape reads the "{1F600}" part of a "\u{1F600}" escape.
+func (l *Lexer) readUnicodeEscape(start token.Position, out *strings.Builder) {
+	invalid := func() {
+		l.pushError(diagnostic.Errorf(
+			CodeInvalidUnicodeEscape,
+			l.spanFrom(start),
+			`invalid unicode escape "%s"`, l.input[start.Offset:l.position],
+		).WithNote("unicode escapes are written as \\u{...} with 1 to 6 hex digits, e.g. \\u{1F600}"))
+	}
+
+	if l.ch != '{' {
+		invalid()
+		return
+	}
+	l.readChar()
+
+	digitsStart := l.position
+	for isHexDigit(l.ch) {
+		l.readChar()
+	}
+	digits := l.input[digitsStart:l.position]
+
+	if l.ch != '}' {
+		invalid()
+		return
+	}
+	l.readChar()
+
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(value)) {
+		invalid()
+		return
+	}
+
+	out.WriteRune(rune(value))
+}
+
+func (l *Lexer) pushUnterminatedStringError(start token.Position, quote string) {
+	l.pushError(diagnostic.Errorf(
+		CodeUnterminatedString,
+		token.Span{File: l.file, Start: start, End: l.advance(start, 1)},
+		"unterminated string literal",
+	).WithNote("the string starting here is never closed with a matching %s", quote))
+}
+
+func (l *Lexer) pushError(d diagnostic.Diagnostic) {
+	l.diagnostics = append(l.diagnostics, d)
+}
+
+// spanFrom returns the span from start up to (excluding) the current char.
+func (l *Lexer) spanFrom(start token.Position) token.Span {
+	return token.Span{File: l.file, Start: start, End: l.currentPosition()}
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -171,17 +425,44 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer, or a float if it's followed by a fraction
+// (`1.5`) and/or an exponent (`1e-3`). A dot or an `e` that isn't followed by
+// digits is left alone, to be lexed as a token of its own.
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekCharAt(0)) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekCharAt(0)
+		if isDigit(next) || (next == '+' || next == '-') && isDigit(l.peekCharAt(1)) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return tokenType, l.input[position:l.position]
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
-func isLetter(ch byte) bool {
-	// Must change in order to support unicode stuff, right?
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
 // Skip forward all of the following whitespaces until a non-whitespace character
@@ -192,6 +473,77 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func isDigit(ch byte) bool {
+// Skip forward all of the whitespaces and comments in front of the next token,
+// recording the comments on the way.
+func (l *Lexer) skipTrivia() {
+	for {
+		l.skipWhitespace()
+
+		switch {
+		case l.ch == '/' && l.peekChar() == '/':
+			l.readLineComment()
+		case l.ch == '/' && l.peekChar() == '*':
+			l.readBlockComment()
+		default:
+			return
+		}
+	}
+}
+
+// readLineComment reads a "//" comment up to, but not including, the end of the line.
+func (l *Lexer) readLineComment() {
+	start := l.currentPosition()
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	l.pushComment(start)
+}
+
+// readBlockComment reads a "/* */" comment. Block comments don't nest.
+func (l *Lexer) readBlockComment() {
+	start := l.currentPosition()
+
+	// Skipping the opening "/*", so that "/*/" isn't mistaken for a whole comment.
+	l.readChar()
+	l.readChar()
+
+	for !(l.ch == '*' && l.peekChar() == '/') {
+		if l.ch == 0 {
+			l.pushComment(start)
+			l.pushError(diagnostic.Errorf(
+				CodeUnterminatedBlockComment,
+				token.Span{File: l.file, Start: start, End: l.advance(start, 2)},
+				"unterminated block comment",
+			).WithNote("block comments are closed with `*/`"))
+			return
+		}
+		l.readChar()
+	}
+
+	l.readChar()
+	l.readChar()
+	l.pushComment(start)
+}
+
+func (l *Lexer) pushComment(start token.Position) {
+	end := l.currentPosition()
+	l.comments = append(l.comments, token.Token{
+		Type:    token.COMMENT,
+		Literal: l.input[start.Offset:end.Offset],
+		Span:    token.Span{File: l.file, Start: start, End: end},
+	})
+}
+
+// advance returns the position n chars after pos, assuming that they're all on
+// the same line.
+func (l *Lexer) advance(pos token.Position, n int) token.Position {
+	return token.Position{Offset: pos.Offset + n, Line: pos.Line, Column: pos.Column + n}
+}
+
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
@@ -1,6 +1,7 @@
 package lexer_test
 
 import (
+	"monkey/diagnostic"
 	"monkey/lexer"
 	"monkey/token"
 	"testing"
@@ -14,7 +15,7 @@ let add = fn(x, y) {
 };
 let result = add(five, ten);
 
-!-/*5
+!-/ *5
 5 < 10 > 5
 
 if (5 < 10) {
@@ -153,3 +154,294 @@ macro(x, y) { x + y; };
 		}
 	}
 }
+
+func TestTokenSpans(t *testing.T) {
+	input := "let x = 10;\n  x >= \"ab\""
+
+	tests := []struct {
+		expectedType  token.TokenType
+		expectedStart token.Position
+		expectedEnd   token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{token.SEMICOLON, token.Position{Offset: 10, Line: 1, Column: 11}, token.Position{Offset: 11, Line: 1, Column: 12}},
+		{token.IDENT, token.Position{Offset: 14, Line: 2, Column: 3}, token.Position{Offset: 15, Line: 2, Column: 4}},
+		{token.GT_EQ, token.Position{Offset: 16, Line: 2, Column: 5}, token.Position{Offset: 18, Line: 2, Column: 7}},
+		{token.STRING, token.Position{Offset: 19, Line: 2, Column: 8}, token.Position{Offset: 23, Line: 2, Column: 12}},
+		{token.EOF, token.Position{Offset: 23, Line: 2, Column: 12}, token.Position{Offset: 23, Line: 2, Column: 12}},
+	}
+
+	l := lexer.NewWithFilename("script.monkey", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - wrong type. expected = %q, got = %q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Span.File != "script.monkey" {
+			t.Errorf("tests[%d] - wrong file. got = %q", i, tok.Span.File)
+		}
+		if tok.Span.Start != tt.expectedStart {
+			t.Errorf("tests[%d] - wrong start. expected = %+v, got = %+v", i, tt.expectedStart, tok.Span.Start)
+		}
+		if tok.Span.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - wrong end. expected = %+v, got = %+v", i, tt.expectedEnd, tok.Span.End)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing comment
+/* block
+   comment */ let y = x /* inline */ / 2;
+//`
+
+	expectedTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENT, "y"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := lexer.New(input)
+	for i, tt := range expectedTokens {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected = %s %q, got = %s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	expectedComments := []struct {
+		literal string
+		start   token.Position
+		end     token.Position
+	}{
+		{"// leading comment", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 18, Line: 1, Column: 19}},
+		{"// trailing comment", token.Position{Offset: 30, Line: 2, Column: 12}, token.Position{Offset: 49, Line: 2, Column: 31}},
+		{"/* block\n   comment */", token.Position{Offset: 50, Line: 3, Column: 1}, token.Position{Offset: 72, Line: 4, Column: 14}},
+		{"/* inline */", token.Position{Offset: 83, Line: 4, Column: 25}, token.Position{Offset: 95, Line: 4, Column: 37}},
+		{"//", token.Position{Offset: 101, Line: 5, Column: 1}, token.Position{Offset: 103, Line: 5, Column: 3}},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("wrong number of comments. expected = %d, got = %d (%v)", len(expectedComments), len(comments), comments)
+	}
+
+	for i, tt := range expectedComments {
+		c := comments[i]
+		if c.Type != token.COMMENT {
+			t.Errorf("comments[%d] - wrong type. got = %q", i, c.Type)
+		}
+		if c.Literal != tt.literal {
+			t.Errorf("comments[%d] - wrong literal. expected = %q, got = %q", i, tt.literal, c.Literal)
+		}
+		if c.Span.Start != tt.start || c.Span.End != tt.end {
+			t.Errorf("comments[%d] - wrong span. expected = %+v-%+v, got = %+v-%+v", i, tt.start, tt.end, c.Span.Start, c.Span.End)
+		}
+	}
+
+	if len(l.Diagnostics()) != 0 {
+		t.Errorf("expected no diagnostics, got %v", l.Diagnostics())
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := lexer.NewWithFilename("script.monkey", "let x = 1;\n  /* never closed")
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	diagnostics := l.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected a single diagnostic, got %v", diagnostics)
+	}
+
+	d := diagnostics[0]
+	if d.Code != lexer.CodeUnterminatedBlockComment {
+		t.Errorf("wrong code. got = %q", d.Code)
+	}
+
+	if d.Error() != "script.monkey:2:3: unterminated block comment" {
+		t.Errorf("wrong error. got = %q", d.Error())
+	}
+
+	if comments := l.Comments(); len(comments) != 1 || comments[0].Literal != "/* never closed" {
+		t.Errorf("the unterminated comment should still be recorded. got = %v", comments)
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"plain"`, "plain"},
+		{`"a\nb\tc\rd"`, "a\nb\tc\rd"},
+		{`"quote \" and backslash \\"`, `quote " and backslash \`},
+		{`"nul \0"`, "nul \x00"},
+		{`"\u{1F600} \u{e9}"`, "😀 é"},
+		{`"héllo"`, "héllo"},
+		{"\"spans\nlines\"", "spans\nlines"},
+		{"`raw \\n \"string\"`", `raw \n "string"`},
+		{"`multi\r\nline`", "multi\nline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			tok := l.NextToken()
+
+			if tok.Type != token.STRING {
+				t.Fatalf("wrong token type. expected = %q, got = %q", token.STRING, tok.Type)
+			}
+			if tok.Literal != tt.expected {
+				t.Errorf("wrong literal. expected = %q, got = %q", tt.expected, tok.Literal)
+			}
+			if len(l.Diagnostics()) != 0 {
+				t.Errorf("unexpected diagnostics: %v", l.Diagnostics())
+			}
+			if next := l.NextToken(); next.Type != token.EOF {
+				t.Errorf("expected the string to be the only token, got %q after it", next.Type)
+			}
+		})
+	}
+}
+
+func TestStringLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode diagnostic.Code
+		expectedErr  string
+	}{
+		{`let s = "never closed;`, lexer.CodeUnterminatedString, "1:9: unterminated string literal"},
+		{"let s = `never closed;", lexer.CodeUnterminatedString, "1:9: unterminated string literal"},
+		{`"ends in a backslash\`, lexer.CodeUnterminatedString, "1:1: unterminated string literal"},
+		{`"what \q is this"`, lexer.CodeUnknownEscape, `1:7: unknown escape sequence "\q"`},
+		{`"\u{110000}"`, lexer.CodeInvalidUnicodeEscape, `1:2: invalid unicode escape "\u{110000}"`},
+		{`"\u{}"`, lexer.CodeInvalidUnicodeEscape, `1:2: invalid unicode escape "\u{}"`},
+		{`"\u1F600"`, lexer.CodeInvalidUnicodeEscape, `1:2: invalid unicode escape "\u"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			}
+
+			diagnostics := l.Diagnostics()
+			if len(diagnostics) != 1 {
+				t.Fatalf("expected a single diagnostic, got %v", diagnostics)
+			}
+			if diagnostics[0].Code != tt.expectedCode {
+				t.Errorf("wrong code. expected = %q, got = %q", tt.expectedCode, diagnostics[0].Code)
+			}
+			if diagnostics[0].Error() != tt.expectedErr {
+				t.Errorf("wrong error. expected = %q, got = %q", tt.expectedErr, diagnostics[0].Error())
+			}
+		})
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let café = "☕"; let ñ_2 = café;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "café", 5},
+		{token.ASSIGN, "=", 10},
+		{token.STRING, "☕", 12},
+		{token.SEMICOLON, ";", 15},
+		{token.LET, "let", 17},
+		{token.IDENT, "ñ_2", 21},
+		{token.ASSIGN, "=", 25},
+		{token.IDENT, "café", 27},
+		{token.SEMICOLON, ";", 31},
+		{token.EOF, "", 32},
+	}
+
+	l := lexer.New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected = %s %q, got = %s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Span.Start.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - wrong column. expected = %d, got = %d", i, tt.expectedColumn, tok.Span.Start.Column)
+		}
+	}
+}
+
+func TestAssignmentOperators(t *testing.T) {
+	input := `x += 1; x -= 2; x *= 3; x /= 4; x = x / 5;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"}, {token.PLUS_ASSIGN, "+="}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.MINUS_ASSIGN, "-="}, {token.INT, "2"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.ASTERIX_ASSIGN, "*="}, {token.INT, "3"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.SLASH_ASSIGN, "/="}, {token.INT, "4"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.ASSIGN, "="}, {token.IDENT, "x"}, {token.SLASH, "/"}, {token.INT, "5"}, {token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := lexer.New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected = %s %q, got = %s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"42", []token.Token{token.New(token.INT, "42")}},
+		{"1.5", []token.Token{token.New(token.FLOAT, "1.5")}},
+		{"0.25e2", []token.Token{token.New(token.FLOAT, "0.25e2")}},
+		{"1e-3", []token.Token{token.New(token.FLOAT, "1e-3")}},
+		{"6E+10", []token.Token{token.New(token.FLOAT, "6E+10")}},
+		{"1.", []token.Token{token.New(token.INT, "1"), token.New(token.ILLEGAL, ".")}},
+		{"2e", []token.Token{token.New(token.INT, "2"), token.New(token.IDENT, "e")}},
+		{"3e+", []token.Token{token.New(token.INT, "3"), token.New(token.IDENT, "e"), token.New(token.PLUS, "+")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			for i, expected := range append(tt.expected, token.New(token.EOF, "")) {
+				tok := l.NextToken()
+				if tok.Type != expected.Type || tok.Literal != expected.Literal {
+					t.Fatalf("tokens[%d] - wrong token. expected = %s %q, got = %s %q",
+						i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+				}
+			}
+		})
+	}
+}
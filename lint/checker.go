@@ -0,0 +1,358 @@
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/diagnostic"
+	"monkey/object"
+	"monkey/token"
+	"strings"
+)
+
+type variableKind int
+
+const (
+	letVariable variableKind = iota
+	parameterVariable
+	loopVariable
+)
+
+// variable is a name defined by the program.
+type variable struct {
+	name *ast.Identifier
+	kind variableKind
+	// global is set for variables defined out of any function.
+	global bool
+	used   bool
+}
+
+// call is a call to a function the program defined, or that it may have.
+type call struct {
+	call *ast.CallExpression
+	// definition is where the function called was defined, if it's a
+	// variable.
+	definition token.Span
+	literal    *ast.FunctionLiteral
+}
+
+// checker walks a program, resolving the names it uses as the compiler would.
+type checker struct {
+	symbolTable *compiler.SymbolTable
+	// depth is how many functions enclose the current node.
+	depth int
+
+	// variables are the variables defined, in order, and by the span of
+	// their name.
+	variables   []*variable
+	definitions map[token.Span]*variable
+	// functions are the functions bound with a let, by the span of the name
+	// they're bound to. Those assigned to afterwards aren't known for sure.
+	functions  map[token.Span]*ast.FunctionLiteral
+	reassigned map[token.Span]bool
+	calls      []call
+
+	diagnostics []diagnostic.Diagnostic
+}
+
+func newChecker() *checker {
+	symbolTable := compiler.NewSymbolTable()
+	for i, builtin := range object.Builtins {
+		symbolTable.DefineBuiltin(i, builtin.Name)
+	}
+
+	return &checker{
+		symbolTable: symbolTable,
+		depth:       0,
+		variables:   []*variable{},
+		definitions: map[token.Span]*variable{},
+		functions:   map[token.Span]*ast.FunctionLiteral{},
+		reassigned:  map[token.Span]bool{},
+		calls:       []call{},
+		diagnostics: []diagnostic.Diagnostic{},
+	}
+}
+
+func (c *checker) check(program *ast.Program) {
+	c.statements(program.Statements)
+	c.checkUnused()
+	c.checkArity()
+}
+
+func (c *checker) report(d diagnostic.Diagnostic) {
+	c.diagnostics = append(c.diagnostics, d)
+}
+
+// statements checks a list of statements, reporting the first one that can't
+// be reached.
+func (c *checker) statements(statements []ast.Statement) {
+	reported := false
+	for i, stmt := range statements {
+		c.statement(stmt)
+
+		if reported || i+1 == len(statements) {
+			continue
+		}
+		if jump, ok := jumpOf(stmt); ok {
+			c.report(diagnostic.Warningf(CodeUnreachableCode, statements[i+1].Span(), "unreachable code").
+				WithNote("it comes after the %s at %s", jump, stmt.Span()))
+			reported = true
+		}
+	}
+}
+
+// jumpOf returns the keyword of stmt if it leaves the block it's in.
+func jumpOf(stmt ast.Statement) (string, bool) {
+	switch stmt.(type) {
+	case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+		return stmt.TokenLiteral(), true
+	}
+	return "", false
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		// The name is defined before its value is compiled, as the compiler
+		// does.
+		c.define(stmt.Name, letVariable)
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			c.functions[stmt.Name.Span()] = fn
+		}
+		c.expression(stmt.Value)
+
+	case *ast.ReturnStatement:
+		c.expression(stmt.ReturnValue)
+
+	case *ast.ExpressionStatement:
+		c.expression(stmt.Expression)
+
+	case *ast.AssignStatement:
+		// Assigning to a variable doesn't use it.
+		if ident, ok := stmt.Target.(*ast.Identifier); ok {
+			if span, ok := c.resolve(ident); ok {
+				c.reassigned[span] = true
+			}
+		} else {
+			c.expression(stmt.Target)
+		}
+		c.expression(stmt.Value)
+
+	case *ast.WhileStatement:
+		c.expression(stmt.Condition())
+		c.statements(stmt.Body().Statements())
+
+	case *ast.ForStatement:
+		c.expression(stmt.Iterable())
+		c.define(stmt.Variable(), loopVariable)
+		c.statements(stmt.Body().Statements())
+	}
+}
+
+func (c *checker) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		if span, ok := c.resolve(e); ok {
+			c.definitions[span].used = true
+		}
+
+	case *ast.PrefixExpression:
+		c.expression(e.Right)
+
+	case *ast.InfixExpression:
+		c.expression(e.Left)
+		c.expression(e.Right)
+
+	case *ast.CallExpression:
+		c.expression(e.Function())
+		for _, argument := range e.Arguments() {
+			c.expression(argument)
+		}
+
+		switch function := e.Function().(type) {
+		case *ast.Identifier:
+			if span, ok := c.resolve(function); ok {
+				c.calls = append(c.calls, call{call: e, definition: span, literal: nil})
+			}
+		case *ast.FunctionLiteral:
+			c.calls = append(c.calls, call{call: e, definition: token.Span{}, literal: function}) //nolint:exhaustruct
+		}
+
+	case *ast.IndexExpression:
+		c.expression(e.Left())
+		c.expression(e.Index())
+
+	case *ast.ArrayLiteral:
+		for _, element := range e.Elements {
+			c.expression(element)
+		}
+
+	case *ast.HashLiteral:
+		for key, value := range e.Pairs() {
+			c.expression(key)
+			c.expression(value)
+		}
+
+	case *ast.IfExpression:
+		c.expression(e.Condition())
+		if isConstant(e.Condition()) {
+			c.report(diagnostic.Warningf(CodeConstantCondition, e.Condition().Span(), "the condition of this if is constant").
+				WithNote("only one of its branches can ever run"))
+		}
+
+		c.statements(e.Consequence().Statements())
+		if alternative, ok := e.Alternative(); ok {
+			c.statements(alternative.Statements())
+		}
+
+	case *ast.FunctionLiteral:
+		name, _ := e.Name()
+		c.function(name, e.Parameters(), e.Body())
+
+	case *ast.MacroLiteral:
+		c.function("", e.Parameters(), e.Body())
+	}
+}
+
+// function checks the body of a function, or of a macro, in a scope of its
+// own.
+func (c *checker) function(name string, parameters []*ast.Identifier, body *ast.BlockStatement) {
+	outer := c.symbolTable
+	c.symbolTable = outer.SpawnScoped()
+	c.depth++
+	defer func() {
+		c.symbolTable = outer
+		c.depth--
+	}()
+
+	if name != "" {
+		c.symbolTable.DefineFunctionName(name)
+	}
+	for _, parameter := range parameters {
+		c.define(parameter, parameterVariable)
+	}
+
+	c.statements(body.Statements())
+}
+
+// define defines the variable named by ident, reporting whether it shadows
+// another.
+func (c *checker) define(ident *ast.Identifier, kind variableKind) {
+	name := ident.Value
+
+	if symbol, ok := c.symbolTable.Resolve(name); ok {
+		// The names of the scope itself are redefined rather than shadowed.
+		shadows := symbol.Scope != compiler.LocalScope && (symbol.Scope != compiler.GlobalScope || c.depth > 0)
+
+		switch {
+		case !shadows:
+		case symbol.Scope == compiler.BuiltinScope:
+			c.report(diagnostic.Warningf(CodeShadowedName, ident.Span(), "%s shadows the builtin %s", name, name).
+				WithNote("the builtin can't be called where %s is defined", name))
+		default:
+			d := diagnostic.Warningf(CodeShadowedName, ident.Span(), "%s shadows a variable of an enclosing scope", name)
+			if span, ok := c.symbolTable.DefinitionSpan(name); ok {
+				d = d.WithNote("%s is first defined at %s", name, span)
+			}
+			c.report(d)
+		}
+	}
+
+	v := &variable{name: ident, kind: kind, global: c.depth == 0, used: false}
+	c.variables = append(c.variables, v)
+	c.definitions[ident.Span()] = v
+	c.symbolTable.DefineAt(name, ident.Span())
+}
+
+// resolve returns where the variable ident refers to was defined, if it was
+// by the program.
+func (c *checker) resolve(ident *ast.Identifier) (token.Span, bool) {
+	if _, ok := c.symbolTable.Resolve(ident.Value); !ok {
+		return token.Span{}, false //nolint:exhaustruct
+	}
+
+	span, ok := c.symbolTable.DefinitionSpan(ident.Value)
+	if _, defined := c.definitions[span]; !ok || !defined {
+		return token.Span{}, false //nolint:exhaustruct
+	}
+	return span, true
+}
+
+// checkUnused reports the variables that are never used. Names starting with
+// an underscore are meant not to be, but for those of the top level lets:
+// the others are what the program exports as a module, and may be used by
+// whoever imports it.
+func (c *checker) checkUnused() {
+	for _, v := range c.variables {
+		name := v.name.Value
+		private := strings.HasPrefix(name, "_")
+
+		switch {
+		case v.used:
+		case v.kind == letVariable && v.global:
+			if private {
+				c.report(diagnostic.Warningf(CodeUnusedVariable, v.name.Span(), "%s is never used", name))
+			}
+		case private:
+		case v.kind == parameterVariable:
+			c.report(diagnostic.Warningf(CodeUnusedParameter, v.name.Span(), "parameter %s is never used", name).
+				WithSuggestion(diagnostic.Suggestion{
+					Message:     "prefix it with an underscore if that's intended",
+					Span:        v.name.Span(),
+					Replacement: "_" + name,
+				}))
+		default:
+			c.report(diagnostic.Warningf(CodeUnusedVariable, v.name.Span(), "%s is never used", name))
+		}
+	}
+}
+
+// checkArity reports the calls to functions known for sure that pass them the
+// wrong number of arguments.
+func (c *checker) checkArity() {
+	for _, call := range c.calls {
+		fn, name := call.literal, "the function"
+		if fn == nil {
+			if c.reassigned[call.definition] {
+				continue
+			}
+			var ok bool
+			if fn, ok = c.functions[call.definition]; !ok {
+				continue
+			}
+			name = c.definitions[call.definition].name.Value
+		}
+
+		expected, got := len(fn.Parameters()), len(call.call.Arguments())
+		if expected == got {
+			continue
+		}
+
+		d := diagnostic.Warningf(CodeWrongArity, call.call.Function().Span(),
+			"%s takes %s, but is called with %d", name, plural(expected, "argument"), got)
+		if call.literal == nil {
+			d = d.WithNote("%s is defined at %s", name, call.definition)
+		}
+		c.report(d)
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// isConstant reports whether e always evaluates to the same value.
+func isConstant(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean, *ast.FunctionLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return isConstant(e.Right)
+	case *ast.InfixExpression:
+		return isConstant(e.Left) && isConstant(e.Right)
+	}
+	return false
+}
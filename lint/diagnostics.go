@@ -0,0 +1,14 @@
+package lint
+
+import "monkey/diagnostic"
+
+// Codes of the diagnostics reported by the linter. They are warnings, hence
+// the W, which also tells them apart from the L codes of the lexer.
+const (
+	CodeUnusedVariable    diagnostic.Code = "W0001"
+	CodeUnusedParameter   diagnostic.Code = "W0002"
+	CodeShadowedName      diagnostic.Code = "W0003"
+	CodeUnreachableCode   diagnostic.Code = "W0004"
+	CodeWrongArity        diagnostic.Code = "W0005"
+	CodeConstantCondition diagnostic.Code = "W0006"
+)
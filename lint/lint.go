@@ -0,0 +1,99 @@
+// Package lint finds likely mistakes in monkey programs that still compile:
+// unused variables and parameters, shadowed names, unreachable code, calls
+// with the wrong number of arguments and if conditions that are constant.
+//
+// Names are resolved with the symbol tables of the compiler, so that they
+// mean the same to the linter as they do to the compiled program.
+//
+// A comment containing "lint:ignore" suppresses the diagnostics of its line,
+// or of the next one if the comment is on a line of its own. It may be
+// followed by the codes to suppress, e.g. "// lint:ignore W0001, W0003 reason",
+// otherwise all of them are.
+package lint
+
+import (
+	"monkey/diagnostic"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Source lints the program read from the file called filename, returning the
+// diagnostics in source order. Programs that don't parse aren't linted, the
+// diagnostics of the parser are returned instead.
+func Source(filename string, source string) []diagnostic.Diagnostic {
+	l := lexer.NewWithFilename(filename, source)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if diagnostic.HasErrors(p.Diagnostics()) {
+		return p.Diagnostics()
+	}
+
+	c := newChecker()
+	c.check(program)
+
+	ignored := suppressions(source, l.Comments())
+	diagnostics := []diagnostic.Diagnostic{}
+	for _, d := range c.diagnostics {
+		if codes, ok := ignored[d.Span.Start.Line]; ok && (codes == nil || codes[d.Code]) {
+			continue
+		}
+		diagnostics = append(diagnostics, d)
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Span.Start.Offset < diagnostics[j].Span.Start.Offset
+	})
+	return diagnostics
+}
+
+const ignoreDirective = "lint:ignore"
+
+var codePattern = regexp.MustCompile(`^[A-Z][0-9]{4}$`)
+
+// suppressions returns the codes suppressed by the comments of source, by
+// line. All codes are suppressed on lines mapped to nil.
+func suppressions(source string, comments []token.Token) map[int]map[diagnostic.Code]bool {
+	ignored := map[int]map[diagnostic.Code]bool{}
+
+	for _, comment := range comments {
+		i := strings.Index(comment.Literal, ignoreDirective)
+		if i < 0 {
+			continue
+		}
+
+		var codes map[diagnostic.Code]bool
+		rest := strings.TrimSuffix(comment.Literal[i+len(ignoreDirective):], "*/")
+		for _, field := range strings.Fields(strings.ReplaceAll(rest, ",", " ")) {
+			if !codePattern.MatchString(field) {
+				break
+			}
+			if codes == nil {
+				codes = map[diagnostic.Code]bool{}
+			}
+			codes[diagnostic.Code(field)] = true
+		}
+
+		line := comment.Span.Start.Line
+		if onItsOwnLine(source, comment) {
+			line = comment.Span.End.Line + 1
+		}
+		ignored[line] = codes
+	}
+
+	return ignored
+}
+
+// onItsOwnLine reports whether there's nothing but comment on its line(s).
+func onItsOwnLine(source string, comment token.Token) bool {
+	lineStart := strings.LastIndexByte(source[:comment.Span.Start.Offset], '\n') + 1
+	lineEnd := len(source)
+	if i := strings.IndexByte(source[comment.Span.End.Offset:], '\n'); i >= 0 {
+		lineEnd = comment.Span.End.Offset + i
+	}
+	return strings.TrimSpace(source[lineStart:comment.Span.Start.Offset]) == "" &&
+		strings.TrimSpace(source[comment.Span.End.Offset:lineEnd]) == ""
+}
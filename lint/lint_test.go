@@ -0,0 +1,146 @@
+package lint_test
+
+import (
+	"fmt"
+	"monkey/diagnostic"
+	"monkey/lint"
+	"strings"
+	"testing"
+)
+
+// describe lists the diagnostics as "line:column code message", one per line.
+func describe(diagnostics []diagnostic.Diagnostic) string {
+	lines := []string{}
+	for _, d := range diagnostics {
+		lines = append(lines, fmt.Sprintf("%s %s %s", d.Span.Start, d.Code, d.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"unused variables and parameters",
+			`let add = fn(a, b, _c) { let sum = a; let _d = 1; 1 };
+let _private = 1;
+let exported = 2;
+for (x in [1]) { puts("x") }
+let counter = fn() { let n = 0; n = 1 };
+add(1, 2, 3);`,
+			"1:17 W0002 parameter b is never used\n" +
+				"1:30 W0001 sum is never used\n" +
+				"2:5 W0001 _private is never used\n" +
+				"4:6 W0001 x is never used\n" +
+				"5:26 W0001 n is never used",
+		},
+		{
+			"shadowed names",
+			`let x = 1;
+let f = fn(len) {
+  let x = len;
+  let g = fn() { let len = 2; len + x };
+  let f = 3;
+  g() + f
+};
+let x = 2;
+f(x);`,
+			"2:12 W0003 len shadows the builtin len\n" +
+				"3:7 W0003 x shadows a variable of an enclosing scope\n" +
+				"4:22 W0003 len shadows a variable of an enclosing scope\n" +
+				"5:7 W0003 f shadows a variable of an enclosing scope",
+		},
+		{
+			"unreachable code",
+			`let f = fn(x) {
+  if (x) { return 1; puts("never"); puts("nor this") }
+  while (x) { break; x }
+  for (y in x) { continue; y }
+  return 2;
+  x
+};
+f(1);`,
+			"2:22 W0004 unreachable code\n" +
+				"3:22 W0004 unreachable code\n" +
+				"4:28 W0004 unreachable code\n" +
+				"6:3 W0004 unreachable code",
+		},
+		{
+			"arity",
+			`let add = fn(a, b) { a + b };
+add(1);
+add(1, 2);
+let one = fn(a) { a };
+one(1, 2);
+fn(a) { a }();
+let changed = fn(a) { a };
+changed = fn(a, b) { a + b };
+changed(1, 2);
+let rec = fn(n) { rec(n - 1, n) };
+rec(1);`,
+			"2:1 W0005 add takes 2 arguments, but is called with 1\n" +
+				"5:1 W0005 one takes 1 argument, but is called with 2\n" +
+				"6:1 W0005 the function takes 1 argument, but is called with 0\n" +
+				"10:19 W0005 rec takes 1 argument, but is called with 2",
+		},
+		{
+			"constant conditions",
+			`let x = 1;
+if (true) { 1 };
+if (1 < 2) { 1 };
+if (!"a") { 1 };
+if (x) { 1 };
+if (x > 1) { 1 };
+while (true) { break; }`,
+			"2:5 W0006 the condition of this if is constant\n" +
+				"3:7 W0006 the condition of this if is constant\n" +
+				"4:5 W0006 the condition of this if is constant",
+		},
+		{
+			"suppressions",
+			`let f = fn(a) { // lint:ignore
+  let b = 1; // lint:ignore W0003
+  // lint:ignore W0001 not needed yet
+  let c = 1;
+  /* lint:ignore W0001, W0002 */ let d = 1;
+  1
+};
+f(1);`,
+			"2:7 W0001 b is never used",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := describe(lint.Source("script.monkey", tt.input))
+			if got != tt.expected {
+				t.Errorf("wrong diagnostics.\n got:\n%s\nwant:\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSourceDiagnostics(t *testing.T) {
+	diagnostics := lint.Source("script.monkey", "let f = fn(a) { 1 };\nf(1);")
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected a diagnostic. got = %v", diagnostics)
+	}
+
+	d := diagnostics[0]
+	if d.Severity != diagnostic.SeverityWarning || d.Span.File != "script.monkey" {
+		t.Errorf("wrong diagnostic. got = %+v", d)
+	}
+	if len(d.Suggestions) != 1 || d.Suggestions[0].Replacement != "_a" || d.Suggestions[0].Span != d.Span {
+		t.Errorf("expected a suggestion to rename the parameter. got = %+v", d.Suggestions)
+	}
+}
+
+func TestSourceThatDoesNotParse(t *testing.T) {
+	diagnostics := lint.Source("script.monkey", "let = 1;")
+	if !diagnostic.HasErrors(diagnostics) {
+		t.Fatalf("expected the errors of the parser. got = %v", diagnostics)
+	}
+}
@@ -0,0 +1,314 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/diagnostic"
+	"monkey/lexer"
+	"monkey/module"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// analysis is what the server knows of a document, as of its last change.
+type analysis struct {
+	path   string
+	source string
+
+	// program is as the parser recovered it if the document doesn't parse.
+	program     *ast.Program
+	diagnostics []diagnostic.Diagnostic
+
+	// references are the identifiers of the document, but those of the
+	// statements the compiler couldn't make sense of.
+	references []compiler.Reference
+	// functions are the function literals bound with a let, by the span of
+	// the name they're bound to.
+	functions map[token.Span]*ast.FunctionLiteral
+}
+
+// macroLimits bound the macros expanded while analyzing a document, which run
+// on each of its changes.
+var macroLimits = object.Limits{
+	MaxInstructions: 1_000_000,
+	MaxDepth:        1000,
+	MaxAllocations:  100_000,
+	MaxStringLength: 1 << 20,
+	MaxArrayLength:  100_000,
+}
+
+// macroTimeout bounds the time macros run for while analyzing a document.
+const macroTimeout = time.Second
+
+// analyze parses and compiles the document read from path, as the compiled
+// engine would run it. Documents that don't parse are analyzed as the parser
+// recovered them, though only their parse errors are reported.
+func analyze(path string, source string) *analysis {
+	a := &analysis{
+		path:        path,
+		source:      source,
+		program:     nil,
+		diagnostics: []diagnostic.Diagnostic{},
+		references:  []compiler.Reference{},
+		functions:   map[token.Span]*ast.FunctionLiteral{},
+	}
+
+	p := parser.New(lexer.NewWithFilename(path, source))
+	program := p.ParseProgram()
+	a.diagnostics = append(a.diagnostics, p.Diagnostics()...)
+	parsed := !diagnostic.HasErrors(p.Diagnostics())
+	if !parsed {
+		program.Statements = completeStatements(program.Statements)
+	}
+	a.program = program
+	a.collectFunctions()
+
+	loader := module.NewLoader(module.SearchPathsFromEnv()...)
+	// A program whose macros fail is still compiled for its references, as
+	// far as it goes without them, but only the failure is reported.
+	expanded, expandErr := expandMacros(loader, program)
+	if expandErr != nil {
+		expanded = program
+	}
+
+	comp := compiler.New()
+	comp.SetModules(compiler.NewModules(loader))
+	comp.RecordReferences()
+	compileErr := comp.Compile(expanded)
+
+	for _, ref := range comp.References() {
+		if ref.Span.File == path {
+			a.references = append(a.references, ref)
+		}
+	}
+	switch {
+	case !parsed:
+	case expandErr != nil:
+		a.addError(expandErr)
+	case compileErr != nil:
+		a.addError(compileErr)
+	}
+
+	return a
+}
+
+// expandMacros expands the macros of program and of the modules it imports,
+// which are the user's code: they run within macroLimits, and without access
+// to the standard streams, those of the server.
+func expandMacros(loader *module.Loader, program *ast.Program) (ast.Node, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), macroTimeout)
+	defer cancel()
+
+	exec := object.DefaultExecContext()
+	exec.Stdout = io.Discard
+	exec.Stderr = io.Discard
+	exec.Stdin = nil
+
+	env := object.NewEnvironment()
+	env.SetExecContext(exec)
+	env.SetBudget(object.NewBudget(ctx, macroLimits))
+	loader.SetMacroEnvironment(env)
+	return loader.Expand(program, env.NewScoped())
+}
+
+// addError adds a diagnostic for an error of expanding or compiling the
+// program. Errors found in imported modules are reported at the top of the
+// document.
+func (a *analysis) addError(err error) {
+	var parseErr *module.ParseError
+	if errors.As(err, &parseErr) {
+		err = parseErr.Diagnostics[0]
+	}
+
+	var d diagnostic.Diagnostic
+	if !errors.As(err, &d) {
+		d = diagnostic.Errorf("", token.Span{}, "%s", err) //nolint:exhaustruct
+	}
+
+	if d.Span.IsValid() && d.Span.File != a.path {
+		d = diagnostic.New(d.Severity, d.Code, token.Span{}, "%s: %s", d.Span, d.Message) //nolint:exhaustruct
+	}
+	a.diagnostics = append(a.diagnostics, d)
+}
+
+// completeStatements returns the statements the parser recovered in full,
+// leaving out those missing nodes where it failed.
+func completeStatements(statements []ast.Statement) []ast.Statement {
+	complete := []ast.Statement{}
+	for _, statement := range statements {
+		_, err := ast.Modify(statement, func(node ast.Node) (ast.Node, error) { return node, nil })
+		if !errors.Is(err, ast.ErrIncomplete) {
+			complete = append(complete, statement)
+		}
+	}
+	return complete
+}
+
+func (a *analysis) collectFunctions() {
+	_, _ = ast.Modify(a.program, func(node ast.Node) (ast.Node, error) {
+		if let, ok := node.(*ast.LetStatement); ok {
+			if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+				a.functions[let.Name.Span()] = fn
+			}
+		}
+		return node, nil
+	})
+}
+
+// referenceAt returns the identifier at pos, if there's one.
+func (a *analysis) referenceAt(pos Position) (compiler.Reference, bool) {
+	offset := a.offset(pos)
+	for _, ref := range a.references {
+		if ref.Span.Start.Offset <= offset && offset <= ref.Span.End.Offset {
+			return ref, true
+		}
+	}
+	return compiler.Reference{}, false //nolint:exhaustruct
+}
+
+// referencesTo returns the identifiers referring to the variable defined at
+// definition.
+func (a *analysis) referencesTo(definition token.Span, includeDefinition bool) []compiler.Reference {
+	refs := []compiler.Reference{}
+	for _, ref := range a.references {
+		if ref.Definition == definition && (includeDefinition || !ref.IsDefinition()) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// describe describes the variable ref refers to, in markdown.
+func (a *analysis) describe(ref compiler.Reference) string {
+	if ref.Scope == compiler.BuiltinScope {
+		for _, builtin := range object.Builtins {
+			if builtin.Name == ref.Name {
+				return fmt.Sprintf("```monkey\n%s\n```\n%s", builtin.Signature, builtin.Doc)
+			}
+		}
+	}
+
+	scope := strings.ToLower(string(ref.Scope))
+	if ref.Scope == compiler.FunctionScope {
+		scope = "function"
+	}
+
+	signature := ref.Name
+	if fn, ok := a.functions[ref.Definition]; ok {
+		params := []string{}
+		for _, param := range fn.Parameters() {
+			params = append(params, param.Value)
+		}
+		signature = fmt.Sprintf("%s: fn(%s)", ref.Name, strings.Join(params, ", "))
+	}
+
+	return fmt.Sprintf("```monkey\n(%s) %s\n```", scope, signature)
+}
+
+// definedNames returns the names of the variables defined in the document,
+// each once, and whether each is bound to a function.
+func (a *analysis) definedNames() ([]string, map[string]bool) {
+	names := []string{}
+	isFunction := map[string]bool{}
+
+	add := func(name string, span token.Span) {
+		if _, seen := isFunction[name]; !seen {
+			names = append(names, name)
+			isFunction[name] = false
+		}
+		if _, ok := a.functions[span]; ok {
+			isFunction[name] = true
+		}
+	}
+
+	for _, ref := range a.references {
+		if ref.IsDefinition() {
+			add(ref.Name, ref.Span)
+		}
+	}
+	// The program may not have compiled to the end.
+	for _, let := range a.topLevelLets() {
+		add(let.Name.Value, let.Name.Span())
+	}
+
+	return names, isFunction
+}
+
+func (a *analysis) topLevelLets() []*ast.LetStatement {
+	if a.program == nil {
+		return nil
+	}
+
+	lets := []*ast.LetStatement{}
+	for _, statement := range a.program.Statements {
+		if let, ok := statement.(*ast.LetStatement); ok {
+			lets = append(lets, let)
+		}
+	}
+	return lets
+}
+
+// toRange converts span to a range of the document. Spans without a location
+// are at its very beginning.
+func (a *analysis) toRange(span token.Span) Range {
+	if !span.IsValid() {
+		return Range{} //nolint:exhaustruct
+	}
+
+	end := span.End
+	if !end.IsValid() {
+		end = span.Start
+	}
+	return Range{Start: a.toPosition(span.Start), End: a.toPosition(end)}
+}
+
+func (a *analysis) toPosition(pos token.Position) Position {
+	lineStart := strings.LastIndexByte(a.source[:min(pos.Offset, len(a.source))], '\n') + 1
+	return Position{Line: pos.Line - 1, Character: utf16Len(a.source[lineStart:min(pos.Offset, len(a.source))])}
+}
+
+// offset returns the byte offset of pos in the document.
+func (a *analysis) offset(pos Position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		next := strings.IndexByte(a.source[offset:], '\n')
+		if next < 0 {
+			return len(a.source)
+		}
+		offset += next + 1
+	}
+
+	for units := 0; units < pos.Character && offset < len(a.source); {
+		r, size := utf8.DecodeRuneInString(a.source[offset:])
+		if r == '\n' {
+			break
+		}
+		units += utf16Units(r)
+		offset += size
+	}
+	return offset
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16Units(r)
+	}
+	return n
+}
+
+// utf16Units returns the number of UTF-16 code units encoding r.
+func utf16Units(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,324 @@
+// lsp is a language server for monkey, speaking the Language Server Protocol
+// so that editors can report errors as code is typed, and navigate it.
+//
+// Documents are analyzed as the compiled engine would run them: parsed, with
+// their macros expanded and their imports loaded, and compiled.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/diagnostic"
+	"monkey/object"
+	"monkey/token"
+	"net/textproto"
+	"net/url"
+	"strconv"
+)
+
+// Server serves a single client, reading its messages from in and writing
+// to out.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// documents are the open documents, by URI.
+	documents map[string]*analysis
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: map[string]*analysis{},
+	}
+}
+
+// Serve serves the client until it exits, or in is closed.
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, rpcErr := s.handle(msg)
+		// Notifications have no ID, and get no response.
+		if msg.ID == nil {
+			continue
+		}
+		s.write(response{JSONRPC: "2.0", ID: msg.ID, Result: result, Error: rpcErr})
+	}
+}
+
+func (s *Server) read() (*message, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %w", err)
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(s.in, content); err != nil {
+		return nil, err
+	}
+
+	msg := &message{} //nolint:exhaustruct
+	if err := json.Unmarshal(content, msg); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	return msg, nil
+}
+
+func (s *Server) write(v any) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(content), content)
+}
+
+func (s *Server) notify(method string, params any) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handle handles a request or a notification, returning the result of the
+// former.
+func (s *Server) handle(msg *message) (any, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				// Documents are synced by sending their full text.
+				"textDocumentSync":       1,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"hoverProvider":          true,
+				"completionProvider":     map[string]any{},
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "monkey"},
+		}, nil
+
+	case "shutdown":
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.documents, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{params.TextDocument.URI, []Diagnostic{}})
+		return nil, nil
+
+	case "textDocument/definition", "textDocument/hover", "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+
+		switch msg.Method {
+		case "textDocument/definition":
+			return s.definition(params.TextDocument.URI, doc, params.Position), nil
+		case "textDocument/hover":
+			return s.hover(doc, params.Position), nil
+		default:
+			return s.completion(doc), nil
+		}
+
+	case "textDocument/references":
+		var params referenceParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return s.references(params.TextDocument.URI, doc, params.Position, params.Context.IncludeDeclaration), nil
+
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return s.documentSymbols(doc), nil
+	}
+
+	if msg.ID == nil {
+		// Notifications the server doesn't know about are ignored.
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("unsupported method %q", msg.Method)}
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// update analyzes the new text of a document, publishing its diagnostics.
+func (s *Server) update(uri string, text string) {
+	doc := analyze(uriToPath(uri), text)
+	s.documents[uri] = doc
+
+	diagnostics := []Diagnostic{}
+	for _, d := range doc.diagnostics {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    doc.toRange(d.Span),
+			Severity: severity(d.Severity),
+			Code:     string(d.Code),
+			Source:   "monkey",
+			Message:  d.Message,
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{uri, diagnostics})
+}
+
+func (s *Server) definition(uri string, doc *analysis, pos Position) *Location {
+	ref, ok := doc.referenceAt(pos)
+	if !ok || !ref.Definition.IsValid() {
+		return nil
+	}
+	return &Location{URI: uri, Range: doc.toRange(ref.Definition)}
+}
+
+func (s *Server) references(uri string, doc *analysis, pos Position, includeDeclaration bool) []Location {
+	locations := []Location{}
+
+	ref, ok := doc.referenceAt(pos)
+	if !ok || !ref.Definition.IsValid() {
+		return locations
+	}
+
+	for _, other := range doc.referencesTo(ref.Definition, includeDeclaration) {
+		locations = append(locations, Location{URI: uri, Range: doc.toRange(other.Span)})
+	}
+	return locations
+}
+
+func (s *Server) hover(doc *analysis, pos Position) *Hover {
+	ref, ok := doc.referenceAt(pos)
+	if !ok {
+		return nil
+	}
+	return &Hover{
+		Contents: markupContent{Kind: "markdown", Value: doc.describe(ref)},
+		Range:    doc.toRange(ref.Span),
+	}
+}
+
+// completion completes identifiers with every name the document defines, the
+// builtins and the keywords. Clients filter them with what was typed.
+func (s *Server) completion(doc *analysis) []CompletionItem {
+	items := []CompletionItem{}
+
+	names, isFunction := doc.definedNames()
+	for _, name := range names {
+		kind := completionVariable
+		if isFunction[name] {
+			kind = completionFunction
+		}
+		items = append(items, CompletionItem{Label: name, Kind: kind}) //nolint:exhaustruct
+	}
+
+	for _, builtin := range object.Builtins {
+		items = append(items, CompletionItem{
+			Label:         builtin.Name,
+			Kind:          completionFunction,
+			Detail:        builtin.String(),
+			Documentation: &markupContent{Kind: "markdown", Value: builtin.Doc},
+		})
+	}
+
+	for _, keyword := range token.Keywords() {
+		items = append(items, CompletionItem{Label: keyword, Kind: completionKeyword}) //nolint:exhaustruct
+	}
+
+	return items
+}
+
+// documentSymbols lists the top level lets of the document.
+func (s *Server) documentSymbols(doc *analysis) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, let := range doc.topLevelLets() {
+		kind := symbolVariable
+		if _, ok := doc.functions[let.Name.Span()]; ok {
+			kind = symbolFunction
+		}
+
+		symbols = append(symbols, DocumentSymbol{
+			Name: let.Name.Value,
+			Kind: kind,
+			Range: Range{
+				Start: doc.toRange(let.Span()).Start,
+				End:   doc.toRange(let.Name.Span()).End,
+			},
+			SelectionRange: doc.toRange(let.Name.Span()),
+		})
+	}
+	return symbols
+}
+
+func severity(s diagnostic.Severity) int {
+	switch s {
+	case diagnostic.SeverityWarning:
+		return 2
+	case diagnostic.SeverityInfo:
+		return 3
+	case diagnostic.SeverityHint:
+		return 4
+	case diagnostic.SeverityError:
+	}
+	return 1
+}
+
+// uriToPath returns the path of the file at uri, or uri itself if it's not a
+// file, as for documents that weren't saved yet.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
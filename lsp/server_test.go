@@ -0,0 +1,239 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/lsp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///project/script.monkey"
+
+const source = `let total = 0;
+let add = fn(x) {
+  total = total + x;
+  total
+};
+add(len("héllo"));
+let missing = nope;
+`
+
+// session runs a server through the given messages, returning what it sent
+// back: the responses by ID and the notifications in order.
+func session(t *testing.T, messages ...map[string]any) (map[int]json.RawMessage, []map[string]any) {
+	t.Helper()
+
+	var in bytes.Buffer
+	for _, msg := range messages {
+		msg["jsonrpc"] = "2.0"
+		content, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	}
+
+	var out bytes.Buffer
+	if err := lsp.NewServer(&in, &out).Serve(); err != nil {
+		t.Fatalf("Serve failed: %s", err)
+	}
+
+	responses := map[int]json.RawMessage{}
+	notifications := []map[string]any{}
+
+	reader := bufio.NewReader(&out)
+	for {
+		header, err := textproto.NewReader(reader).ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid output: %s", err)
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		content := make([]byte, length)
+		if _, err := io.ReadFull(reader, content); err != nil {
+			t.Fatal(err)
+		}
+
+		var msg struct {
+			ID     *int            `json:"id"`
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(content, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.ID != nil {
+			responses[*msg.ID] = msg.Result
+			continue
+		}
+
+		var notification map[string]any
+		if err := json.Unmarshal(content, &notification); err != nil {
+			t.Fatal(err)
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return responses, notifications
+}
+
+func request(id int, method string, params map[string]any) map[string]any {
+	return map[string]any{"id": id, "method": method, "params": params}
+}
+
+func at(line int, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": character},
+		"context":      map[string]any{"includeDeclaration": true},
+	}
+}
+
+func TestServer(t *testing.T) {
+	responses, notifications := session(t,
+		request(1, "initialize", map[string]any{}),
+		map[string]any{"method": "initialized", "params": map[string]any{}},
+		map[string]any{"method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": "monkey", "version": 1, "text": source},
+		}},
+		// The use of total in the body of add.
+		request(2, "textDocument/definition", at(2, 11)),
+		request(3, "textDocument/references", at(0, 5)),
+		request(4, "textDocument/hover", at(5, 5)),
+		request(5, "textDocument/hover", at(5, 1)),
+		request(6, "textDocument/completion", at(6, 0)),
+		request(7, "textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": uri}}),
+		request(8, "shutdown", nil),
+		map[string]any{"method": "exit"},
+	)
+
+	tests := []struct {
+		id       int
+		expected string
+	}{
+		{2, `{"uri":"file:///project/script.monkey","range":{"start":{"line":0,"character":4},"end":{"line":0,"character":9}}}`},
+		{3, `[{"uri":"file:///project/script.monkey","range":{"start":{"line":0,"character":4},"end":{"line":0,"character":9}}},` +
+			`{"uri":"file:///project/script.monkey","range":{"start":{"line":2,"character":2},"end":{"line":2,"character":7}}},` +
+			`{"uri":"file:///project/script.monkey","range":{"start":{"line":2,"character":10},"end":{"line":2,"character":15}}},` +
+			`{"uri":"file:///project/script.monkey","range":{"start":{"line":3,"character":2},"end":{"line":3,"character":7}}}]`},
+		{4, `{"contents":{"kind":"markdown","value":"` + "```monkey\\nlen(value)\\n```\\n" +
+			`Returns the number of characters of a string, of elements of an array or a range, or of pairs of a hash."},` +
+			`"range":{"start":{"line":5,"character":4},"end":{"line":5,"character":7}}}`},
+		{5, `{"contents":{"kind":"markdown","value":"` + "```monkey\\n(global) add: fn(x)\\n```" + `"},` +
+			`"range":{"start":{"line":5,"character":0},"end":{"line":5,"character":3}}}`},
+		{7, `[{"name":"total","kind":13,"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":9}},"selectionRange":{"start":{"line":0,"character":4},"end":{"line":0,"character":9}}},` +
+			`{"name":"add","kind":12,"range":{"start":{"line":1,"character":0},"end":{"line":1,"character":7}},"selectionRange":{"start":{"line":1,"character":4},"end":{"line":1,"character":7}}},` +
+			`{"name":"missing","kind":13,"range":{"start":{"line":6,"character":0},"end":{"line":6,"character":11}},"selectionRange":{"start":{"line":6,"character":4},"end":{"line":6,"character":11}}}]`},
+	}
+
+	for _, tt := range tests {
+		if got := string(responses[tt.id]); got != tt.expected {
+			t.Errorf("wrong response to %d.\n got = %s\nwant = %s", tt.id, got, tt.expected)
+		}
+	}
+
+	var completion []struct {
+		Label string `json:"label"`
+		Kind  int    `json:"kind"`
+	}
+	if err := json.Unmarshal(responses[6], &completion); err != nil {
+		t.Fatal(err)
+	}
+	labels := []string{}
+	for _, item := range completion {
+		labels = append(labels, fmt.Sprintf("%s/%d", item.Label, item.Kind))
+	}
+	for _, expected := range []string{"total/6", "add/3", "x/6", "missing/6", "puts/3", "while/14"} {
+		if !strings.Contains(" "+strings.Join(labels, " ")+" ", " "+expected+" ") {
+			t.Errorf("completion is missing %s. got = %v", expected, labels)
+		}
+	}
+
+	if len(notifications) != 1 || notifications[0]["method"] != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected diagnostics to be published. got = %v", notifications)
+	}
+	diagnostics, _ := json.Marshal(notifications[0]["params"])
+	expected := `{"diagnostics":[{"code":"C0003","message":"undefined variable: nope","range":{"end":{"character":18,"line":6},"start":{"character":14,"line":6}},"severity":1,"source":"monkey"}],"uri":"file:///project/script.monkey"}`
+	if string(diagnostics) != expected {
+		t.Errorf("wrong diagnostics.\n got = %s\nwant = %s", diagnostics, expected)
+	}
+}
+
+func TestServerParseErrors(t *testing.T) {
+	_, notifications := session(t,
+		map[string]any{"method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": uri, "text": "let = 1;\nlet 😀x = 2;"},
+		}},
+		map[string]any{"method": "textDocument/didChange", "params": map[string]any{
+			"textDocument":   map[string]any{"uri": uri},
+			"contentChanges": []map[string]any{{"text": "let x = 1;"}},
+		}},
+	)
+
+	if len(notifications) != 2 {
+		t.Fatalf("expected diagnostics to be published twice. got = %v", notifications)
+	}
+
+	first := notifications[0]["params"].(map[string]any)["diagnostics"].([]any)
+	if len(first) == 0 {
+		t.Errorf("expected parse errors")
+	}
+	second := notifications[1]["params"].(map[string]any)["diagnostics"].([]any)
+	if len(second) != 0 {
+		t.Errorf("expected no diagnostics once fixed. got = %v", second)
+	}
+}
+
+func TestServerRecovers(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		params map[string]any
+		method string
+		want   string
+	}{
+		{
+			name:   "definition despite a parse error",
+			text:   "let x = 1;\nlet = 2;\nputs(x);",
+			method: "textDocument/definition",
+			params: at(2, 5),
+			want:   `{"uri":"file:///project/script.monkey","range":{"start":{"line":0,"character":4},"end":{"line":0,"character":5}}}`,
+		},
+		{
+			name:   "references after a compile error",
+			text:   "let a = nope;\nlet x = 1;\nputs(x);",
+			method: "textDocument/references",
+			params: at(1, 4),
+			want: `[{"uri":"file:///project/script.monkey","range":{"start":{"line":1,"character":4},"end":{"line":1,"character":5}}},` +
+				`{"uri":"file:///project/script.monkey","range":{"start":{"line":2,"character":5},"end":{"line":2,"character":6}}}]`,
+		},
+		{
+			name:   "macros running out of their limits",
+			text:   "let forever = macro() { while (true) { puts(\"tick\"); } };\nlet x = 1;\nforever();\nputs(x);",
+			method: "textDocument/definition",
+			params: at(3, 5),
+			want:   `{"uri":"file:///project/script.monkey","range":{"start":{"line":1,"character":4},"end":{"line":1,"character":5}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses, _ := session(t,
+				map[string]any{"method": "textDocument/didOpen", "params": map[string]any{
+					"textDocument": map[string]any{"uri": uri, "text": tt.text},
+				}},
+				request(1, tt.method, tt.params),
+			)
+			if got := string(responses[1]); got != tt.want {
+				t.Errorf("wrong response.\n got = %s\nwant = %s", got, tt.want)
+			}
+		})
+	}
+}
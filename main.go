@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"monkey/fileexec"
 	"monkey/repl"
@@ -11,6 +12,13 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			subcommand(os.Args[2:])
+			return
+		}
+	}
+
 	args := ParseArgs()
 
 	if args.ShouldEnterRepl() {
@@ -37,7 +45,11 @@ func main() {
 
 	switch args.Engine {
 	case ENGINE_VM:
-		fileexec.ExecFileCompiled(args.File)
+		var dump io.Writer
+		if args.Dump {
+			dump = os.Stderr
+		}
+		fileexec.ExecFileCompiled(args.File, dump, args.Debug)
 		return
 	case ENGINE_TREE:
 		fileexec.ExecFileTree(args.File)
@@ -61,11 +73,16 @@ func isEngineTypeAllowed(engineType EngineType) bool {
 type MonkeyProgArgs struct {
 	File   string
 	Engine EngineType
+	Dump   bool
+	Debug  bool
 }
 
 func ParseArgs() *MonkeyProgArgs {
 	fileFlag := flag.String("file", "", "Path to the file to be evaluated. If omitted, will enter REPL instead.")
 	engineFlag := flag.String("engine", "vm", "The backend engine to evaluate the language. [vm, tree]")
+	dumpFlag := flag.Bool("dump", false, "Print the disassembled bytecode to stderr before running the file. Only with the vm engine.")
+
+	debugFlag := flag.Bool("debug", false, "Dump the globals, the stack and the instructions of the VM on runtime errors. Only with the vm engine.")
 
 	flag.Parse()
 
@@ -79,6 +96,8 @@ func ParseArgs() *MonkeyProgArgs {
 	return &MonkeyProgArgs{
 		File:   *fileFlag,
 		Engine: engine,
+		Dump:   *dumpFlag,
+		Debug:  *debugFlag,
 	}
 }
 
@@ -0,0 +1,9 @@
+package module
+
+import "monkey/diagnostic"
+
+// Codes of the diagnostics reported while loading modules.
+const (
+	CodeModuleNotFound diagnostic.Code = "I0001"
+	CodeImportCycle    diagnostic.Code = "I0002"
+)
@@ -0,0 +1,96 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+	"monkey/diagnostic"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+)
+
+// Importer runs the modules imported by programs running on the tree engine,
+// each in an environment of its own. It's an object.Importer, set on the
+// environment of the program with object.Environment.SetImporter.
+type Importer struct {
+	loader *Loader
+
+	// exports of the modules that already ran, by path.
+	exports map[string]*object.Hash
+	// running are the paths of the modules currently running.
+	running map[string]bool
+
+	// builtins are those of the modules, the standard ones if nil, and exec
+	// what they reach outside of the program through.
+	builtins *object.BuiltinRegistry
+	exec     *object.ExecContext
+}
+
+func NewImporter(loader *Loader) *Importer {
+	return &Importer{
+		loader:   loader,
+		exports:  map[string]*object.Hash{},
+		running:  map[string]bool{},
+		builtins: nil,
+		exec:     nil,
+	}
+}
+
+// SetExecContext sets what the builtins called by the modules imported reach
+// outside of the program through, if it's not the process itself.
+func (i *Importer) SetExecContext(exec *object.ExecContext) {
+	i.exec = exec
+}
+
+// SetBuiltins sets the builtins of the modules imported, if they're not the
+// standard ones.
+func (i *Importer) SetBuiltins(builtins *object.BuiltinRegistry) {
+	i.builtins = builtins
+}
+
+func (i *Importer) Import(path string, from token.Span) object.Object {
+	module, err := i.loader.Load(path, from)
+	if err != nil {
+		var d diagnostic.Diagnostic
+		if errors.As(err, &d) {
+			return &object.Error{Message: d.Message, Span: d.Span}
+		}
+		return &object.Error{Message: err.Error(), Span: from}
+	}
+
+	if exports, ok := i.exports[module.Path]; ok {
+		return exports
+	}
+
+	// Cycles are caught when loading, but a module could still get to import
+	// itself if the loader was used before to load a different program.
+	if i.running[module.Path] {
+		return &object.Error{Message: fmt.Sprintf("import cycle: %s imports itself", module.Path), Span: from}
+	}
+	i.running[module.Path] = true
+	defer delete(i.running, module.Path)
+
+	env := object.NewEnvironment()
+	env.SetImporter(i)
+	env.SetBuiltins(i.builtins)
+	env.SetExecContext(i.exec)
+
+	if result := evaluator.Eval(module.Program, env); result != nil && result.Type() == object.ERROR_OBJ {
+		return result
+	}
+
+	exports := object.NewHash()
+	for _, name := range module.Exports {
+		value, ok := env.Get(name)
+		if !ok {
+			continue
+		}
+
+		key := &object.String{Value: name}
+		hashKey, _ := key.HashKey()
+		exports.Set(hashKey, object.HashPair{Key: key, Value: value})
+	}
+
+	i.exports[module.Path] = exports
+	return exports
+}
@@ -0,0 +1,254 @@
+// module loads the modules imported by monkey programs, for both engines.
+//
+// A module is a source file, importing it with `import("path")` evaluates to a
+// hash of its exports: the top level bindings whose name doesn't start with an
+// underscore. Each module is run once, however many times it is imported.
+//
+// Macros are exported as well, once a module is bound at the top level with
+// `let m = import("path")` its macros are called as `m["name"](...)`.
+package module
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/diagnostic"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"os"
+	"strings"
+)
+
+// Module is an imported source file, parsed and with its macros expanded.
+type Module struct {
+	// Path is the absolute path of the file.
+	Path   string
+	Source string
+
+	Program *ast.Program
+
+	// Exports are the names of the bindings the module exports, in the order
+	// they're defined.
+	Exports []string
+
+	// Macros holds the macros the module defines or imports.
+	Macros *object.Environment
+
+	// macros are the names of the macros the module exports.
+	macros []string
+}
+
+// ParseError is returned when an imported module doesn't parse.
+type ParseError struct {
+	Path        string
+	Source      string
+	Diagnostics []diagnostic.Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	if len(e.Diagnostics) == 1 {
+		return e.Diagnostics[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e.Diagnostics[0].Error(), len(e.Diagnostics)-1)
+}
+
+// Loader reads, parses and expands the macros of modules, each once. Errors
+// point at the import that failed, loading a program fails on import cycles.
+type Loader struct {
+	Resolver Resolver
+
+	modules map[string]*Module
+	// sources of the files read, including those of modules that failed to
+	// load.
+	sources map[string]string
+	// loading are the modules being loaded, each imported by the previous one.
+	loading []string
+	// macroEnv is the environment the macros of modules are defined within,
+	// see SetMacroEnvironment.
+	macroEnv *object.Environment
+}
+
+func NewLoader(searchPaths ...string) *Loader {
+	return &Loader{
+		Resolver: Resolver{SearchPaths: searchPaths},
+		modules:  map[string]*Module{},
+		sources:  map[string]string{},
+		loading:  []string{},
+		macroEnv: nil,
+	}
+}
+
+// SetMacroEnvironment makes the macros of the modules loaded afterwards be
+// defined in environments scoped within env, which gives them its builtins,
+// exec context and budget.
+func (l *Loader) SetMacroEnvironment(env *object.Environment) {
+	l.macroEnv = env
+}
+
+// Source returns the source of the module loaded from file, so that errors
+// reported in modules can be shown along with their code.
+func (l *Loader) Source(file string) (string, bool) {
+	source, ok := l.sources[file]
+	return source, ok
+}
+
+// Load returns the module imported as path, loading it if it wasn't already.
+// from is the location of the import.
+func (l *Loader) Load(path string, from token.Span) (*Module, error) {
+	resolved, err := l.Resolver.Resolve(path, from.File)
+	if err != nil {
+		return nil, diagnostic.Errorf(CodeModuleNotFound, from, "cannot import %q: %s", path, err)
+	}
+
+	if module, ok := l.modules[resolved]; ok {
+		return module, nil
+	}
+
+	for i, loading := range l.loading {
+		if loading == resolved {
+			cycle := append(append([]string{}, l.loading[i:]...), resolved)
+			return nil, diagnostic.Errorf(CodeImportCycle, from, "import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	l.loading = append(l.loading, resolved)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	source, err := os.ReadFile(resolved)
+	if err != nil {
+		return nil, diagnostic.Errorf(CodeModuleNotFound, from, "cannot import %q: %s", path, err)
+	}
+	l.sources[resolved] = string(source)
+
+	p := parser.New(lexer.NewWithFilename(resolved, string(source)))
+	program := p.ParseProgram()
+	if diagnostic.HasErrors(p.Diagnostics()) {
+		return nil, &ParseError{Path: resolved, Source: string(source), Diagnostics: p.Diagnostics()}
+	}
+
+	module := &Module{
+		Path:    resolved,
+		Source:  string(source),
+		Program: program,
+		Exports: exports(program),
+		Macros:  l.newMacroEnv(),
+		macros:  macroNames(program),
+	}
+
+	expanded, err := l.Expand(program, module.Macros)
+	if err != nil {
+		return nil, err
+	}
+	module.Program = expanded.(*ast.Program)
+
+	l.modules[resolved] = module
+	return module, nil
+}
+
+func (l *Loader) newMacroEnv() *object.Environment {
+	if l.macroEnv == nil {
+		return object.NewEnvironment()
+	}
+	return l.macroEnv.NewScoped()
+}
+
+// Expand expands the macros of program, as evaluator.DefineMacros and
+// evaluator.ExpandMacros do, with the macros of the modules it binds at the
+// top level being available too. Every module the expanded program imports
+// is loaded.
+func (l *Loader) Expand(program *ast.Program, macroEnv *object.Environment) (ast.Node, error) {
+	for _, statement := range program.Statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+		imp, ok := let.Value.(*ast.ImportExpression)
+		if !ok {
+			continue
+		}
+
+		module, err := l.Load(imp.Path(), imp.Span())
+		if err != nil {
+			return nil, err
+		}
+		macroEnv.Define(let.Name.Value, module.exportedMacros())
+	}
+
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	// Loading whatever else is imported, so that missing modules and cycles
+	// are reported before anything runs.
+	_, err = ast.Modify(expanded, func(node ast.Node) (ast.Node, error) {
+		if imp, ok := node.(*ast.ImportExpression); ok {
+			if _, err := l.Load(imp.Path(), imp.Span()); err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return expanded, nil
+}
+
+// exportedMacros returns the macros of the module, in a hash by name.
+func (m *Module) exportedMacros() *object.Hash {
+	macros := object.NewHash()
+	for _, name := range m.macros {
+		macro, ok := m.Macros.Get(name)
+		if !ok {
+			continue
+		}
+
+		key := &object.String{Value: name}
+		hashKey, _ := key.HashKey()
+		macros.Set(hashKey, object.HashPair{Key: key, Value: macro})
+	}
+	return macros
+}
+
+func exports(program *ast.Program) []string {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, statement := range program.Statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok || !isExported(let.Name.Value) || seen[let.Name.Value] {
+			continue
+		}
+		if _, isMacro := let.Value.(*ast.MacroLiteral); isMacro {
+			continue
+		}
+
+		seen[let.Name.Value] = true
+		names = append(names, let.Name.Value)
+	}
+
+	return names
+}
+
+func macroNames(program *ast.Program) []string {
+	names := []string{}
+	for _, statement := range program.Statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok || !isExported(let.Name.Value) {
+			continue
+		}
+		if _, isMacro := let.Value.(*ast.MacroLiteral); isMacro {
+			names = append(names, let.Name.Value)
+		}
+	}
+	return names
+}
+
+func isExported(name string) bool {
+	return !strings.HasPrefix(name, "_")
+}
@@ -0,0 +1,163 @@
+package module_test
+
+import (
+	"errors"
+	"monkey/diagnostic"
+	"monkey/module"
+	"monkey/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the files into a temporary directory, returning it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestResolve(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.monkey":        "",
+		"local.monkey":       "",
+		"lib/local.monkey":   "",
+		"lib/shared.monkey":  "",
+		"std/strings.monkey": "",
+	})
+	importer := filepath.Join(dir, "lib", "main.monkey")
+
+	resolver := module.Resolver{SearchPaths: []string{filepath.Join(dir, "std"), dir}}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"local.monkey", "lib/local.monkey"},
+		{"./shared.monkey", "lib/shared.monkey"},
+		{"../local.monkey", "local.monkey"},
+		{"strings.monkey", "std/strings.monkey"},
+		{"main.monkey", "main.monkey"},
+		{filepath.Join(dir, "local.monkey"), "local.monkey"},
+	}
+
+	for _, tt := range tests {
+		resolved, err := resolver.Resolve(tt.path, importer)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %s", tt.path, err)
+			continue
+		}
+
+		if expected := filepath.Join(dir, tt.expected); resolved != expected {
+			t.Errorf("Resolve(%q) wrong. got = %s, want = %s", tt.path, resolved, expected)
+		}
+	}
+
+	for _, path := range []string{"missing.monkey", "./strings.monkey", "lib"} {
+		if _, err := resolver.Resolve(path, importer); err == nil {
+			t.Errorf("Resolve(%q) should have failed", path)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib.monkey": `
+			let helpers = import("helpers.monkey");
+			let _private = 1;
+			let answer = 42;
+			let twice = macro(x) { quote(unquote(x) * 2) };
+			let answer = helpers["twice"](21);
+		`,
+		"helpers.monkey": `let twice = macro(x) { quote(unquote(x) + unquote(x)) };`,
+	})
+
+	loader := module.NewLoader()
+	from := token.Span{File: filepath.Join(dir, "main.monkey")} //nolint:exhaustruct
+
+	lib, err := loader.Load("lib.monkey", from)
+	if err != nil {
+		t.Fatalf("Load failed: %s", err)
+	}
+
+	if !reflect.DeepEqual(lib.Exports, []string{"helpers", "answer"}) {
+		t.Errorf("wrong exports. got = %v", lib.Exports)
+	}
+
+	// The macro imported from helpers.monkey is expanded.
+	if last := lib.Program.Statements[len(lib.Program.Statements)-1].String(); last != "(let answer (infix 21 + 21))" {
+		t.Errorf("wrong expansion. got = %s", last)
+	}
+
+	again, err := loader.Load("./lib.monkey", from)
+	if err != nil || again != lib {
+		t.Errorf("the module was loaded again. err = %v", err)
+	}
+
+	if source, ok := loader.Source(filepath.Join(dir, "helpers.monkey")); !ok || !strings.Contains(source, "let twice") {
+		t.Errorf("wrong source of helpers.monkey. got = %q", source)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.monkey":       `let b = import("b.monkey");`,
+		"b.monkey":       `let c = fn() { import("a.monkey") };`,
+		"broken.monkey":  `let = 1;`,
+		"missing.monkey": `let x = import("nowhere.monkey");`,
+	})
+	from := token.Span{File: filepath.Join(dir, "main.monkey")} //nolint:exhaustruct
+
+	t.Run("cycle", func(t *testing.T) {
+		_, err := module.NewLoader().Load("a.monkey", from)
+
+		var d diagnostic.Diagnostic
+		if !errors.As(err, &d) || d.Code != module.CodeImportCycle {
+			t.Fatalf("expected an import cycle, got = %v", err)
+		}
+
+		a, b := filepath.Join(dir, "a.monkey"), filepath.Join(dir, "b.monkey")
+		if expected := "import cycle: " + a + " -> " + b + " -> " + a; d.Message != expected {
+			t.Errorf("wrong message. got = %q, want = %q", d.Message, expected)
+		}
+		if d.Span.File != b {
+			t.Errorf("the error should point at the import in b.monkey. got = %s", d.Span)
+		}
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		_, err := module.NewLoader().Load("broken.monkey", from)
+
+		var parseErr *module.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("expected a *module.ParseError, got = %T (%v)", err, err)
+		}
+		if parseErr.Source != "let = 1;" || parseErr.Diagnostics[0].Span.File != filepath.Join(dir, "broken.monkey") {
+			t.Errorf("wrong error. got = %+v", parseErr)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := module.NewLoader().Load("missing.monkey", from)
+
+		var d diagnostic.Diagnostic
+		if !errors.As(err, &d) || d.Code != module.CodeModuleNotFound {
+			t.Fatalf("expected a missing module, got = %v", err)
+		}
+		if !strings.HasPrefix(d.Message, `cannot import "nowhere.monkey": module "nowhere.monkey" not found`) {
+			t.Errorf("wrong message. got = %q", d.Message)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package module
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SearchPathEnv is the environment variable listing the directories modules
+// are searched in, separated as in PATH.
+const SearchPathEnv = "MONKEYPATH"
+
+// Resolver finds the file of an imported module.
+//
+// Paths starting with "./" or "../" are relative to the directory of the
+// importing file. Other relative paths are looked up in that directory too,
+// and then in each of the SearchPaths in order.
+type Resolver struct {
+	SearchPaths []string
+}
+
+// SearchPathsFromEnv returns the search paths listed in $MONKEYPATH.
+func SearchPathsFromEnv() []string {
+	paths := []string{}
+	for _, path := range filepath.SplitList(os.Getenv(SearchPathEnv)) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Resolve returns the absolute path of the module imported as path from the
+// file importer, which is empty for code that isn't read from a file (e.g. in
+// the REPL), in which case the working directory stands for its directory.
+func (r Resolver) Resolve(path string, importer string) (string, error) {
+	if filepath.IsAbs(path) {
+		if !isFile(path) {
+			return "", fmt.Errorf("module %q not found", path)
+		}
+		return filepath.Clean(path), nil
+	}
+
+	dirs := []string{filepath.Dir(importer)}
+	if importer == "" {
+		dirs[0] = "."
+	}
+	if !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") {
+		dirs = append(dirs, r.SearchPaths...)
+	}
+
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, path)
+		if isFile(candidate) {
+			return filepath.Abs(candidate)
+		}
+	}
+
+	return "", fmt.Errorf("module %q not found, searched in: %s", path, strings.Join(dirs, ", "))
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
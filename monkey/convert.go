@@ -0,0 +1,168 @@
+package monkey
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"monkey/object"
+	"reflect"
+	"slices"
+)
+
+// ToObject converts a Go value into its monkey counterpart:
+//
+//	nil                       -> NULL
+//	bool                      -> BOOLEAN
+//	int, int8, ..., uint64    -> INTEGER
+//	float32, float64          -> FLOAT
+//	string                    -> STRING
+//	slices and arrays         -> ARRAY
+//	maps                      -> HASH, keys ordered as they're printed
+//
+// Values that already are an object.Object are returned as they are.
+func ToObject(value any) (object.Object, error) {
+	if obj, ok := value.(object.Object); ok {
+		return obj, nil
+	}
+	if value == nil {
+		return &object.CONST_NULL, nil
+	}
+
+	v := reflect.ValueOf(value)
+
+	//exhaustive:ignore
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return &object.CONST_TRUE, nil
+		}
+		return &object.CONST_FALSE, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows an %s", v.Uint(), object.INTEGER_OBJ)
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return &object.CONST_NULL, nil
+		}
+
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := ToObject(v.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return &object.CONST_NULL, nil
+		}
+
+		pairs := make([]object.HashPair, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := ToObject(iter.Key().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %w", iter.Key(), err)
+			}
+
+			if _, err := key.HashKey(); err != nil {
+				return nil, fmt.Errorf("key %v: %w", iter.Key(), err)
+			}
+
+			value, err := ToObject(iter.Value().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("value of key %v: %w", iter.Key(), err)
+			}
+
+			pairs = append(pairs, object.HashPair{Key: key, Value: value})
+		}
+
+		// Go maps have no order, the hash gets one that doesn't change
+		// from one conversion to the next.
+		slices.SortFunc(pairs, func(a, b object.HashPair) int {
+			return cmp.Compare(a.Key.Inspect(), b.Key.Inspect())
+		})
+		hash := object.NewHash()
+		for _, pair := range pairs {
+			hashKey, _ := pair.Key.HashKey()
+			hash.Set(hashKey, pair)
+		}
+		return hash, nil
+
+	default:
+		return nil, fmt.Errorf("values of type %T cannot be converted into monkey", value)
+	}
+}
+
+// FromObject converts a monkey value into its Go counterpart:
+//
+//	NULL    -> nil
+//	BOOLEAN -> bool
+//	INTEGER -> int64
+//	FLOAT   -> float64
+//	STRING  -> string
+//	ARRAY   -> []any
+//	HASH    -> map[any]any
+//
+// Other values, such as functions, have no Go counterpart.
+func FromObject(obj object.Object) (any, error) {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+
+	case *object.Array:
+		elements := make([]any, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := FromObject(element)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			elements[i] = value
+		}
+		return elements, nil
+
+	case *object.Hash:
+		pairs := make(map[any]any, obj.Len())
+		for _, pair := range obj.Ordered() {
+			key, err := FromObject(pair.Key)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+
+			value, err := FromObject(pair.Value)
+			if err != nil {
+				return nil, fmt.Errorf("value of key %s: %w", pair.Key.Inspect(), err)
+			}
+
+			pairs[key] = value
+		}
+		return pairs, nil
+
+	default:
+		return nil, fmt.Errorf("values of type %s cannot be converted into Go", obj.Type())
+	}
+}
@@ -0,0 +1,118 @@
+package monkey_test
+
+import (
+	"monkey/monkey"
+	"monkey/object"
+	"reflect"
+	"testing"
+)
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{2.5, "2.5"},
+		{float32(0.5), "0.5"},
+		{"monkey", "monkey"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{[]any{1, "two", []bool{true}}, "[1, two, [true]]"},
+		{map[string]int{"one": 1}, "{one: 1}"},
+		{&object.Integer{Value: 3}, "3"},
+	}
+
+	for _, tt := range tests {
+		obj, err := monkey.ToObject(tt.value)
+		if err != nil {
+			t.Errorf("ToObject(%#v) failed: %s", tt.value, err)
+			continue
+		}
+
+		if obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong. got = %s, want = %s", tt.value, obj.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestToObjectErrors(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected string
+	}{
+		{uint64(1 << 63), "9223372036854775808 overflows an INTEGER"},
+		{struct{}{}, "values of type struct {} cannot be converted into monkey"},
+		{[]any{1, make(chan int)}, "element 1: values of type chan int cannot be converted into monkey"},
+		{map[[1]int]int{{1}: 1}, "key [1]: unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		_, err := monkey.ToObject(tt.value)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong error. got = %v, want = %s", tt.value, err, tt.expected)
+		}
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	tests := []struct {
+		obj      object.Object
+		expected any
+	}{
+		{&object.CONST_NULL, nil},
+		{&object.CONST_FALSE, false},
+		{&object.Integer{Value: 5}, int64(5)},
+		{&object.Float{Value: 0.25}, 0.25},
+		{&object.String{Value: "s"}, "s"},
+		{
+			&object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.String{Value: "a"}}},
+			[]any{int64(1), "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		value, err := monkey.FromObject(tt.obj)
+		if err != nil {
+			t.Errorf("FromObject(%s) failed: %s", tt.obj.Inspect(), err)
+			continue
+		}
+
+		if !reflect.DeepEqual(value, tt.expected) {
+			t.Errorf("FromObject(%s) wrong. got = %#v, want = %#v", tt.obj.Inspect(), value, tt.expected)
+		}
+	}
+
+	if _, err := monkey.FromObject(&object.Builtin{}); err == nil {
+		t.Errorf("expected an error converting a builtin")
+	}
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	interp := &monkey.Interpreter{}
+	if _, err := interp.Eval(`let describe = fn(config) { [config["name"], config["limits"][1] * 2] };`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	config, err := monkey.ToObject(map[string]any{"name": "svc", "limits": []float64{0.5, 1.5}})
+	if err != nil {
+		t.Fatalf("ToObject failed: %s", err)
+	}
+
+	result, err := interp.Call("describe", config)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	value, err := monkey.FromObject(result)
+	if err != nil {
+		t.Fatalf("FromObject failed: %s", err)
+	}
+
+	if !reflect.DeepEqual(value, []any{"svc", 3.0}) {
+		t.Errorf("wrong result. got = %#v", value)
+	}
+}
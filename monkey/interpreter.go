@@ -0,0 +1,439 @@
+// monkey is the API for embedding the monkey language into Go programs:
+//
+//	interp := &monkey.Interpreter{}
+//	if _, err := interp.Eval(`let double = fn(x) { x * 2 };`); err != nil {
+//		return err
+//	}
+//	result, err := interp.Call("double", &object.Integer{Value: 21})
+//
+// It runs the whole pipeline (lexing, parsing, expanding macros, compiling and
+// running) on either of the engines.
+package monkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/diagnostic"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/module"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"monkey/vm"
+	"slices"
+)
+
+type Engine string
+
+const (
+	EngineVM   Engine = "vm"
+	EngineTree Engine = "tree"
+)
+
+// Interpreter runs monkey code, keeping its state (globals, macros...) from one
+// call to the next, so code can use everything the code before it defined.
+//
+// The zero value is ready to use, running on the vm. An Interpreter must not be
+// used concurrently.
+type Interpreter struct {
+	// Engine runs the code, EngineVM if empty. It must not change once the
+	// interpreter has been used.
+	Engine Engine
+
+	// Stdout receives what the scripts print, that of ExecContext if nil.
+	Stdout io.Writer
+
+	// Stderr, if set, receives every error returned rendered for humans,
+	// quoting the offending source.
+	Stderr io.Writer
+
+	// Globals are defined for the scripts before the first evaluation.
+	Globals map[string]object.Object
+
+	// SearchPaths are the directories modules are imported from, after the
+	// working directory.
+	SearchPaths []string
+
+	// ExecContext is what builtins reach outside of the scripts through, the
+	// process itself if nil. It must not change once the interpreter has been
+	// used.
+	ExecContext *object.ExecContext
+
+	// Limits bound each evaluation, along with its macros, and each call; if
+	// zero, none but a depth of object.DefaultMaxDepth. Exceeding one of them
+	// fails with a *RuntimeError wrapping an *object.LimitExceededError.
+	Limits object.Limits
+
+	initialized bool
+	macroEnv    *object.Environment
+	loader      *module.Loader
+	builtins    *object.BuiltinRegistry
+	exec        *object.ExecContext
+
+	// State of EngineTree.
+	env *object.Environment
+
+	// State of EngineVM.
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+	modules     *compiler.Modules
+}
+
+// ParseError is returned when the source doesn't parse.
+type ParseError struct {
+	Diagnostics []diagnostic.Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	if len(e.Diagnostics) == 1 {
+		return e.Diagnostics[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e.Diagnostics[0].Error(), len(e.Diagnostics)-1)
+}
+
+// RuntimeError is returned when a script fails while running, whichever the
+// engine.
+type RuntimeError struct {
+	Message string
+	Span    token.Span
+
+	// Err is the error the engine failed with, if it wasn't raised by the
+	// script itself: e.g. an *object.LimitExceededError, or the error of the
+	// context.
+	Err error
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Span.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Span, e.Message)
+	}
+	return e.Message
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// Eval runs src, returning the value of its last statement if that's an
+// expression, and NULL otherwise.
+//
+// Errors are a *ParseError, a diagnostic.Diagnostic for failures to expand
+// macros or to compile, or a *RuntimeError.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	return i.EvalContext(context.Background(), src)
+}
+
+// EvalContext runs src as Eval does, stopping once ctx is done with a
+// *RuntimeError wrapping the error of ctx.
+func (i *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	i.init()
+
+	result, err := i.eval(ctx, src)
+	if err != nil {
+		i.report(src, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// Call calls the function (or builtin) defined globally as fnName.
+func (i *Interpreter) Call(fnName string, args ...object.Object) (object.Object, error) {
+	return i.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext calls the function defined globally as fnName as Call does,
+// stopping once ctx is done.
+func (i *Interpreter) CallContext(ctx context.Context, fnName string, args ...object.Object) (object.Object, error) {
+	i.init()
+
+	fn, ok := i.GetGlobal(fnName)
+	if !ok {
+		return nil, fmt.Errorf("undefined function: %s", fnName)
+	}
+
+	var result object.Object
+	var err error
+	if i.engine() == EngineTree {
+		budget := object.NewBudget(ctx, i.Limits)
+		i.env.SetBudget(budget)
+		result, err = toResult(evaluator.ApplyWith(i.exec, fn, args...))
+		i.env.SetBudget(nil)
+		if budgetErr := budget.Err(); budgetErr != nil {
+			err = &RuntimeError{Message: budgetErr.Error(), Span: token.Span{}, Err: budgetErr}
+		}
+	} else {
+		result, err = i.callVM(ctx, fn, args)
+	}
+
+	if err != nil {
+		i.report("", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetGlobal defines name for the scripts, or changes its value if it's already
+// defined.
+func (i *Interpreter) SetGlobal(name string, value object.Object) {
+	i.init()
+	i.setGlobal(name, value)
+}
+
+// RegisterBuiltin adds a builtin implemented in Go for the scripts evaluated
+// afterwards, on either engine. The arguments are checked against sig before
+// fn is called with them. Globals of the same name take precedence over it.
+func (i *Interpreter) RegisterBuiltin(sig object.Signature, fn object.BuiltinFunction) error {
+	i.init()
+
+	if err := i.builtins.Register(sig, fn); err != nil {
+		return err
+	}
+
+	if i.engine() == EngineVM {
+		if _, ok := i.symbolTable.Resolve(sig.Name); !ok {
+			i.symbolTable.DefineBuiltin(len(i.builtins.All())-1, sig.Name)
+		}
+	}
+	return nil
+}
+
+// GetGlobal returns the value of the global name, which may be a builtin.
+func (i *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	i.init()
+
+	if i.engine() == EngineTree {
+		if value, ok := i.env.Get(name); ok {
+			return value, true
+		}
+		if builtin, ok := i.builtins.Lookup(name); ok {
+			return builtin, true
+		}
+		return nil, false
+	}
+
+	symbol, ok := i.symbolTable.Resolve(name)
+	if !ok {
+		return nil, false
+	}
+
+	switch symbol.Scope {
+	case compiler.GlobalScope:
+		return i.globals[symbol.Index], i.globals[symbol.Index] != nil
+	case compiler.BuiltinScope:
+		return i.builtins.At(symbol.Index)
+	default:
+		return nil, false
+	}
+}
+
+func (i *Interpreter) engine() Engine {
+	if i.Engine == "" {
+		return EngineVM
+	}
+	return i.Engine
+}
+
+func (i *Interpreter) init() {
+	if i.initialized {
+		return
+	}
+	i.initialized = true
+
+	i.macroEnv = object.NewEnvironment()
+	i.loader = module.NewLoader(i.SearchPaths...)
+	i.builtins = object.NewBuiltinRegistry()
+	i.macroEnv.SetBuiltins(i.builtins)
+
+	exec := *i.ExecContext.OrDefault()
+	if i.Stdout != nil {
+		exec.Stdout = i.Stdout
+	}
+	i.exec = &exec
+	i.macroEnv.SetExecContext(i.exec)
+	i.loader.SetMacroEnvironment(i.macroEnv)
+
+	if i.engine() == EngineTree {
+		importer := module.NewImporter(i.loader)
+		importer.SetBuiltins(i.builtins)
+		importer.SetExecContext(i.exec)
+		i.env = object.NewEnvironment()
+		i.env.SetImporter(importer)
+		i.env.SetBuiltins(i.builtins)
+		i.env.SetExecContext(i.exec)
+	} else {
+		i.symbolTable = compiler.NewSymbolTable()
+		for idx, builtin := range i.builtins.All() {
+			i.symbolTable.DefineBuiltin(idx, builtin.Name)
+		}
+		i.constants = []object.Object{}
+		i.globals = vm.InitGlobalsArray()
+		i.modules = compiler.NewModules(i.loader)
+	}
+
+	for name, value := range i.Globals {
+		i.setGlobal(name, value)
+	}
+}
+
+func (i *Interpreter) setGlobal(name string, value object.Object) {
+	if i.engine() == EngineTree {
+		i.env.Define(name, value)
+		return
+	}
+
+	symbol, ok := i.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		symbol = i.symbolTable.Define(name)
+	}
+	i.globals[symbol.Index] = value
+}
+
+func (i *Interpreter) eval(ctx context.Context, src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if diagnostic.HasErrors(p.Diagnostics()) {
+		return nil, &ParseError{Diagnostics: p.Diagnostics()}
+	}
+
+	budget := object.NewBudget(ctx, i.Limits)
+	i.macroEnv.SetBudget(budget)
+	_, err := i.loader.Expand(program, i.macroEnv)
+	i.macroEnv.SetBudget(nil)
+	if budgetErr := budget.Err(); budgetErr != nil {
+		return nil, &RuntimeError{Message: budgetErr.Error(), Span: token.Span{}, Err: budgetErr}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if i.engine() == EngineTree {
+		result, err := evaluator.EvalContext(ctx, program, i.env, i.Limits)
+		if err != nil {
+			return nil, &RuntimeError{Message: err.Error(), Span: token.Span{}, Err: err}
+		}
+		return toResult(result)
+	}
+	return i.evalVM(ctx, program)
+}
+
+func (i *Interpreter) evalVM(ctx context.Context, program *ast.Program) (object.Object, error) {
+	// Failing to compile leaves nothing of src behind, and failing to run
+	// leaves the globals that were set, as the tree engine does.
+	symbols, constants := i.symbolTable.Copy(), i.constants
+
+	comp := compiler.NewWithState(i.symbolTable, i.constants)
+	comp.SetModules(i.modules)
+	if err := comp.Compile(program); err != nil {
+		i.symbolTable, i.constants = symbols, constants
+		return nil, err
+	}
+
+	bytecode := comp.Bytecode()
+	i.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalState(bytecode, i.globals)
+	machine.SetBuiltins(i.builtins)
+	machine.SetExecContext(i.exec)
+	machine.SetLimits(i.Limits)
+	machine.SetGlobalNames(comp.GlobalNames())
+	if err := machine.RunContext(ctx); err != nil {
+		i.symbolTable.Rollback(symbols, func(symbol compiler.Symbol) bool {
+			return i.globals[symbol.Index] != nil
+		})
+		return nil, vmRuntimeError(err)
+	}
+
+	if len(program.Statements) == 0 {
+		return &object.CONST_NULL, nil
+	}
+	if _, ok := program.Statements[len(program.Statements)-1].(*ast.ExpressionStatement); !ok {
+		return &object.CONST_NULL, nil
+	}
+	// Builtins fail by returning an error, which is one like any other.
+	return toResult(machine.LastPoppedStackElem())
+}
+
+// callVM calls fn by running a tiny program made of a single call, with the
+// function and the arguments as its constants.
+func (i *Interpreter) callVM(ctx context.Context, fn object.Object, args []object.Object) (object.Object, error) {
+	constants := append(slices.Clip(i.constants), fn)
+	constants = append(constants, args...)
+
+	instructions := code.Make(code.OpConstant, len(i.constants))
+	for argIdx := range args {
+		instructions = append(instructions, code.Make(code.OpConstant, len(i.constants)+1+argIdx)...)
+	}
+	instructions = append(instructions, code.Make(code.OpCall, len(args))...)
+	instructions = append(instructions, code.Make(code.OpPop)...)
+
+	bytecode := &compiler.Bytecode{Instructions: instructions, Constants: constants} //nolint:exhaustruct
+	machine := vm.NewWithGlobalState(bytecode, i.globals)
+	machine.SetBuiltins(i.builtins)
+	machine.SetExecContext(i.exec)
+	machine.SetLimits(i.Limits)
+	if err := machine.RunContext(ctx); err != nil {
+		return nil, vmRuntimeError(err)
+	}
+
+	return toResult(machine.LastPoppedStackElem())
+}
+
+// toResult returns the result of a script, or its error if it's an ERROR.
+func toResult(result object.Object) (object.Object, error) {
+	switch result := result.(type) {
+	case nil:
+		return &object.CONST_NULL, nil
+	case *object.Error:
+		return nil, &RuntimeError{Message: result.Message, Span: result.Span, Err: nil}
+	default:
+		return result, nil
+	}
+}
+
+func vmRuntimeError(err error) error {
+	var runErr *vm.VmRunError
+	if errors.As(err, &runErr) {
+		return &RuntimeError{Message: runErr.Err.Error(), Span: runErr.Span, Err: runErr.Err}
+	}
+	return &RuntimeError{Message: err.Error(), Span: token.Span{}, Err: err}
+}
+
+// report renders err to Stderr, if set.
+func (i *Interpreter) report(src string, err error) {
+	if i.Stderr == nil {
+		return
+	}
+
+	var parseErr *ParseError
+	var moduleParseErr *module.ParseError
+	var d diagnostic.Diagnostic
+	var runtimeErr *RuntimeError
+	switch {
+	case errors.As(err, &parseErr):
+		diagnostic.FprintAll(i.Stderr, src, parseErr.Diagnostics)
+	case errors.As(err, &moduleParseErr):
+		diagnostic.FprintAll(i.Stderr, moduleParseErr.Source, moduleParseErr.Diagnostics)
+	case errors.As(err, &d):
+		diagnostic.Fprint(i.Stderr, i.source(src, d.Span), d)
+	case errors.As(err, &runtimeErr) && runtimeErr.Span.IsValid():
+		d := diagnostic.Errorf("", runtimeErr.Span, "%s", runtimeErr.Message)
+		diagnostic.Fprint(i.Stderr, i.source(src, runtimeErr.Span), d)
+	default:
+		fmt.Fprintf(i.Stderr, "error: %s\n", err)
+	}
+}
+
+// source returns the source span points into: src, unless it's in a module.
+func (i *Interpreter) source(src string, span token.Span) string {
+	if moduleSource, ok := i.loader.Source(span.File); ok {
+		return moduleSource
+	}
+	return src
+}
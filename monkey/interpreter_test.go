@@ -0,0 +1,430 @@
+package monkey_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"monkey/diagnostic"
+	"monkey/monkey"
+	"monkey/object"
+	"strings"
+	"testing"
+)
+
+var engines = []monkey.Engine{monkey.EngineVM, monkey.EngineTree}
+
+func forEachEngine(t *testing.T, test func(t *testing.T, engine monkey.Engine)) {
+	t.Helper()
+
+	for _, engine := range engines {
+		t.Run(string(engine), func(t *testing.T) {
+			test(t, engine)
+		})
+	}
+}
+
+func TestInterpreterEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2", "3"},
+		{"let x = 5;", "null"},
+		{"", "null"},
+		{`let greet = fn(name) { "hello " + name }; greet("monkey")`, "hello monkey"},
+		{"let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; unless(false, 1, 2)", "1"},
+		{"return 7; 8", "7"},
+	}
+
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		for _, tt := range tests {
+			t.Run(tt.input, func(t *testing.T) {
+				interp := &monkey.Interpreter{Engine: engine}
+
+				result, err := interp.Eval(tt.input)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+
+				if result.Inspect() != tt.expected {
+					t.Errorf("wrong result. got = %s, want = %s", result.Inspect(), tt.expected)
+				}
+			})
+		}
+	})
+}
+
+func TestInterpreterKeepsState(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		interp := &monkey.Interpreter{Engine: engine}
+
+		steps := []string{
+			"let total = 0;",
+			"let add = fn(n) { total = total + n; total };",
+			"let twice = macro(x) { quote(unquote(x) * 2) };",
+			"add(twice(5))",
+			"add(1)",
+		}
+
+		var result object.Object
+		for _, step := range steps {
+			var err error
+			if result, err = interp.Eval(step); err != nil {
+				t.Fatalf("unexpected error in %q: %s", step, err)
+			}
+		}
+
+		if result.Inspect() != "11" {
+			t.Errorf("wrong result. got = %s, want = 11", result.Inspect())
+		}
+	})
+}
+
+func TestInterpreterCall(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		interp := &monkey.Interpreter{Engine: engine}
+
+		if _, err := interp.Eval(`let offset = 100; let add = fn(a, b) { a + b + offset };`); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		result, err := interp.Call("add", &object.Integer{Value: 1}, &object.Integer{Value: 2})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result.Inspect() != "103" {
+			t.Errorf("wrong result. got = %s, want = 103", result.Inspect())
+		}
+
+		result, err = interp.Call("len", &object.String{Value: "four"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result.Inspect() != "4" {
+			t.Errorf("wrong result. got = %s, want = 4", result.Inspect())
+		}
+
+		if _, err := interp.Call("missing"); err == nil || err.Error() != "undefined function: missing" {
+			t.Errorf("wrong error calling an undefined function. got = %v", err)
+		}
+
+		_, err = interp.Call("add", &object.Integer{Value: 1})
+		var runtimeErr *monkey.RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Fatalf("expected a *monkey.RuntimeError, got = %T (%v)", err, err)
+		}
+		if runtimeErr.Message != "wrong number of arguments: want = 2, got = 1" {
+			t.Errorf("wrong error message. got = %q", runtimeErr.Message)
+		}
+	})
+}
+
+func TestInterpreterGlobals(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		interp := &monkey.Interpreter{
+			Engine:  engine,
+			Globals: map[string]object.Object{"rate": &object.Float{Value: 0.5}},
+		}
+
+		interp.SetGlobal("base", &object.Integer{Value: 10})
+		interp.SetGlobal("double", &object.Builtin{Fn: func(_ *object.ExecContext, args ...object.Object) object.Object {
+			return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+		}})
+
+		result, err := interp.Eval("double(base) * rate")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result.Inspect() != "10.0" {
+			t.Errorf("wrong result. got = %s, want = 10.0", result.Inspect())
+		}
+
+		interp.SetGlobal("base", &object.Integer{Value: 1})
+		if _, err := interp.Eval("let seen = base;"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		seen, ok := interp.GetGlobal("seen")
+		if !ok || seen.Inspect() != "1" {
+			t.Errorf("wrong global. got = %v (defined = %t), want = 1", seen, ok)
+		}
+
+		if _, ok := interp.GetGlobal("undefined"); ok {
+			t.Errorf("got a value for an undefined global")
+		}
+	})
+}
+
+func TestInterpreterRegisterBuiltin(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		interp := &monkey.Interpreter{Engine: engine}
+
+		err := interp.RegisterBuiltin(object.Signature{
+			Name:    "stutter",
+			Params:  []object.Param{{Name: "s", Types: []object.ObjectType{object.STRING_OBJ}}, {Name: "n", Types: []object.ObjectType{object.INTEGER_OBJ}}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Repeats s n times.",
+			Usage:   "",
+		}, func(_ *object.ExecContext, args ...object.Object) object.Object {
+			return &object.String{Value: strings.Repeat(args[0].(*object.String).Value, int(args[1].(*object.Integer).Value))}
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		result, err := interp.Eval(`let twice = fn(s) { stutter(s, 2) }; twice("ab")`)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result.Inspect() != "abab" {
+			t.Errorf("wrong result. got = %s, want = abab", result.Inspect())
+		}
+
+		_, err = interp.Eval(`stutter("ab", "2"); 1`)
+		var runtimeErr *monkey.RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Fatalf("expected a *monkey.RuntimeError, got = %T (%v)", err, err)
+		}
+		if want := "second argument to `stutter` must be INTEGER, got STRING"; runtimeErr.Message != want {
+			t.Errorf("wrong error. got = %q, want = %q", runtimeErr.Message, want)
+		}
+
+		if _, ok := interp.GetGlobal("stutter"); !ok {
+			t.Errorf("registered builtin not found")
+		}
+		if err := interp.RegisterBuiltin(object.Signature{Name: "len"}, nil); err == nil { //nolint:exhaustruct
+			t.Errorf("registering a builtin twice succeeded")
+		}
+
+		// Other interpreters have the standard builtins only.
+		other := &monkey.Interpreter{Engine: engine}
+		if _, err := other.Eval(`stutter("ab", 2)`); err == nil {
+			t.Errorf("builtin registered on another interpreter")
+		}
+	})
+}
+
+func TestInterpreterLimits(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		interp := &monkey.Interpreter{Engine: engine, Limits: object.Limits{MaxInstructions: 10000, MaxDepth: 50}} //nolint:exhaustruct
+		if _, err := interp.Eval(`let loop = fn(n) { loop(n + 1) };`); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		_, err := interp.Call("loop", &object.Integer{Value: 0})
+		var limitErr *object.LimitExceededError
+		if !errors.As(err, &limitErr) || limitErr.Limit != object.DepthLimit {
+			t.Errorf("expected the call depth limit to be exceeded, got = %v", err)
+		}
+
+		_, err = interp.Eval(`let i = 0; while (true) { i += 1 }`)
+		if !errors.As(err, &limitErr) || limitErr.Limit != object.InstructionLimit {
+			t.Errorf("expected the instruction limit to be exceeded, got = %v", err)
+		}
+
+		// Each evaluation has a budget of its own.
+		result, err := interp.Eval(`i > 0`)
+		if err != nil || result.Inspect() != "true" {
+			t.Errorf("wrong result. got = %v (%v), want = true", result, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		unlimited := &monkey.Interpreter{Engine: engine}
+		if _, err := unlimited.EvalContext(ctx, `while (true) {}`); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the evaluation to be canceled, got = %v", err)
+		}
+	})
+}
+
+func TestInterpreterMacroLimits(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		interp := &monkey.Interpreter{Engine: engine, Limits: object.Limits{MaxInstructions: 10000, MaxDepth: 50}} //nolint:exhaustruct
+		_, err := interp.Eval(`let spin = macro() { while (true) {}; quote(1) }; spin()`)
+		var limitErr *object.LimitExceededError
+		if !errors.As(err, &limitErr) || limitErr.Limit != object.InstructionLimit {
+			t.Errorf("expected the instruction limit to be exceeded, got = %v", err)
+		}
+
+		_, err = interp.Eval(`let deep = macro() { let f = fn() { f() }; f() }; deep()`)
+		if !errors.As(err, &limitErr) || limitErr.Limit != object.DepthLimit {
+			t.Errorf("expected the call depth limit to be exceeded, got = %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		unlimited := &monkey.Interpreter{Engine: engine}
+		if _, err := unlimited.EvalContext(ctx, `let spin = macro() { while (true) {}; quote(1) }; spin()`); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the expansion to be canceled, got = %v", err)
+		}
+	})
+}
+
+func TestInterpreterDefaultDepth(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		interp := &monkey.Interpreter{Engine: engine}
+		var runtimeErr *monkey.RuntimeError
+		if _, err := interp.Eval(`let f = fn() { f() }; f()`); !errors.As(err, &runtimeErr) {
+			t.Errorf("expected a runtime error, got = %v", err)
+		} else if !strings.Contains(err.Error(), "stack overflow") {
+			t.Errorf("wrong error. got = %q, want it to mention a stack overflow", err)
+		}
+
+		if _, err := interp.Eval(`let deep = macro() { let f = fn() { f() }; f() }; deep()`); !errors.Is(err, object.ErrStackOverflow) {
+			t.Errorf("expected the macro to overflow the stack, got = %v", err)
+		}
+	})
+}
+
+func TestInterpreterStdout(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		var out bytes.Buffer
+		interp := &monkey.Interpreter{Engine: engine, Stdout: &out}
+
+		if _, err := interp.Eval(`puts("hello"); puts("world")`); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if out.String() != "hello\nworld\n" {
+			t.Errorf("wrong output. got = %q", out.String())
+		}
+	})
+}
+
+func TestInterpreterExecContext(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		var out bytes.Buffer
+		interp := &monkey.Interpreter{
+			Engine: engine,
+			ExecContext: &object.ExecContext{ //nolint:exhaustruct
+				Stdout: &out,
+				Stdin:  strings.NewReader("monkey\n"),
+			},
+		}
+
+		if _, err := interp.Eval(`let greet = fn() { puts("hello", readline()) }; greet()`); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if out.String() != "hello monkey\n" {
+			t.Errorf("wrong output. got = %q", out.String())
+		}
+
+		// The clock was left out, so it's denied.
+		result, err := interp.Eval(`now()`)
+		if err == nil || !strings.Contains(err.Error(), "the clock is not available") {
+			t.Errorf("expected the clock to be denied, got = %v (%v)", result, err)
+		}
+	})
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		t.Run("parse", func(t *testing.T) {
+			_, err := (&monkey.Interpreter{Engine: engine}).Eval("let = 5;")
+
+			var parseErr *monkey.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected a *monkey.ParseError, got = %T (%v)", err, err)
+			}
+			if parseErr.Diagnostics[0].Code != "P0001" {
+				t.Errorf("wrong code. got = %q", parseErr.Diagnostics[0].Code)
+			}
+		})
+
+		t.Run("macro", func(t *testing.T) {
+			_, err := (&monkey.Interpreter{Engine: engine}).Eval("let m = macro() { 1 }; m()")
+
+			var d diagnostic.Diagnostic
+			if !errors.As(err, &d) {
+				t.Fatalf("expected a diagnostic, got = %T (%v)", err, err)
+			}
+		})
+
+		t.Run("assignment", func(t *testing.T) {
+			tests := []struct {
+				input    string
+				expected string
+			}{
+				{"len = 1", "1:1: cannot assign to len"},
+				{"let f = fn(n) {\n  f = 2;\n  n\n};\nf(1)", "2:3: cannot assign to f"},
+				{"let f = fn() {\n  let g = fn() { f += 1 };\n  g()\n};\nf()", "2:18: cannot assign to f"},
+			}
+
+			for _, tt := range tests {
+				_, err := (&monkey.Interpreter{Engine: engine}).Eval(tt.input)
+				if err == nil || err.Error() != tt.expected {
+					t.Errorf("wrong error of %q. got = %v, want = %q", tt.input, err, tt.expected)
+				}
+			}
+		})
+
+		t.Run("runtime", func(t *testing.T) {
+			var stderr bytes.Buffer
+			interp := &monkey.Interpreter{Engine: engine, Stderr: &stderr}
+
+			_, err := interp.Eval("let a = 1;\na / 0")
+
+			var runtimeErr *monkey.RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("expected a *monkey.RuntimeError, got = %T (%v)", err, err)
+			}
+			if err.Error() != "2:3: division by zero" {
+				t.Errorf("wrong error. got = %q", err.Error())
+			}
+			if !strings.Contains(stderr.String(), "2 | a / 0") {
+				t.Errorf("error not rendered to stderr. got = %q", stderr.String())
+			}
+
+			// The interpreter is still usable after a failure.
+			if result, err := interp.Eval("a + 1"); err != nil || result.Inspect() != "2" {
+				t.Errorf("interpreter broken after an error. got = %v, %v", result, err)
+			}
+		})
+
+		t.Run("builtin", func(t *testing.T) {
+			for _, src := range []string{`len(1)`, `let double = fn(x) { x * 2 }; double(len)`} {
+				_, err := (&monkey.Interpreter{Engine: engine}).Eval(src)
+				var runtimeErr *monkey.RuntimeError
+				if !errors.As(err, &runtimeErr) {
+					t.Errorf("%s: expected a *monkey.RuntimeError, got = %T (%v)", src, err, err)
+				}
+			}
+		})
+
+		t.Run("unset globals", func(t *testing.T) {
+			interp := &monkey.Interpreter{Engine: engine}
+
+			if _, err := interp.Eval("let a = 1; let b = 1 / 0;"); err == nil {
+				t.Fatalf("expected the division by zero to fail")
+			}
+			for _, src := range []string{"b + 1", "puts(b)", "[b][0]"} {
+				_, err := interp.Eval(src)
+				if err == nil || !strings.Contains(err.Error(), "b") {
+					t.Errorf("%s: expected b not to be found, got = %v", src, err)
+				}
+			}
+
+			if _, err := interp.Eval("let c = 1; let d = e;"); err == nil {
+				t.Fatalf("expected e not to be found")
+			}
+			if result, err := interp.Eval("a"); err != nil || result.Inspect() != "1" {
+				t.Errorf("wrong a. got = %v, %v", result, err)
+			}
+			if result, err := interp.Eval("let c = 2; c"); err != nil || result.Inspect() != "2" {
+				t.Errorf("wrong c. got = %v, %v", result, err)
+			}
+
+			_, err := interp.Eval("if (false) { let f = 1; }; f + 1")
+			if err == nil || !strings.Contains(err.Error(), "identifier not found: f") {
+				t.Errorf("expected f not to be found, got = %v", err)
+			}
+		})
+	})
+
+	_, err := (&monkey.Interpreter{Engine: monkey.EngineVM}).Eval("undefined + 1")
+	var d diagnostic.Diagnostic
+	if !errors.As(err, &d) || d.Error() != "1:1: undefined variable: undefined" {
+		t.Errorf("expected a compile error, got = %T (%v)", err, err)
+	}
+}
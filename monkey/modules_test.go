@@ -0,0 +1,100 @@
+package monkey_test
+
+import (
+	"errors"
+	"monkey/diagnostic"
+	"monkey/monkey"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpreterImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"counter.monkey": `
+			let _count = 0;
+			let next = fn() { _count += 1; _count };
+			let twice = macro(x) { quote(unquote(x) * 2) };
+		`,
+		"user.monkey": `
+			let counter = import("counter.monkey");
+			let bump = fn() { counter["next"]() };
+		`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		interp := &monkey.Interpreter{Engine: engine, SearchPaths: []string{dir}}
+
+		steps := []struct {
+			input    string
+			expected string
+		}{
+			{`let c = import("counter.monkey"); c["next"]()`, "1"},
+			// The module runs once, and keeps its globals between calls.
+			{`let u = import("user.monkey"); u["bump"]()`, "2"},
+			{`c["next"]() + import("counter.monkey")["next"]()`, "7"},
+			{`c["_count"]`, "null"},
+			{`c["twice"](21)`, "42"},
+		}
+
+		for _, step := range steps {
+			result, err := interp.Eval(step.input)
+			if err != nil {
+				t.Fatalf("unexpected error in %q: %s", step.input, err)
+			}
+			if result.Inspect() != step.expected {
+				t.Errorf("wrong result of %q. got = %s, want = %s", step.input, result.Inspect(), step.expected)
+			}
+		}
+	})
+}
+
+func TestInterpreterImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.monkey":       `let b = import("b.monkey");`,
+		"b.monkey":       `let a = import("a.monkey");`,
+		"failing.monkey": "let ok = 1;\nlet bad = 1 / 0;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	forEachEngine(t, func(t *testing.T, engine monkey.Engine) {
+		tests := []struct {
+			input    string
+			expected string
+		}{
+			{`import("a.monkey")`, filepath.Join(dir, "b.monkey") + ":1:9: import cycle: " +
+				filepath.Join(dir, "a.monkey") + " -> " + filepath.Join(dir, "b.monkey") + " -> " + filepath.Join(dir, "a.monkey")},
+			{`import("missing.monkey")`, `1:1: cannot import "missing.monkey": module "missing.monkey" not found, searched in: ., ` + dir},
+			{`import("failing.monkey")`, filepath.Join(dir, "failing.monkey") + ":2:13: division by zero"},
+		}
+
+		for _, tt := range tests {
+			_, err := (&monkey.Interpreter{Engine: engine, SearchPaths: []string{dir}}).Eval(tt.input)
+			if err == nil {
+				t.Errorf("expected an error importing %q", tt.input)
+				continue
+			}
+
+			if err.Error() != tt.expected {
+				t.Errorf("wrong error. got = %q, want = %q", err.Error(), tt.expected)
+			}
+		}
+
+		var d diagnostic.Diagnostic
+		_, err := (&monkey.Interpreter{Engine: engine}).Eval(`import("a.monkey")`)
+		if !errors.As(err, &d) {
+			t.Errorf("expected a diagnostic, got = %T (%v)", err, err)
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package object
 
-type BuiltinFunction func(...Object) Object
+// BuiltinFunction implements a builtin. ctx is never nil, it's what the
+// builtin reaches outside of the program through.
+type BuiltinFunction func(ctx *ExecContext, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
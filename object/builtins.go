@@ -1,164 +1,356 @@
 package object
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
 
+// BuiltinItem is a builtin, along with how it's called.
 type BuiltinItem struct {
-	Name    string
+	Signature
+
 	Builtin *Builtin
 }
 
-func toBF(bf BuiltinFunction) *Builtin {
-	return &Builtin{bf}
-}
+var numberTypes = []ObjectType{INTEGER_OBJ, FLOAT_OBJ}
 
+// Builtins are the standard builtins, those of every registry.
 var Builtins = func() []BuiltinItem {
-	return []BuiltinItem{
-		{
-			"len",
-			toBF(func(args ...Object) Object {
-				if len(args) != 1 {
-					return newWrongNumOfArgsError(len(args), 1)
-				}
+	builtins := []BuiltinItem{
+		newBuiltin(Signature{
+			Name:    "len",
+			Params:  []Param{{"value", []ObjectType{STRING_OBJ, ARRAY_OBJ, RANGE_OBJ, HASH_OBJ}}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns the number of characters of a string, of elements of an array or a range, or of pairs of a hash.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			switch arg := args[0].(type) {
+			case *Array:
+				return &Integer{Value: int64(len(arg.Elements))}
+			case *String:
+				return &Integer{Value: int64(arg.Len())}
+			case *Hash:
+				return &Integer{Value: int64(arg.Len())}
+			default:
+				return &Integer{Value: arg.(*Range).Len()}
+			}
+		}),
+		newBuiltin(Signature{
+			Name:    "first",
+			Params:  []Param{{"array", []ObjectType{ARRAY_OBJ}}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns the first element of an array, or null if it's empty.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			arr := args[0].(*Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
 
-				switch arg := args[0].(type) {
-
-				case *Array:
-					return &Integer{Value: int64(len(arg.Elements))}
+			return &CONST_NULL
+		}),
+		newBuiltin(Signature{
+			Name:    "last",
+			Params:  []Param{{"array", []ObjectType{ARRAY_OBJ}}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns the last element of an array, or null if it's empty.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+			return &Null{}
+		}),
+		newBuiltin(Signature{
+			Name:    "rest",
+			Params:  []Param{{"array", []ObjectType{ARRAY_OBJ}}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns a new array with every element of array but the first, or null if it's empty.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]Object, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &Array{Elements: newElements}
+			}
 
+			return &CONST_NULL
+		}),
+		newBuiltin(Signature{
+			Name:    "push",
+			Params:  []Param{{"array", []ObjectType{ARRAY_OBJ}}, {"value", nil}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Returns a new array with the elements of array followed by value.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			// Copying, since arrays can be modified in place and the result
+			// must not share its elements with the original.
+			arr := args[0].(*Array)
+			if err := ctx.CheckArrayLength(len(arr.Elements) + 1); err != nil {
+				return err
+			}
+			elements := make([]Object, len(arr.Elements), len(arr.Elements)+1)
+			copy(elements, arr.Elements)
+			return &Array{Elements: append(elements, args[1])}
+		}),
+		newBuiltin(Signature{
+			Name:    "puts",
+			Params:  []Param{{"values", nil}},
+			MinArgs: 0,
+			MaxArgs: Variadic,
+			Doc:     "Prints the values to stdout, separated by spaces and followed by a newline.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			return printTo(ctx.Stdout, "stdout", "puts", args)
+		}),
+		newBuiltin(Signature{
+			Name:    "sprintf",
+			Params:  []Param{{"format", []ObjectType{STRING_OBJ}}, {"values", nil}},
+			MinArgs: 1,
+			MaxArgs: Variadic,
+			Doc:     "Formats the values as Go's fmt.Sprintf does.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			formattedArgs := make([]any, len(args)-1)
+			for i := 0; i < len(args)-1; i++ {
+				var value any
+				switch v := args[i+1].(type) {
+				case *Integer:
+					value = v.Value
+				case *Float:
+					value = v.Value
+				case *Boolean:
+					value = v.Value
 				case *String:
-					return &Integer{Value: int64(len(arg.Value))}
-
-				default:
-					return newError("argument to `len` not supported, got %s", args[0].Type())
-				}
-			}),
-		},
-		{
-			"first",
-			toBF(func(args ...Object) Object {
-				if len(args) != 1 {
-					return newWrongNumOfArgsError(len(args), 1)
-				}
-
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("argument to `first` must be an ARRAY, got %s", args[0].Type())
-				}
-
-				arr := args[0].(*Array)
-				if len(arr.Elements) > 0 {
-					return arr.Elements[0]
+					value = v.Value
 				}
+				formattedArgs[i] = value
+			}
 
-				return &CONST_NULL
-			}),
-		},
-		{
-			"last",
-			toBF(func(args ...Object) Object {
-				if len(args) != 1 {
-					return newWrongNumOfArgsError(len(args), 1)
-				}
+			return &String{fmt.Sprintf(args[0].Inspect(), formattedArgs...)}
+		}),
+		newBuiltin(Signature{
+			Name: "range",
+			Params: []Param{
+				{"start", []ObjectType{INTEGER_OBJ}},
+				{"end", []ObjectType{INTEGER_OBJ}},
+				{"step", []ObjectType{INTEGER_OBJ}},
+			},
+			MinArgs: 1,
+			MaxArgs: 3,
+			Doc:     "Returns the integers from start (0 by default) up to end excluded, every step (1 by default).",
+			Usage:   "range(end), range(start, end) or range(start, end, step)",
+		}, func(_ *ExecContext, args ...Object) Object {
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				bounds[i] = arg.(*Integer).Value
+			}
 
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("argument to `last` must be an ARRAY, got %s", args[0].Type())
+			switch len(bounds) {
+			case 1:
+				return &Range{Start: 0, End: bounds[0], Step: 1}
+			case 2:
+				return &Range{Start: bounds[0], End: bounds[1], Step: 1}
+			default:
+				if bounds[2] == 0 {
+					return newError("`range` step must not be zero")
 				}
+				return &Range{Start: bounds[0], End: bounds[1], Step: bounds[2]}
+			}
+		}),
+		newBuiltin(Signature{
+			Name:    "floor",
+			Params:  []Param{{"number", numberTypes}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Rounds a number down to an integer.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			return roundWith("floor", math.Floor, args[0])
+		}),
+		newBuiltin(Signature{
+			Name:    "ceil",
+			Params:  []Param{{"number", numberTypes}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Rounds a number up to an integer.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			return roundWith("ceil", math.Ceil, args[0])
+		}),
+		newBuiltin(Signature{
+			Name:    "round",
+			Params:  []Param{{"number", numberTypes}, {"digits", []ObjectType{INTEGER_OBJ}}},
+			MinArgs: 1,
+			MaxArgs: 2,
+			Doc:     "Rounds a number to the nearest integer, or to a float with the given number of decimal digits.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			if len(args) < 2 {
+				return roundWith("round", math.Round, args[0])
+			}
 
-				arr := args[0].(*Array)
-				length := len(arr.Elements)
-				if length > 0 {
-					return arr.Elements[length-1]
-				}
-				return &Null{}
-			}),
-		},
-		{
-			"rest",
-			toBF(func(args ...Object) Object {
-				if len(args) != 1 {
-					return newWrongNumOfArgsError(len(args), 1)
-				}
+			// round(x, digits) keeps the result a float, rounded to the
+			// given number of decimal digits.
+			value, _ := ToFloat(args[0])
+			digits := args[1].(*Integer)
 
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("argument to `rest` must be an ARRAY, got %s", args[0].Type())
+			scale := math.Pow(10, float64(digits.Value))
+			return &Float{Value: math.Round(value*scale) / scale}
+		}),
+		newBuiltin(Signature{
+			Name:    "float",
+			Params:  []Param{{"value", []ObjectType{INTEGER_OBJ, FLOAT_OBJ, STRING_OBJ}}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Converts an integer or a string to a float.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			switch arg := args[0].(type) {
+			case *Integer:
+				return &Float{Value: float64(arg.Value)}
+			case *Float:
+				return arg
+			default:
+				s := arg.(*String).Value
+				value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+				if err != nil {
+					return newError("could not parse %q as float", s)
 				}
-
-				arr := args[0].(*Array)
-				length := len(arr.Elements)
-				if length > 0 {
-					newElements := make([]Object, length-1)
-					copy(newElements, arr.Elements[1:length])
-					return &Array{Elements: newElements}
+				return &Float{Value: value}
+			}
+		}),
+		newBuiltin(Signature{
+			Name:    "int",
+			Params:  []Param{{"value", []ObjectType{INTEGER_OBJ, FLOAT_OBJ, STRING_OBJ}}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Converts a float, truncating it, or a string to an integer.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			switch arg := args[0].(type) {
+			case *Integer:
+				return arg
+			case *Float:
+				return floatToInteger("int", math.Trunc(arg.Value))
+			default:
+				s := arg.(*String).Value
+				value, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+				if err != nil {
+					return newError("could not parse %q as integer", s)
 				}
-
+				return &Integer{Value: value}
+			}
+		}),
+		newBuiltin(Signature{
+			Name:    "eputs",
+			Params:  []Param{{"values", nil}},
+			MinArgs: 0,
+			MaxArgs: Variadic,
+			Doc:     "Prints the values to stderr, as puts does to stdout.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			return printTo(ctx.Stderr, "stderr", "eputs", args)
+		}),
+		newBuiltin(Signature{
+			Name:    "readline",
+			Params:  nil,
+			MinArgs: 0,
+			MaxArgs: 0,
+			Doc:     "Reads a line from stdin, without its line ending, or returns null once there's none left.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			line, err := ctx.ReadLine()
+			switch {
+			case errors.Is(err, io.EOF):
 				return &CONST_NULL
-			}),
-		},
-		{
-			"push",
-			toBF(func(args ...Object) Object {
-				if len(args) != 2 {
-					return newWrongNumOfArgsError(len(args), 2)
-				}
-
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("first argument to `push` must be %s, got %s", ARRAY_OBJ, args[0].Type())
-				}
-
-				arr := args[0].(*Array)
-				return &Array{Elements: append(arr.Elements, args[1])}
-			}),
-		},
-		{
-			"puts",
-			toBF(func(args ...Object) Object {
-				if len(args) != 1 {
-					return newWrongNumOfArgsError(len(args), 1)
-				}
-
-				if args[0].Type() != STRING_OBJ {
-					return newError("first argument to `puts` must be %s, got %s", STRING_OBJ, args[0].Type())
-				}
-
-				fmt.Println(args[0].Inspect())
+			case err != nil:
+				return newError("`readline`: %s", err)
+			}
+			return &String{Value: line}
+		}),
+		newBuiltin(Signature{
+			Name:    "now",
+			Params:  nil,
+			MinArgs: 0,
+			MaxArgs: 0,
+			Doc:     "Returns the current time, in milliseconds since the Unix epoch.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			if ctx.Clock == nil {
+				return newError("`now`: the clock is not available")
+			}
+			return &Integer{Value: ctx.Clock().UnixMilli()}
+		}),
+		newBuiltin(Signature{
+			Name:    "getenv",
+			Params:  []Param{{"name", []ObjectType{STRING_OBJ}}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns the value of an environment variable, or null if it's not set.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			if ctx.LookupEnv == nil {
+				return newError("`getenv`: the environment is not available")
+			}
+			value, ok := ctx.LookupEnv(args[0].(*String).Value)
+			if !ok {
 				return &CONST_NULL
-			}),
-		},
-		{
-			"sprintf",
-			toBF(func(args ...Object) Object {
-				if len(args) < 1 {
-					return newError("sprintf function requires at least a single argument")
-				}
+			}
+			return &String{Value: value}
+		}),
+	}
+	builtins = append(builtins, stringBuiltins()...)
+	builtins = append(builtins, collectionBuiltins()...)
+	return append(builtins, hashBuiltins()...)
+}()
 
-				fmtArg := args[0]
-				if fmtArg.Type() != STRING_OBJ {
-					return newError("first argument to `sprintf` must be %s, got = %s", STRING_OBJ, fmtArg.Type())
-				}
+// printTo implements the builtins printing values to w, the capability called
+// capability.
+func printTo(w io.Writer, capability string, name string, values []Object) Object {
+	if w == nil {
+		return newError("`%s`: %s is not available", name, capability)
+	}
 
-				formattedArgs := make([]any, len(args)-1)
-				for i := 0; i < len(args)-1; i++ {
-					var value any
-					switch v := args[i+1].(type) {
-					case *Integer:
-						value = v.Value
-					case *Boolean:
-						value = v.Value
-					case *String:
-						value = v.Value
-					}
-					formattedArgs[i] = value
-				}
+	inspected := make([]string, len(values))
+	for i, value := range values {
+		inspected[i] = value.Inspect()
+	}
+	if _, err := fmt.Fprintln(w, strings.Join(inspected, " ")); err != nil {
+		return newError("`%s`: %s", name, err)
+	}
+	return &CONST_NULL
+}
 
-				return &String{fmt.Sprintf(fmtArg.Inspect(), formattedArgs...)}
-			}),
-		},
+// roundWith implements the builtins turning a number into an integer, rounding
+// it with fn. Integers are returned as they are.
+func roundWith(name string, fn func(float64) float64, number Object) Object {
+	if integer, ok := number.(*Integer); ok {
+		return integer
 	}
-}()
+	return floatToInteger(name, fn(number.(*Float).Value))
+}
 
-func newError(format string, args ...any) *Error {
-	return &Error{Message: fmt.Sprintf(format, args...)}
+func floatToInteger(name string, value float64) Object {
+	if math.IsNaN(value) || value < math.MinInt64 || value >= math.MaxInt64 {
+		return newError("result of `%s` does not fit in an %s: %g", name, INTEGER_OBJ, value)
+	}
+	return &Integer{Value: int64(value)}
 }
 
-func newWrongNumOfArgsError(got int, want int64) *Error {
-	return newError("wrong number of arguments. got = %d, want = %d", got, want)
+func newError(format string, args ...any) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...)}
 }
@@ -0,0 +1,385 @@
+package object
+
+import (
+	"sort"
+	"strings"
+)
+
+var (
+	iterableParam = []ObjectType{ARRAY_OBJ, RANGE_OBJ, STRING_OBJ, HASH_OBJ}
+	functionParam = []ObjectType{FUNCTION_OBJ, CLOSURE_OBJ, BUILTIN_OBJ}
+)
+
+// collectionBuiltins are the builtins working on collections, any that can be
+// iterated over as `for ... in` does. Those taking a function call it back
+// through the ExecContext, and fail as soon as a call does.
+func collectionBuiltins() []BuiltinItem {
+	return []BuiltinItem{
+		newBuiltin(Signature{
+			Name:    "map",
+			Params:  []Param{{"collection", iterableParam}, {"fn", functionParam}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Returns the results of fn called with each element of collection.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			elements := []Object{}
+			if err := each(args[0], func(element Object) (err Object) {
+				result := ctx.Call(args[1], element)
+				if isError(result) {
+					return result
+				}
+				elements, err = grow(ctx, elements, result)
+				return err
+			}); err != nil {
+				return err
+			}
+			return &Array{Elements: elements}
+		}),
+		newBuiltin(Signature{
+			Name:    "filter",
+			Params:  []Param{{"collection", iterableParam}, {"fn", functionParam}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Returns the elements of collection for which fn returns a truthy value.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			elements := []Object{}
+			if err := each(args[0], func(element Object) (err Object) {
+				result := ctx.Call(args[1], element)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					elements, err = grow(ctx, elements, element)
+				}
+				return err
+			}); err != nil {
+				return err
+			}
+			return &Array{Elements: elements}
+		}),
+		newBuiltin(Signature{
+			Name:    "reduce",
+			Params:  []Param{{"collection", iterableParam}, {"fn", functionParam}, {"initial", nil}},
+			MinArgs: 2,
+			MaxArgs: 3,
+			Doc: "Combines the elements of collection into one, by calling fn with the result so far and each element. " +
+				"The result starts as initial, or the first element if it's left out, and is null if there's none.",
+			Usage: "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			var acc Object
+			if len(args) == 3 {
+				acc = args[2]
+			}
+			if err := each(args[0], func(element Object) Object {
+				if acc == nil {
+					acc = element
+					return nil
+				}
+				acc = ctx.Call(args[1], acc, element)
+				if isError(acc) {
+					return acc
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if acc == nil {
+				return &CONST_NULL
+			}
+			return acc
+		}),
+		newBuiltin(Signature{
+			Name:    "sort",
+			Params:  []Param{{"collection", iterableParam}, {"less", functionParam}},
+			MinArgs: 1,
+			MaxArgs: 2,
+			Doc: "Returns the elements of collection sorted by less, which tells whether its first argument goes " +
+				"before the second. Numbers and strings are sorted in ascending order if it's left out. " +
+				"Equal elements keep their order.",
+			Usage: "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			elements, err := collect(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			less := func(a, b Object) Object { return compareDefault(a, b) }
+			if len(args) == 2 {
+				less = func(a, b Object) Object { return ctx.Call(args[1], a, b) }
+			}
+
+			// Once a comparison has failed, the remaining ones are skipped
+			// and the sort returns the error.
+			sort.SliceStable(elements, func(i, j int) bool {
+				if err != nil {
+					return false
+				}
+				result := less(elements[i], elements[j])
+				if isError(result) {
+					err = result
+					return false
+				}
+				return isTruthy(result)
+			})
+			if err != nil {
+				return err
+			}
+			return &Array{Elements: elements}
+		}),
+		newBuiltin(Signature{
+			Name:    "reverse",
+			Params:  []Param{{"value", []ObjectType{ARRAY_OBJ, STRING_OBJ}}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns the elements of an array, or the chars of a string, in reverse order.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			switch arg := args[0].(type) {
+			case *String:
+				chars := []rune(arg.Value)
+				for i, j := 0, len(chars)-1; i < j; i, j = i+1, j-1 {
+					chars[i], chars[j] = chars[j], chars[i]
+				}
+				return &String{Value: string(chars)}
+			default:
+				elements := arg.(*Array).Elements
+				reversed := make([]Object, len(elements))
+				for i, element := range elements {
+					reversed[len(elements)-1-i] = element
+				}
+				return &Array{Elements: reversed}
+			}
+		}),
+		newBuiltin(Signature{
+			Name:    "zip",
+			Params:  []Param{{"collections", iterableParam}},
+			MinArgs: 1,
+			MaxArgs: Variadic,
+			Doc:     "Returns arrays of the elements of the collections at the same index, as many as the shortest has.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			iterators := make([]*Iterator, len(args))
+			for i, arg := range args {
+				iterator, err := NewIterator(arg)
+				if err != nil {
+					return newError("`zip`: %s", err)
+				}
+				iterators[i] = iterator
+			}
+
+			tuples := []Object{}
+			for {
+				tuple := make([]Object, len(iterators))
+				for i, iterator := range iterators {
+					element, ok := iterator.Next()
+					if !ok {
+						return &Array{Elements: tuples}
+					}
+					tuple[i] = element
+				}
+				// The tuples are counted as they're created, the array of
+				// them by the engine.
+				if err := ctx.Allocate(1); err != nil {
+					return err
+				}
+				var err Object
+				if tuples, err = grow(ctx, tuples, &Array{Elements: tuple}); err != nil {
+					return err
+				}
+			}
+		}),
+		newBuiltin(Signature{
+			Name:    "any",
+			Params:  []Param{{"collection", iterableParam}, {"fn", functionParam}},
+			MinArgs: 1,
+			MaxArgs: 2,
+			Doc:     "Reports whether fn returns a truthy value for any element of collection, or any element is truthy.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			return findTruthy(ctx, "any", args, true)
+		}),
+		newBuiltin(Signature{
+			Name:    "all",
+			Params:  []Param{{"collection", iterableParam}, {"fn", functionParam}},
+			MinArgs: 1,
+			MaxArgs: 2,
+			Doc:     "Reports whether fn returns a truthy value for all elements of collection, or all elements are truthy.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			return findTruthy(ctx, "all", args, false)
+		}),
+		newBuiltin(Signature{
+			Name:    "find",
+			Params:  []Param{{"collection", iterableParam}, {"fn", functionParam}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Returns the first element of collection for which fn returns a truthy value, or null if there's none.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			var found Object = &CONST_NULL
+			if err := each(args[0], func(element Object) Object {
+				result := ctx.Call(args[1], element)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					found = element
+					return stopIteration
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			return found
+		}),
+		newBuiltin(Signature{
+			Name:    "flat_map",
+			Params:  []Param{{"collection", iterableParam}, {"fn", functionParam}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Returns the elements of the arrays fn returns when called with each element of collection.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			elements := []Object{}
+			if err := each(args[0], func(element Object) (err Object) {
+				result := ctx.Call(args[1], element)
+				if isError(result) {
+					return result
+				}
+				array, ok := result.(*Array)
+				if !ok {
+					return newError("`flat_map`: fn must return ARRAY, got %s", result.Type())
+				}
+				elements, err = grow(ctx, elements, array.Elements...)
+				return err
+			}); err != nil {
+				return err
+			}
+			return &Array{Elements: elements}
+		}),
+		newBuiltin(Signature{
+			Name:    "unique",
+			Params:  []Param{{"collection", iterableParam}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns the elements of collection without those equal to an earlier one. Elements must be hashable.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			seen := map[HashKey]bool{}
+			elements := []Object{}
+			if err := each(args[0], func(element Object) (err Object) {
+				key, hashErr := element.HashKey()
+				if hashErr != nil {
+					return newError("`unique`: %s", hashErr)
+				}
+				if !seen[key] {
+					seen[key] = true
+					elements, err = grow(ctx, elements, element)
+				}
+				return err
+			}); err != nil {
+				return err
+			}
+			return &Array{Elements: elements}
+		}),
+	}
+}
+
+// stopIteration ends each early, without failing.
+var stopIteration = &Error{Message: "stop iteration"} //nolint:exhaustruct
+
+// each calls fn with each element of collection, until it returns non-nil. It
+// returns what fn did, or nil if it returned stopIteration or nothing at all.
+func each(collection Object, fn func(element Object) Object) Object {
+	iterator, err := NewIterator(collection)
+	if err != nil {
+		return newError("%s", err)
+	}
+	for element, ok := iterator.Next(); ok; element, ok = iterator.Next() {
+		if result := fn(element); result != nil {
+			if result == stopIteration {
+				return nil
+			}
+			return result
+		}
+	}
+	return nil
+}
+
+// collect returns the elements of collection.
+func collect(ctx *ExecContext, collection Object) ([]Object, Object) {
+	elements := []Object{}
+	err := each(collection, func(element Object) (err Object) {
+		elements, err = grow(ctx, elements, element)
+		return err
+	})
+	return elements, err
+}
+
+// grow appends added to elements, an array being built by a builtin, once
+// checked that they fit in the limits of the running program.
+func grow(ctx *ExecContext, elements []Object, added ...Object) ([]Object, Object) {
+	if err := ctx.CheckArrayLength(len(elements) + len(added)); err != nil {
+		return elements, err
+	}
+	return append(elements, added...), nil
+}
+
+// findTruthy implements any and all: it returns want as soon as the truthiness
+// of an element, or of what the function returns for it, is want.
+func findTruthy(ctx *ExecContext, name string, args []Object, want bool) Object {
+	found := false
+	if err := each(args[0], func(element Object) Object {
+		result := element
+		if len(args) == 2 {
+			result = ctx.Call(args[1], element)
+			if isError(result) {
+				return result
+			}
+		}
+		if isTruthy(result) == want {
+			found = true
+			return stopIteration
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return nativeToBoolean(found == want)
+}
+
+// compareDefault tells whether a goes before b, for sort without a function:
+// numbers and strings are in ascending order.
+func compareDefault(a, b Object) Object {
+	switch {
+	case a.Type() == INTEGER_OBJ && b.Type() == INTEGER_OBJ:
+		return nativeToBoolean(a.(*Integer).Value < b.(*Integer).Value)
+	case IsNumber(a) && IsNumber(b):
+		x, _ := ToFloat(a)
+		y, _ := ToFloat(b)
+		return nativeToBoolean(x < y)
+	case a.Type() == STRING_OBJ && b.Type() == STRING_OBJ:
+		return nativeToBoolean(strings.Compare(a.(*String).Value, b.(*String).Value) < 0)
+	default:
+		return newError("`sort`: cannot compare %s with %s, a function comparing them is needed", a.Type(), b.Type())
+	}
+}
+
+// isTruthy tells whether obj counts as true for the builtins: every value but
+// false and null, or nothing at all, does.
+func isTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Null, nil:
+		return false
+	default:
+		return true
+	}
+}
+
+func isError(obj Object) bool {
+	return obj != nil && obj.Type() == ERROR_OBJ
+}
@@ -0,0 +1,114 @@
+package object
+
+var hashParam = []ObjectType{HASH_OBJ}
+
+// hashBuiltins are the builtins working on hashes. Like iteration, they follow
+// the order the keys were first set in, and they return new hashes rather than
+// changing those they're given.
+func hashBuiltins() []BuiltinItem {
+	return []BuiltinItem{
+		newBuiltin(Signature{
+			Name:    "keys",
+			Params:  []Param{{"hash", hashParam}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns the keys of hash.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			pairs := args[0].(*Hash).Ordered()
+			keys := make([]Object, len(pairs))
+			for i, pair := range pairs {
+				keys[i] = pair.Key
+			}
+			return &Array{Elements: keys}
+		}),
+		newBuiltin(Signature{
+			Name:    "values",
+			Params:  []Param{{"hash", hashParam}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns the values of hash.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			pairs := args[0].(*Hash).Ordered()
+			values := make([]Object, len(pairs))
+			for i, pair := range pairs {
+				values[i] = pair.Value
+			}
+			return &Array{Elements: values}
+		}),
+		newBuiltin(Signature{
+			Name:    "items",
+			Params:  []Param{{"hash", hashParam}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns the pairs of hash, each as a [key, value] array.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			pairs := args[0].(*Hash).Ordered()
+			// Each pair makes an array, counted on top of that of them all.
+			if err := ctx.Allocate(len(pairs)); err != nil {
+				return err
+			}
+			items := make([]Object, len(pairs))
+			for i, pair := range pairs {
+				items[i] = &Array{Elements: []Object{pair.Key, pair.Value}}
+			}
+			return &Array{Elements: items}
+		}),
+		newBuiltin(Signature{
+			Name:    "has",
+			Params:  []Param{{"hash", hashParam}, {"key", nil}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Reports whether key is set in hash.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			key, err := args[1].HashKey()
+			if err != nil {
+				return newError("%s", err)
+			}
+			_, ok := args[0].(*Hash).Get(key)
+			return nativeToBoolean(ok)
+		}),
+		newBuiltin(Signature{
+			Name:    "delete",
+			Params:  []Param{{"hash", hashParam}, {"key", nil}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Returns a new hash with the pairs of hash but that of key.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			key, err := args[1].HashKey()
+			if err != nil {
+				return newError("%s", err)
+			}
+			hash := args[0].(*Hash).Copy()
+			hash.Delete(key)
+			return hash
+		}),
+		newBuiltin(Signature{
+			Name:    "merge",
+			Params:  []Param{{"hashes", hashParam}},
+			MinArgs: 1,
+			MaxArgs: Variadic,
+			Doc: "Returns a new hash with the pairs of all hashes. Values of later hashes replace those of " +
+				"earlier ones, keys stay where they were first set.",
+			Usage: "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			merged := args[0].(*Hash).Copy()
+			for _, arg := range args[1:] {
+				hash := arg.(*Hash)
+				for _, key := range hash.Keys {
+					if _, ok := merged.Get(key); !ok {
+						if err := ctx.CheckArrayLength(merged.Len() + 1); err != nil {
+							return err
+						}
+					}
+					merged.Set(key, hash.Pairs[key])
+				}
+			}
+			return merged
+		}),
+	}
+}
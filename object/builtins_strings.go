@@ -0,0 +1,307 @@
+package object
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	stringParam = []ObjectType{STRING_OBJ}
+	intParam    = []ObjectType{INTEGER_OBJ}
+)
+
+// stringBuiltins are the builtins working on strings. Like indexing, they
+// count in chars rather than bytes.
+func stringBuiltins() []BuiltinItem {
+	return []BuiltinItem{
+		newBuiltin(Signature{
+			Name:    "split",
+			Params:  []Param{{"string", stringParam}, {"separator", stringParam}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Returns the parts of string between the separators, or its chars if the separator is empty.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			s, separator := args[0].(*String).Value, args[1].(*String).Value
+			n := strings.Count(s, separator) + 1
+			if separator == "" {
+				n = utf8.RuneCountInString(s)
+			}
+			if err := reserveStrings(ctx, n); err != nil {
+				return err
+			}
+			return stringArray(strings.Split(s, separator))
+		}),
+		newBuiltin(Signature{
+			Name:    "join",
+			Params:  []Param{{"array", []ObjectType{ARRAY_OBJ}}, {"separator", stringParam}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Returns the elements of array as they're printed, separated by separator.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			elements, separator := args[0].(*Array).Elements, args[1].(*String).Value
+			parts := make([]string, len(elements))
+			length := max(0, len(parts)-1) * len(separator)
+			for i, element := range elements {
+				parts[i] = element.Inspect()
+				length += len(parts[i])
+			}
+			if err := ctx.CheckStringLength(length); err != nil {
+				return err
+			}
+			return &String{Value: strings.Join(parts, separator)}
+		}),
+		newBuiltin(Signature{
+			Name:    "trim",
+			Params:  []Param{{"string", stringParam}, {"chars", stringParam}},
+			MinArgs: 1,
+			MaxArgs: 2,
+			Doc:     "Returns string without the whitespace, or the given chars, it starts and ends with.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			s := args[0].(*String).Value
+			if len(args) < 2 {
+				return &String{Value: strings.TrimSpace(s)}
+			}
+			return &String{Value: strings.Trim(s, args[1].(*String).Value)}
+		}),
+		newBuiltin(Signature{
+			Name:    "upper",
+			Params:  []Param{{"string", stringParam}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns string in upper case.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			return &String{Value: strings.ToUpper(args[0].(*String).Value)}
+		}),
+		newBuiltin(Signature{
+			Name:    "lower",
+			Params:  []Param{{"string", stringParam}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns string in lower case.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			return &String{Value: strings.ToLower(args[0].(*String).Value)}
+		}),
+		newBuiltin(Signature{
+			Name:    "contains",
+			Params:  []Param{{"string", stringParam}, {"substring", stringParam}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Reports whether substring is within string.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			return nativeToBoolean(strings.Contains(args[0].(*String).Value, args[1].(*String).Value))
+		}),
+		newBuiltin(Signature{
+			Name:    "index_of",
+			Params:  []Param{{"string", stringParam}, {"substring", stringParam}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Returns the index of the first char of substring within string, or -1 if it's not there.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			s := args[0].(*String).Value
+			i := strings.Index(s, args[1].(*String).Value)
+			if i < 0 {
+				return &Integer{Value: -1}
+			}
+			return &Integer{Value: int64(utf8.RuneCountInString(s[:i]))}
+		}),
+		newBuiltin(Signature{
+			Name:    "replace",
+			Params:  []Param{{"string", stringParam}, {"old", stringParam}, {"new", stringParam}, {"n", intParam}},
+			MinArgs: 3,
+			MaxArgs: 4,
+			Doc:     "Returns string with the first n occurrences of old replaced by new, all of them if n is left out.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			s, old, replacement := args[0].(*String).Value, args[1].(*String).Value, args[2].(*String).Value
+			n := -1
+			if len(args) == 4 {
+				n = int(args[3].(*Integer).Value)
+			}
+
+			// An empty old matches before each char and at the end.
+			count := strings.Count(s, old)
+			if n >= 0 {
+				count = min(count, n)
+			}
+			if len(replacement) > len(old) && count > (maxStringLength-len(s))/(len(replacement)-len(old)) {
+				return newError("result of `replace` is too long")
+			}
+			if err := ctx.CheckStringLength(len(s) + count*(len(replacement)-len(old))); err != nil {
+				return err
+			}
+			return &String{Value: strings.Replace(s, old, replacement, n)}
+		}),
+		newBuiltin(Signature{
+			Name:    "starts_with",
+			Params:  []Param{{"string", stringParam}, {"prefix", stringParam}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Reports whether string starts with prefix.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			return nativeToBoolean(strings.HasPrefix(args[0].(*String).Value, args[1].(*String).Value))
+		}),
+		newBuiltin(Signature{
+			Name:    "ends_with",
+			Params:  []Param{{"string", stringParam}, {"suffix", stringParam}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Reports whether string ends with suffix.",
+			Usage:   "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			return nativeToBoolean(strings.HasSuffix(args[0].(*String).Value, args[1].(*String).Value))
+		}),
+		newBuiltin(Signature{
+			Name:    "substr",
+			Params:  []Param{{"string", stringParam}, {"start", intParam}, {"end", intParam}},
+			MinArgs: 2,
+			MaxArgs: 3,
+			Doc: "Returns the chars of string from start up to end excluded, or to its end. " +
+				"Negative indices count from the end, and indices out of the string are clamped to it.",
+			Usage: "",
+		}, func(_ *ExecContext, args ...Object) Object {
+			chars := []rune(args[0].(*String).Value)
+			start := clampIndex(args[1].(*Integer).Value, len(chars))
+			end := len(chars)
+			if len(args) == 3 {
+				end = clampIndex(args[2].(*Integer).Value, len(chars))
+			}
+			if start >= end {
+				return &String{Value: ""}
+			}
+			return &String{Value: string(chars[start:end])}
+		}),
+		newBuiltin(Signature{
+			Name:    "repeat",
+			Params:  []Param{{"string", stringParam}, {"count", intParam}},
+			MinArgs: 2,
+			MaxArgs: 2,
+			Doc:     "Returns string repeated count times.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			s, count := args[0].(*String).Value, args[1].(*Integer).Value
+			switch {
+			case count < 0:
+				return newError("`repeat` count must not be negative, got %d", count)
+			case len(s) > 0 && count > int64(maxStringLength/len(s)):
+				return newError("result of `repeat` is too long")
+			}
+			if err := ctx.CheckStringLength(len(s) * int(count)); err != nil {
+				return err
+			}
+			return &String{Value: strings.Repeat(s, int(count))}
+		}),
+		newBuiltin(Signature{
+			Name:    "chars",
+			Params:  []Param{{"string", stringParam}},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Doc:     "Returns the chars of string, each as a string of its own.",
+			Usage:   "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			s := args[0].(*String).Value
+			if err := reserveStrings(ctx, utf8.RuneCountInString(s)); err != nil {
+				return err
+			}
+			chars := make([]string, 0, len(s))
+			for _, ch := range s {
+				chars = append(chars, string(ch))
+			}
+			return stringArray(chars)
+		}),
+		newBuiltin(Signature{
+			Name:    "format",
+			Params:  []Param{{"template", stringParam}, {"values", nil}},
+			MinArgs: 1,
+			MaxArgs: Variadic,
+			Doc: "Returns template with each {n} replaced by the n-th value (from 0) as it's printed, " +
+				"and {{ and }} by { and }.",
+			Usage: "",
+		}, func(ctx *ExecContext, args ...Object) Object {
+			return formatTemplate(ctx, args[0].(*String).Value, args[1:])
+		}),
+	}
+}
+
+// maxStringLength is the length of the longest string builtins create.
+const maxStringLength = 1 << 30
+
+// reserveStrings checks that an array of n new strings fits in the limits of
+// the running program, before a builtin creates it, and counts the strings.
+func reserveStrings(ctx *ExecContext, n int) Object {
+	if err := ctx.CheckArrayLength(n); err != nil {
+		return err
+	}
+	return ctx.Allocate(n)
+}
+
+func stringArray(values []string) *Array {
+	elements := make([]Object, len(values))
+	for i, value := range values {
+		elements[i] = &String{Value: value}
+	}
+	return &Array{Elements: elements}
+}
+
+func nativeToBoolean(b bool) *Boolean {
+	if b {
+		return &CONST_TRUE
+	}
+	return &CONST_FALSE
+}
+
+// clampIndex returns index within [0, length], counting negative ones from the
+// end.
+func clampIndex(index int64, length int) int {
+	if index < 0 {
+		index += int64(length)
+	}
+	return int(max(0, min(index, int64(length))))
+}
+
+// formatTemplate implements format.
+func formatTemplate(ctx *ExecContext, template string, values []Object) Object {
+	var out strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case (c == '{' || c == '}') && i+1 < len(template) && template[i+1] == c:
+			out.WriteByte(c)
+			i++
+
+		case c == '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end < 0 {
+				return newError("`format`: unclosed { at %d", i)
+			}
+			placeholder := template[i+1 : i+end]
+			n, err := strconv.Atoi(placeholder)
+			if err != nil || n < 0 {
+				return newError("`format`: invalid placeholder {%s}", placeholder)
+			}
+			if n >= len(values) {
+				return newError("`format`: no value for {%d}, got %d values", n, len(values))
+			}
+			out.WriteString(values[n].Inspect())
+			if err := ctx.CheckStringLength(out.Len()); err != nil {
+				return err
+			}
+			i += end
+
+		case c == '}':
+			return newError("`format`: unopened } at %d", i)
+
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return &String{Value: out.String()}
+}
@@ -0,0 +1,19 @@
+package object
+
+// Cell is a box holding a variable that a closure captured, so that the
+// closure and the function defining the variable share it: an assignment on
+// either side is seen by the other.
+//
+// Cells only live in the vm's local slots and closures' free variables, and
+// are never handed out as values.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType {
+	return CELL_OBJ
+}
+
+func (c *Cell) Inspect() string {
+	return c.Value.Inspect()
+}
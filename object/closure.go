@@ -5,6 +5,10 @@ import "fmt"
 type Closure struct {
 	Fn   *CompiledFunction
 	Free []Object
+
+	// Globals are the globals of the module the closure was created in, nil
+	// for the ones of the main program.
+	Globals []Object
 }
 
 func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
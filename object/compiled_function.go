@@ -6,9 +6,24 @@ import (
 )
 
 type CompiledFunction struct {
+	// Name is the name the function was bound to, if any.
+	Name string
+
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+
+	// NumGlobals is how many globals a module defines, if the function is the
+	// body of a module.
+	NumGlobals int
+
+	// SourceMap maps Instructions back to the source the function was compiled from.
+	SourceMap code.SourceMap
+
+	// LocalNames and FreeNames name the local and the free variables of the
+	// function by their index, for debuggers. They're not serialized.
+	LocalNames []string
+	FreeNames  []string
 }
 
 func (c *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
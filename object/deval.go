@@ -2,6 +2,7 @@ package object
 
 import (
 	"fmt"
+	"math"
 	"monkey/ast"
 	"monkey/token"
 )
@@ -105,6 +106,38 @@ func (i *Integer) Deval() (ast.Node, error) {
 	return ast.NewIntegerLiteral(t, i.Value), nil
 }
 
+func (f *Float) Deval() (ast.Node, error) {
+	if math.IsInf(f.Value, 0) || math.IsNaN(f.Value) {
+		return nil, fmt.Errorf("float %s has no literal to be restored into", f.Inspect())
+	}
+
+	t := token.Token{
+		Type:    token.FLOAT,
+		Literal: f.Inspect(),
+	}
+	return ast.NewFloatLiteral(t, f.Value), nil
+}
+
 func (m *Macro) Deval() (ast.Node, error) {
 	return nil, newDevalForTypeNotSupportedError(m)
 }
+
+func (r *Range) Deval() (ast.Node, error) {
+	return nil, newDevalForTypeNotSupportedError(r)
+}
+
+func (it *Iterator) Deval() (ast.Node, error) {
+	return nil, newDevalForTypeNotSupportedError(it)
+}
+
+func (b *Break) Deval() (ast.Node, error) {
+	return nil, newDevalForTypeNotSupportedError(b)
+}
+
+func (c *Continue) Deval() (ast.Node, error) {
+	return nil, newDevalForTypeNotSupportedError(c)
+}
+
+func (c *Cell) Deval() (ast.Node, error) {
+	return nil, newDevalForTypeNotSupportedError(c)
+}
@@ -1,15 +1,98 @@
 package object
 
+import "monkey/token"
+
+// Importer evaluates the modules imported by `import` expressions, returning
+// an ERROR if the module can't be imported.
+type Importer interface {
+	Import(path string, from token.Span) Object
+}
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	// fixed are the names of store that can't be assigned to, see
+	// DefineFixed.
+	fixed map[string]bool
+
+	importer Importer
+	builtins *BuiltinRegistry
+	budget   *Budget
+	exec     *ExecContext
 }
 
 func NewEnvironment() *Environment {
 	return &Environment{
 		map[string]Object{},
 		nil,
+		map[string]bool{},
+		nil,
+		nil,
+		nil,
+		nil,
+	}
+}
+
+// Importer returns the importer of the environment, or of its closest outer
+// environment that has one.
+func (e *Environment) Importer() Importer {
+	if e.importer == nil && e.outer != nil {
+		return e.outer.Importer()
+	}
+	return e.importer
+}
+
+// SetImporter sets what loads the modules imported by code running in this
+// environment, and in the environments it encloses.
+func (e *Environment) SetImporter(importer Importer) {
+	e.importer = importer
+}
+
+// Builtins returns the builtins of the environment, or of its closest outer
+// environment that has some. It returns nil if none has, for the standard
+// builtins.
+func (e *Environment) Builtins() *BuiltinRegistry {
+	if e.builtins == nil && e.outer != nil {
+		return e.outer.Builtins()
+	}
+	return e.builtins
+}
+
+// SetBuiltins sets the builtins of the code running in this environment, and
+// in the environments it encloses.
+func (e *Environment) SetBuiltins(builtins *BuiltinRegistry) {
+	e.builtins = builtins
+}
+
+// Budget returns the budget of the environment, or of its closest outer
+// environment that has one. It returns nil if none has, for no limits.
+func (e *Environment) Budget() *Budget {
+	if e.budget == nil && e.outer != nil {
+		return e.outer.Budget()
+	}
+	return e.budget
+}
+
+// SetBudget sets the budget of the code running in this environment, and in
+// the environments it encloses. nil removes it.
+func (e *Environment) SetBudget(budget *Budget) {
+	e.budget = budget
+}
+
+// ExecContext returns what the builtins called in the environment reach
+// outside of the program through: the context of the environment, or of its
+// closest outer environment that has one, or the default one.
+func (e *Environment) ExecContext() *ExecContext {
+	if e.exec == nil && e.outer != nil {
+		return e.outer.ExecContext()
 	}
+	return e.exec.OrDefault()
+}
+
+// SetExecContext sets the context of the builtins called in this environment,
+// and in the environments it encloses.
+func (e *Environment) SetExecContext(exec *ExecContext) {
+	e.exec = exec
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -20,14 +103,61 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
-func (e *Environment) Set(name string, val Object) Object {
+// Define binds the name in this very environment, shadowing any binding of the
+// same name in the outer ones.
+func (e *Environment) Define(name string, val Object) Object {
+	e.store[name] = val
+	delete(e.fixed, name)
+	return val
+}
+
+// DefineFixed binds the name as Define does, for good: Set fails to rebind it,
+// and Fixed reports it, until it's defined again.
+func (e *Environment) DefineFixed(name string, val Object) Object {
 	e.store[name] = val
+	e.fixed[name] = true
 	return val
 }
 
+// Fixed reports whether the binding name resolves to was defined with
+// DefineFixed.
+func (e *Environment) Fixed(name string) bool {
+	if _, ok := e.store[name]; ok {
+		return e.fixed[name]
+	}
+	if e.outer != nil {
+		return e.outer.Fixed(name)
+	}
+	return false
+}
+
+// Set rebinds an existing name in the environment that defines it, which may
+// be any of the outer ones. It returns false if the name isn't defined at all,
+// or is fixed there.
+func (e *Environment) Set(name string, val Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		if e.fixed[name] {
+			return nil, false
+		}
+		e.store[name] = val
+		return val, true
+	}
+
+	if e.outer != nil {
+		return e.outer.Set(name, val)
+	}
+
+	return nil, false
+}
+
 func (e *Environment) NewScoped() *Environment {
 	return &Environment{
 		map[string]Object{},
 		e,
+		map[string]bool{},
+		nil,
+		nil,
+		nil,
+		nil,
 	}
 }
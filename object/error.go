@@ -1,9 +1,17 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
+	"monkey/token"
+)
 
 type Error struct {
 	Message string
+
+	// Span is where in the source the error was raised. It is not set for
+	// errors created outside of an evaluation (e.g. by builtins), until the
+	// evaluator attaches the location of the node it was evaluating.
+	Span token.Span
 }
 
 func (e *Error) Type() ObjectType {
@@ -11,5 +19,8 @@ func (e *Error) Type() ObjectType {
 }
 
 func (e *Error) Inspect() string {
+	if e.Span.IsValid() {
+		return fmt.Sprintf("ERROR: %s: %s", e.Span, e.Message)
+	}
 	return fmt.Sprintf("ERROR: %s", e.Message)
 }
@@ -0,0 +1,163 @@
+package object
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Caller calls the functions of the running program back, for builtins taking
+// functions as arguments. It returns an ERROR if the call fails.
+type Caller interface {
+	Call(fn Object, args ...Object) Object
+}
+
+// ExecContext is what builtins reach outside of the program through. Each of
+// its capabilities may be swapped, or denied by leaving it nil: the builtins
+// that need a capability that's denied fail.
+type ExecContext struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// Clock returns the current time.
+	Clock func() time.Time
+	// LookupEnv returns the value of an environment variable, if it's set.
+	LookupEnv func(name string) (string, bool)
+
+	// caller is set by the engine running the builtins, see WithCaller.
+	caller Caller
+	// budget is that of the program running the builtins, nil if it's not
+	// limited, see WithBudget.
+	budget *Budget
+}
+
+// DefaultExecContext returns the context of the process itself: its standard
+// streams, the system clock and the environment variables.
+func DefaultExecContext() *ExecContext {
+	return &ExecContext{
+		Stdout:    os.Stdout,
+		Stderr:    os.Stderr,
+		Stdin:     os.Stdin,
+		Clock:     time.Now,
+		LookupEnv: os.LookupEnv,
+		caller:    nil,
+		budget:    nil,
+	}
+}
+
+// ReadLine reads a line from Stdin, without its line ending. It returns
+// io.EOF once there's nothing left to read.
+//
+// Stdin is read a byte at a time, so that nothing past the line is consumed:
+// contexts are copied by the engines, and the host may read Stdin too.
+func (c *ExecContext) ReadLine() (string, error) {
+	if c.Stdin == nil {
+		return "", errors.New("stdin is not available")
+	}
+
+	var line strings.Builder
+	b := make([]byte, 1)
+	for {
+		n, err := c.Stdin.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line.WriteByte(b[0])
+		}
+		if err == io.EOF && line.Len() > 0 {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(line.String(), "\r"), nil
+}
+
+// Call calls fn, a function of the running program or a builtin, with args.
+// It returns an ERROR if the call fails, which builtins should return as is so
+// that the program stops.
+func (c *ExecContext) Call(fn Object, args ...Object) Object {
+	if c.caller != nil {
+		return c.caller.Call(fn, args...)
+	}
+	if builtin, ok := fn.(*Builtin); ok {
+		return builtin.Fn(c, args...)
+	}
+	return newError("cannot call %s: no program is running", fn.Type())
+}
+
+// WithCaller returns a copy of the context calling functions back with
+// caller, for the engines to pass to builtins.
+func (c *ExecContext) WithCaller(caller Caller) *ExecContext {
+	withCaller := *c.OrDefault()
+	withCaller.caller = caller
+	return &withCaller
+}
+
+// WithBudget returns a copy of the context whose builtins count what they
+// create against budget, for the engines running programs within limits.
+func (c *ExecContext) WithBudget(budget *Budget) *ExecContext {
+	withBudget := *c.OrDefault()
+	withBudget.budget = budget
+	return &withBudget
+}
+
+// Budget returns the budget of the running program, nil if it's not limited.
+func (c *ExecContext) Budget() *Budget {
+	if c == nil {
+		return nil
+	}
+	return c.budget
+}
+
+// CheckStringLength returns an ERROR if a string of length bytes doesn't fit
+// in the limits of the running program. Builtins check the strings they
+// create before building them.
+func (c *ExecContext) CheckStringLength(length int) Object {
+	if c.Budget() == nil {
+		return nil
+	}
+	return budgetError(c.budget.CheckStringLength(length))
+}
+
+// CheckArrayLength returns an ERROR if an array of length elements, or a hash
+// of length pairs, doesn't fit in the limits of the running program.
+func (c *ExecContext) CheckArrayLength(length int) Object {
+	if c.Budget() == nil {
+		return nil
+	}
+	return budgetError(c.budget.CheckArrayLength(length))
+}
+
+// Allocate counts n values a builtin creates on top of the one it returns,
+// which the engines count, e.g. the elements of an array of new strings. It
+// returns an ERROR if they don't fit in the limits of the running program.
+func (c *ExecContext) Allocate(n int) Object {
+	if c.Budget() == nil {
+		return nil
+	}
+	return budgetError(c.budget.AllocateN(n))
+}
+
+func budgetError(err error) Object {
+	if err == nil {
+		return nil
+	}
+	return newError("%s", err)
+}
+
+// defaultExecContext is the context of the builtins called without one.
+var defaultExecContext = DefaultExecContext()
+
+// OrDefault returns c, or the default context if c is nil.
+func (c *ExecContext) OrDefault() *ExecContext {
+	if c == nil {
+		return defaultExecContext
+	}
+	return c
+}
@@ -0,0 +1,94 @@
+package object_test
+
+import (
+	"context"
+	"errors"
+	"monkey/object"
+	"strings"
+	"testing"
+	"time"
+)
+
+func call(t *testing.T, exec *object.ExecContext, name string, args ...object.Object) object.Object {
+	t.Helper()
+
+	builtin, ok := object.NewBuiltinRegistry().Lookup(name)
+	if !ok {
+		t.Fatalf("no builtin called %s", name)
+	}
+	return builtin.Fn(exec, args...)
+}
+
+func TestExecContext(t *testing.T) {
+	var stdout, stderr strings.Builder
+	exec := &object.ExecContext{
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Stdin:  strings.NewReader("first\r\nsecond"),
+		Clock:  func() time.Time { return time.UnixMilli(1234) },
+		LookupEnv: func(name string) (string, bool) {
+			return "value of " + name, name == "HOME"
+		},
+	}
+
+	call(t, exec, "puts", &object.String{Value: "out"}, &object.Integer{Value: 1})
+	call(t, exec, "eputs", &object.String{Value: "err"})
+	if stdout.String() != "out 1\n" || stderr.String() != "err\n" {
+		t.Errorf("wrong output. got stdout = %q, stderr = %q", stdout.String(), stderr.String())
+	}
+
+	for _, expected := range []string{"first", "second", "null"} {
+		if line := call(t, exec, "readline"); line.Inspect() != expected {
+			t.Errorf("wrong line. got = %q, want = %q", line.Inspect(), expected)
+		}
+	}
+
+	if now := call(t, exec, "now"); now.Inspect() != "1234" {
+		t.Errorf("wrong time. got = %s, want = 1234", now.Inspect())
+	}
+
+	if home := call(t, exec, "getenv", &object.String{Value: "HOME"}); home.Inspect() != "value of HOME" {
+		t.Errorf("wrong variable. got = %s", home.Inspect())
+	}
+	if unset := call(t, exec, "getenv", &object.String{Value: "UNSET"}); unset.Inspect() != "null" {
+		t.Errorf("wrong unset variable. got = %s, want = null", unset.Inspect())
+	}
+}
+
+func TestExecContextDenied(t *testing.T) {
+	exec := &object.ExecContext{} //nolint:exhaustruct
+	tests := []struct {
+		name     string
+		args     []object.Object
+		expected string
+	}{
+		{"puts", nil, "`puts`: stdout is not available"},
+		{"eputs", nil, "`eputs`: stderr is not available"},
+		{"readline", nil, "`readline`: stdin is not available"},
+		{"now", nil, "`now`: the clock is not available"},
+		{"getenv", []object.Object{&object.String{Value: "HOME"}}, "`getenv`: the environment is not available"},
+	}
+
+	for _, tt := range tests {
+		result, ok := call(t, exec, tt.name, tt.args...).(*object.Error)
+		if !ok || result.Message != tt.expected {
+			t.Errorf("wrong result of %s. got = %v, want an error %q", tt.name, result, tt.expected)
+		}
+	}
+}
+
+func TestExecContextBudget(t *testing.T) {
+	budget := object.NewBudget(context.Background(), object.Limits{MaxArrayLength: 2}) //nolint:exhaustruct
+	exec := object.DefaultExecContext().WithBudget(budget)
+
+	array := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}}
+	result, ok := call(t, exec, "push", array, &object.Integer{Value: 3}).(*object.Error)
+	if !ok || result.Message != "array length limit exceeded (2)" {
+		t.Errorf("wrong result of push. got = %v, want the limit to be exceeded", result)
+	}
+
+	var limitErr *object.LimitExceededError
+	if !errors.As(budget.Err(), &limitErr) || limitErr.Limit != object.ArrayLengthLimit {
+		t.Errorf("expected the budget to fail. got = %v", budget.Err())
+	}
+}
@@ -0,0 +1,44 @@
+package object
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Inspect formats the float in its shortest exact form, always keeping it
+// recognizable as a float: 2.0 is shown as "2.0" rather than "2".
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if math.IsInf(f.Value, 0) || math.IsNaN(f.Value) || strings.ContainsAny(s, ".e") {
+		return s
+	}
+	return s + ".0"
+}
+
+// ToFloat returns the value of a number as a float64. Integers are promoted,
+// which is how mixed integer and float arithmetic is carried out.
+func ToFloat(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
+// IsNumber reports whether obj is an integer or a float.
+func IsNumber(obj Object) bool {
+	_, ok := ToFloat(obj)
+	return ok
+}
@@ -7,6 +7,9 @@ import (
 )
 
 type Function struct {
+	// Name is the name the function was bound to by a let, if it was, which
+	// it's called by within its body.
+	Name       string
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
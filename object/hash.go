@@ -2,11 +2,17 @@ package object
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 )
 
+// Hash maps keys to values. It remembers the order the keys were first set
+// in, which is that hashes are printed and iterated over in.
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	// Keys are those of Pairs in order, Set and Delete keep them in step.
+	Keys []HashKey
 }
 
 type HashPair struct {
@@ -14,14 +20,62 @@ type HashPair struct {
 	Value Object
 }
 
+// NewHash returns an empty hash.
+func NewHash() *Hash {
+	return &Hash{Pairs: map[HashKey]HashPair{}, Keys: []HashKey{}}
+}
+
+// Set sets the pair of key. A key that's already set keeps its place.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.Keys = append(h.Keys, key)
+	}
+	h.Pairs[key] = pair
+}
+
+// Get returns the pair of key, if it's set.
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	pair, ok := h.Pairs[key]
+	return pair, ok
+}
+
+// Delete unsets key, and reports whether it was set.
+func (h *Hash) Delete(key HashKey) bool {
+	if _, ok := h.Pairs[key]; !ok {
+		return false
+	}
+	delete(h.Pairs, key)
+	h.Keys = slices.DeleteFunc(h.Keys, func(k HashKey) bool { return k == key })
+	return true
+}
+
+// Copy returns a hash with the same pairs, in the same order.
+func (h *Hash) Copy() *Hash {
+	return &Hash{Pairs: maps.Clone(h.Pairs), Keys: slices.Clone(h.Keys)}
+}
+
+// Len returns the number of pairs.
+func (h *Hash) Len() int {
+	return len(h.Pairs)
+}
+
+// Ordered returns the pairs, in the order their keys were first set.
+func (h *Hash) Ordered() []HashPair {
+	pairs := make([]HashPair, len(h.Keys))
+	for i, key := range h.Keys {
+		pairs[i] = h.Pairs[key]
+	}
+	return pairs
+}
+
 func (h *Hash) Type() ObjectType {
 	return HASH_OBJ
 }
 
 func (h *Hash) Inspect() string {
 	pairs := []string{}
-	for _, value := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", value.Key.Inspect(), value.Value.Inspect()))
+	for _, pair := range h.Ordered() {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 	return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
 }
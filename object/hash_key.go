@@ -3,6 +3,7 @@ package object
 import (
 	"fmt"
 	"hash/fnv"
+	"math"
 )
 
 type hashKey interface {
@@ -56,6 +57,15 @@ func (i *Integer) HashKey() (HashKey, error) {
 	return NewHashKey(i.Type(), uint64(i.Value)), nil
 }
 
+// HashKey of a float that holds a whole number is the same as the integer's,
+// so that 1.0 and 1, which are equal, also find the same entry in a hash.
+func (f *Float) HashKey() (HashKey, error) {
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return NewHashKey(INTEGER_OBJ, uint64(int64(f.Value))), nil
+	}
+	return NewHashKey(f.Type(), math.Float64bits(f.Value)), nil
+}
+
 func (s *String) HashKey() (HashKey, error) {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -63,7 +73,7 @@ func (s *String) HashKey() (HashKey, error) {
 }
 
 func newTypeNotHashableError(obj Object) error {
-	return fmt.Errorf("object of type '%T' is not hashable", obj.Type())
+	return fmt.Errorf("unusable as hash key: %s", obj.Type())
 }
 
 func (e *Error) HashKey() (HashKey, error) {
@@ -109,3 +119,23 @@ func (q *Quote) HashKey() (HashKey, error) {
 func (m *Macro) HashKey() (HashKey, error) {
 	return ZeroHashKey(), newTypeNotHashableError(m)
 }
+
+func (r *Range) HashKey() (HashKey, error) {
+	return ZeroHashKey(), newTypeNotHashableError(r)
+}
+
+func (it *Iterator) HashKey() (HashKey, error) {
+	return ZeroHashKey(), newTypeNotHashableError(it)
+}
+
+func (b *Break) HashKey() (HashKey, error) {
+	return ZeroHashKey(), newTypeNotHashableError(b)
+}
+
+func (c *Continue) HashKey() (HashKey, error) {
+	return ZeroHashKey(), newTypeNotHashableError(c)
+}
+
+func (c *Cell) HashKey() (HashKey, error) {
+	return ZeroHashKey(), newTypeNotHashableError(c)
+}
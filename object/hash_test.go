@@ -25,6 +25,38 @@ func TestStringHashKey(t *testing.T) {
 	}
 }
 
+func TestFloatHashKey(t *testing.T) {
+	if ensureHashSuccess(t, &object.Float{Value: 2}) != ensureHashSuccess(t, &object.Integer{Value: 2}) {
+		t.Errorf("whole float and the equal integer have different hash keys")
+	}
+
+	if ensureHashSuccess(t, &object.Float{Value: 2.5}) != ensureHashSuccess(t, &object.Float{Value: 2.5}) {
+		t.Errorf("floats with same value and different hash keys")
+	}
+
+	if ensureHashSuccess(t, &object.Float{Value: 2.5}) == ensureHashSuccess(t, &object.Integer{Value: 2}) {
+		t.Errorf("fractional float has the same hash key as an integer")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{2, "2.0"},
+		{0.5, "0.5"},
+		{-1.25, "-1.25"},
+		{1e21, "1e+21"},
+	}
+
+	for _, tt := range tests {
+		if got := (&object.Float{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("wrong inspect. got = %q, want = %q", got, tt.expected)
+		}
+	}
+}
+
 func ensureHashSuccess(t *testing.T, o object.Object) object.HashKey {
 	hashKey, err := o.HashKey()
 	if err != nil {
@@ -33,3 +65,36 @@ func ensureHashSuccess(t *testing.T, o object.Object) object.HashKey {
 
 	return hashKey
 }
+
+func TestHashOrder(t *testing.T) {
+	hash := object.NewHash()
+	set := func(key string, value int64) {
+		k := &object.String{Value: key}
+		hashKey, _ := k.HashKey()
+		hash.Set(hashKey, object.HashPair{Key: k, Value: &object.Integer{Value: value}})
+	}
+
+	set("c", 1)
+	set("a", 2)
+	set("b", 3)
+	set("a", 4)
+	if got, want := hash.Inspect(), "{c: 1, a: 4, b: 3}"; got != want {
+		t.Errorf("wrong order after setting. got = %s, want = %s", got, want)
+	}
+
+	copied := hash.Copy()
+	key, _ := (&object.String{Value: "c"}).HashKey()
+	if !hash.Delete(key) {
+		t.Errorf("expected the key to be deleted")
+	}
+	if hash.Delete(key) {
+		t.Errorf("expected the key to be deleted only once")
+	}
+	set("c", 5)
+	if got, want := hash.Inspect(), "{a: 4, b: 3, c: 5}"; got != want {
+		t.Errorf("wrong order after deleting. got = %s, want = %s", got, want)
+	}
+	if got, want := copied.Inspect(), "{c: 1, a: 4, b: 3}"; got != want {
+		t.Errorf("copy changed with the original. got = %s, want = %s", got, want)
+	}
+}
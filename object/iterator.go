@@ -0,0 +1,78 @@
+package object
+
+import (
+	"fmt"
+)
+
+// Iterator walks over the elements of a collection, it is what `for ... in`
+// loops are built on:
+//   - arrays yield their elements.
+//   - hashes yield [key, value] arrays, in the order the keys were first set.
+//   - strings yield their chars, each as a string of its own.
+//   - ranges yield their integers.
+type Iterator struct {
+	next func() (Object, bool)
+}
+
+func NewIterator(obj Object) (*Iterator, error) {
+	switch obj := obj.(type) {
+	case *Array:
+		return newSliceIterator(obj.Elements), nil
+
+	case *Hash:
+		pairs := obj.Ordered()
+		elements := make([]Object, len(pairs))
+		for i, pair := range pairs {
+			elements[i] = &Array{Elements: []Object{pair.Key, pair.Value}}
+		}
+		return newSliceIterator(elements), nil
+
+	case *String:
+		chars := []rune(obj.Value)
+		elements := make([]Object, len(chars))
+		for i, ch := range chars {
+			elements[i] = &String{Value: string(ch)}
+		}
+		return newSliceIterator(elements), nil
+
+	case *Range:
+		current, remaining := obj.Start, obj.Len()
+		return &Iterator{func() (Object, bool) {
+			if remaining <= 0 {
+				return nil, false
+			}
+			value := current
+			current += obj.Step
+			remaining--
+			return &Integer{Value: value}, true
+		}}, nil
+
+	default:
+		return nil, fmt.Errorf("cannot iterate over %s", obj.Type())
+	}
+}
+
+func newSliceIterator(elements []Object) *Iterator {
+	index := 0
+	return &Iterator{func() (Object, bool) {
+		if index >= len(elements) {
+			return nil, false
+		}
+		element := elements[index]
+		index++
+		return element, true
+	}}
+}
+
+// Next returns the next element, or false once there are no elements left.
+func (it *Iterator) Next() (Object, bool) {
+	return it.next()
+}
+
+func (it *Iterator) Type() ObjectType {
+	return ITERATOR_OBJ
+}
+
+func (it *Iterator) Inspect() string {
+	return "iterator"
+}
@@ -0,0 +1,193 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Limits bound what a program may use while running, so that untrusted code
+// can be run safely. Zero fields are not limited.
+type Limits struct {
+	// MaxInstructions bounds the instructions run by the vm, or the nodes
+	// evaluated by the tree engine.
+	MaxInstructions int64
+	// MaxDepth bounds the calls in progress at once, DefaultMaxDepth if zero.
+	MaxDepth int
+	// MaxAllocations bounds the strings, arrays, hashes and functions
+	// created.
+	MaxAllocations int64
+	// MaxStringLength bounds the length of strings in bytes, MaxArrayLength
+	// the elements of arrays and the pairs of hashes.
+	MaxStringLength int
+	MaxArrayLength  int
+}
+
+// Limit names one of the Limits.
+type Limit string
+
+const (
+	InstructionLimit  Limit = "instruction"
+	DepthLimit        Limit = "call depth"
+	AllocationLimit   Limit = "allocation"
+	StringLengthLimit Limit = "string length"
+	ArrayLengthLimit  Limit = "array length"
+)
+
+// LimitExceededError is returned when a program exceeds one of its Limits.
+type LimitExceededError struct {
+	Limit Limit
+	Max   int64
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s limit exceeded (%d)", e.Limit, e.Max)
+}
+
+// DefaultMaxDepth bounds the calls in progress at once when Limits don't: as
+// many as the vm has frames for. Calls any deeper would overflow the Go stack
+// of the tree engine, which can't be recovered from.
+const DefaultMaxDepth = 1024
+
+// ErrStackOverflow is returned once the calls in progress exceed
+// DefaultMaxDepth.
+var ErrStackOverflow = errors.New("stack overflow")
+
+// checkInterval is how many instructions run between two checks of the
+// context, which are comparatively slow.
+const checkInterval = 1024
+
+// Budget keeps track of what a running program has used of its Limits, and
+// of whether the context it runs in is done. Once it has failed it keeps
+// failing with the same error, so that the program stops for good.
+type Budget struct {
+	ctx    context.Context
+	limits Limits
+
+	instructions int64
+	depth        int
+	allocations  int64
+
+	err error
+}
+
+func NewBudget(ctx context.Context, limits Limits) *Budget {
+	return &Budget{
+		ctx:          ctx,
+		limits:       limits,
+		instructions: 0,
+		depth:        0,
+		allocations:  0,
+		err:          nil,
+	}
+}
+
+// Err returns why the budget failed, nil if it hasn't.
+func (b *Budget) Err() error {
+	return b.err
+}
+
+func (b *Budget) fail(err error) error {
+	if b.err == nil {
+		b.err = err
+	}
+	return b.err
+}
+
+func (b *Budget) exceeded(limit Limit, value int64) error {
+	return b.fail(&LimitExceededError{Limit: limit, Max: value})
+}
+
+// Step counts an instruction about to run.
+func (b *Budget) Step() error {
+	if b.err != nil {
+		return b.err
+	}
+
+	b.instructions++
+	if b.limits.MaxInstructions > 0 && b.instructions > b.limits.MaxInstructions {
+		return b.exceeded(InstructionLimit, b.limits.MaxInstructions)
+	}
+	if b.instructions%checkInterval == 1 {
+		if err := b.ctx.Err(); err != nil {
+			return b.fail(err)
+		}
+	}
+	return nil
+}
+
+// Enter counts a call starting, Leave one returning.
+func (b *Budget) Enter() error {
+	b.depth++
+	if b.limits.MaxDepth > 0 && b.depth > b.limits.MaxDepth {
+		return b.exceeded(DepthLimit, int64(b.limits.MaxDepth))
+	}
+	if b.depth > DefaultMaxDepth && b.limits.MaxDepth == 0 {
+		return b.fail(ErrStackOverflow)
+	}
+	return nil
+}
+
+func (b *Budget) Leave() {
+	b.depth--
+}
+
+// Allocate counts obj, just created by the program, if it's one of the values
+// Limits bound.
+func (b *Budget) Allocate(obj Object) error {
+	switch obj.(type) {
+	case *String, *Array, *Hash, *Function, *Closure:
+	default:
+		return nil
+	}
+
+	if err := b.AllocateN(1); err != nil {
+		return err
+	}
+	return b.CheckSize(obj)
+}
+
+// AllocateN counts n values about to be created, for those created by
+// builtins on top of the one they return.
+func (b *Budget) AllocateN(n int) error {
+	if b.err != nil {
+		return b.err
+	}
+
+	b.allocations += int64(n)
+	if b.limits.MaxAllocations > 0 && b.allocations > b.limits.MaxAllocations {
+		return b.exceeded(AllocationLimit, b.limits.MaxAllocations)
+	}
+	return nil
+}
+
+// CheckSize checks the size of obj against Limits, once it has grown.
+func (b *Budget) CheckSize(obj Object) error {
+	switch obj := obj.(type) {
+	case *String:
+		return b.CheckStringLength(len(obj.Value))
+	case *Array:
+		return b.CheckArrayLength(len(obj.Elements))
+	case *Hash:
+		return b.CheckArrayLength(obj.Len())
+	}
+	return nil
+}
+
+// CheckStringLength checks the length in bytes of a string against Limits,
+// so that builtins can before they create it.
+func (b *Budget) CheckStringLength(length int) error {
+	if limit := b.limits.MaxStringLength; limit > 0 && length > limit {
+		return b.exceeded(StringLengthLimit, int64(limit))
+	}
+	return nil
+}
+
+// CheckArrayLength checks the elements of an array, or the pairs of a hash,
+// against Limits, so that builtins can before they create it.
+func (b *Budget) CheckArrayLength(length int) error {
+	if limit := b.limits.MaxArrayLength; limit > 0 && length > limit {
+		return b.exceeded(ArrayLengthLimit, int64(limit))
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package object
+
+// Break and Continue are the signals that `break` and `continue` statements
+// evaluate to. Like ReturnValue, they bubble up through the enclosing blocks
+// until they reach the loop they act on.
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
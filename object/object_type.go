@@ -4,6 +4,7 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ           ObjectType = "INTEGER"
+	FLOAT_OBJ             ObjectType = "FLOAT"
 	BOOLEAN_OBJ           ObjectType = "BOOLEAN"
 	NULL_OBJ              ObjectType = "NULL"
 	RETURN_VALUE_OBJ      ObjectType = "RETURN"
@@ -17,4 +18,9 @@ const (
 	MACRO_OBJ             ObjectType = "MACRO"
 	COMPILED_FUNCTION_OBJ ObjectType = "COMPILED_FUNCTION"
 	CLOSURE_OBJ           ObjectType = "CLOSURE"
+	RANGE_OBJ             ObjectType = "RANGE"
+	ITERATOR_OBJ          ObjectType = "ITERATOR"
+	BREAK_OBJ             ObjectType = "BREAK"
+	CONTINUE_OBJ          ObjectType = "CONTINUE"
+	CELL_OBJ              ObjectType = "CELL"
 )
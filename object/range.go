@@ -0,0 +1,48 @@
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+// Range is the sequence of integers from Start (inclusive) to End (exclusive),
+// Step apart. Step is never 0, and may be negative to count down.
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType {
+	return RANGE_OBJ
+}
+
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.End)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// Len returns the number of integers in the range, at most math.MaxInt64.
+// It's computed in uint64, which the distance between any two int64 fits in.
+func (r *Range) Len() int64 {
+	var distance, step uint64
+	if r.Step > 0 {
+		if r.End <= r.Start {
+			return 0
+		}
+		distance, step = uint64(r.End)-uint64(r.Start), uint64(r.Step)
+	} else {
+		if r.Start <= r.End {
+			return 0
+		}
+		distance, step = uint64(r.Start)-uint64(r.End), -uint64(r.Step)
+	}
+
+	length := distance / step
+	if distance%step != 0 {
+		length++
+	}
+	return int64(min(length, math.MaxInt64))
+}
@@ -0,0 +1,31 @@
+package object_test
+
+import (
+	"math"
+	"monkey/object"
+	"testing"
+)
+
+func TestRangeLen(t *testing.T) {
+	tests := []struct {
+		r        object.Range
+		expected int64
+	}{
+		{object.Range{Start: 0, End: 10, Step: 1}, 10},
+		{object.Range{Start: 0, End: 10, Step: 3}, 4},
+		{object.Range{Start: 10, End: 0, Step: -3}, 4},
+		{object.Range{Start: 5, End: 5, Step: 1}, 0},
+		{object.Range{Start: 5, End: 0, Step: 1}, 0},
+		{object.Range{Start: 0, End: 5, Step: -1}, 0},
+		{object.Range{Start: math.MinInt64, End: math.MaxInt64, Step: 1}, math.MaxInt64},
+		{object.Range{Start: math.MinInt64, End: math.MaxInt64, Step: 4}, 1 << 62},
+		{object.Range{Start: math.MaxInt64, End: math.MinInt64, Step: math.MinInt64}, 2},
+		{object.Range{Start: -1, End: math.MaxInt64, Step: math.MaxInt64}, 2},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.Len(); got != tt.expected {
+			t.Errorf("wrong length of %s. got = %d, want = %d", tt.r.Inspect(), got, tt.expected)
+		}
+	}
+}
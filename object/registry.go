@@ -0,0 +1,210 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Variadic is the MaxArgs of the builtins taking any number of arguments.
+const Variadic = -1
+
+// MaxBuiltins is how many builtins a registry holds at most, as the
+// instructions refer to them with a single byte.
+const MaxBuiltins = 256
+
+// Param is a parameter of a builtin.
+type Param struct {
+	Name string
+	// Types are those of the arguments the parameter accepts, any if empty.
+	Types []ObjectType
+}
+
+// Signature declares how a builtin is called: the arguments are checked
+// against it before the builtin runs.
+type Signature struct {
+	Name string
+
+	// Params are the parameters of the builtin, the last one repeated for
+	// the arguments beyond.
+	Params []Param
+	// MinArgs and MaxArgs bound the number of arguments, MaxArgs is Variadic
+	// if there's no upper bound.
+	MinArgs int
+	MaxArgs int
+
+	// Doc describes what the builtin does, and Usage how it's called if that's
+	// not clear from its parameters, for tools such as the language server.
+	Doc   string
+	Usage string
+}
+
+// String returns how the builtin is called, e.g. "round(number, [digits])".
+func (s Signature) String() string {
+	if s.Usage != "" {
+		return s.Usage
+	}
+
+	params := []string{}
+	for i, param := range s.Params {
+		switch {
+		case s.MaxArgs == Variadic && i == len(s.Params)-1:
+			params = append(params, param.Name+"...")
+		case i >= s.MinArgs:
+			params = append(params, "["+param.Name+"]")
+		default:
+			params = append(params, param.Name)
+		}
+	}
+	return fmt.Sprintf("%s(%s)", s.Name, strings.Join(params, ", "))
+}
+
+// Check returns the error of a call to the builtin with args, nil if there's
+// none.
+func (s Signature) Check(args []Object) *Error {
+	if len(args) < s.MinArgs || (s.MaxArgs != Variadic && len(args) > s.MaxArgs) {
+		return newError("wrong number of arguments. got = %d, want = %s", len(args), s.arity())
+	}
+
+	for i, arg := range args {
+		if len(s.Params) == 0 {
+			break
+		}
+		param := s.Params[min(i, len(s.Params)-1)]
+		if len(param.Types) == 0 || accepts(param.Types, arg.Type()) {
+			continue
+		}
+
+		argument := "argument"
+		if s.MaxArgs != 1 {
+			argument = ordinal(i) + " argument"
+		}
+		return newError("%s to `%s` must be %s, got %s", argument, s.Name, alternatives(param.Types), arg.Type())
+	}
+
+	return nil
+}
+
+func (s Signature) arity() string {
+	switch {
+	case s.MaxArgs == Variadic:
+		return fmt.Sprintf("at least %d", s.MinArgs)
+	case s.MaxArgs == s.MinArgs:
+		return fmt.Sprintf("%d", s.MinArgs)
+	case s.MaxArgs == s.MinArgs+1:
+		return fmt.Sprintf("%d or %d", s.MinArgs, s.MaxArgs)
+	default:
+		return fmt.Sprintf("%d to %d", s.MinArgs, s.MaxArgs)
+	}
+}
+
+func accepts(types []ObjectType, t ObjectType) bool {
+	for _, accepted := range types {
+		if accepted == t {
+			return true
+		}
+	}
+	return false
+}
+
+// alternatives lists types as "A", "A or B", "A, B or C"...
+func alternatives(types []ObjectType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+func ordinal(i int) string {
+	ordinals := []string{"first", "second", "third", "fourth", "fifth"}
+	if i < len(ordinals) {
+		return ordinals[i]
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
+
+// newBuiltin returns the builtin running fn, once the arguments have been
+// checked against sig.
+func newBuiltin(sig Signature, fn BuiltinFunction) BuiltinItem {
+	return BuiltinItem{
+		Signature: sig,
+		Builtin: &Builtin{Fn: func(ctx *ExecContext, args ...Object) Object {
+			if err := sig.Check(args); err != nil {
+				return err
+			}
+			return fn(ctx, args...)
+		}},
+	}
+}
+
+// BuiltinRegistry holds the builtins of an interpreter: the standard ones, and
+// those the program embedding it registers. Builtins are referred to by their
+// index, which is the order they were registered in.
+//
+// Engines that are given no registry use the standard builtins.
+type BuiltinRegistry struct {
+	builtins []BuiltinItem
+	indices  map[string]int
+}
+
+// NewBuiltinRegistry returns a registry holding the standard builtins.
+func NewBuiltinRegistry() *BuiltinRegistry {
+	r := &BuiltinRegistry{
+		builtins: make([]BuiltinItem, 0, len(Builtins)),
+		indices:  map[string]int{},
+	}
+	for _, builtin := range Builtins {
+		r.add(builtin)
+	}
+	return r
+}
+
+// Register adds the builtin called sig.Name, running fn once the arguments
+// have been checked against sig. Names can't be registered twice.
+func (r *BuiltinRegistry) Register(sig Signature, fn BuiltinFunction) error {
+	switch {
+	case sig.Name == "":
+		return fmt.Errorf("builtins must have a name")
+	case sig.MinArgs < 0 || (sig.MaxArgs != Variadic && sig.MaxArgs < sig.MinArgs):
+		return fmt.Errorf("builtin %s: invalid number of arguments: %d to %d", sig.Name, sig.MinArgs, sig.MaxArgs)
+	}
+	if _, ok := r.indices[sig.Name]; ok {
+		return fmt.Errorf("builtin %s is already registered", sig.Name)
+	}
+	if len(r.builtins) >= MaxBuiltins {
+		return fmt.Errorf("builtin %s: there can't be more than %d builtins", sig.Name, MaxBuiltins)
+	}
+
+	r.add(newBuiltin(sig, fn))
+	return nil
+}
+
+func (r *BuiltinRegistry) add(builtin BuiltinItem) {
+	r.indices[builtin.Name] = len(r.builtins)
+	r.builtins = append(r.builtins, builtin)
+}
+
+// Lookup returns the builtin called name.
+func (r *BuiltinRegistry) Lookup(name string) (*Builtin, bool) {
+	index, ok := r.indices[name]
+	if !ok {
+		return nil, false
+	}
+	return r.builtins[index].Builtin, true
+}
+
+// At returns the builtin at index.
+func (r *BuiltinRegistry) At(index int) (*Builtin, bool) {
+	if index < 0 || index >= len(r.builtins) {
+		return nil, false
+	}
+	return r.builtins[index].Builtin, true
+}
+
+// All returns the builtins, by index.
+func (r *BuiltinRegistry) All() []BuiltinItem {
+	return r.builtins
+}
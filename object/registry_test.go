@@ -0,0 +1,132 @@
+package object_test
+
+import (
+	"monkey/object"
+	"strings"
+	"testing"
+)
+
+func TestSignatureCheck(t *testing.T) {
+	numbers := []object.ObjectType{object.INTEGER_OBJ, object.FLOAT_OBJ}
+	tests := []struct {
+		sig      object.Signature
+		args     []object.Object
+		expected string
+	}{
+		{
+			object.Signature{Name: "f", Params: []object.Param{{Name: "x", Types: numbers}}, MinArgs: 1, MaxArgs: 1, Doc: "", Usage: ""},
+			[]object.Object{&object.Integer{Value: 1}},
+			"",
+		},
+		{
+			object.Signature{Name: "f", Params: []object.Param{{Name: "x", Types: numbers}}, MinArgs: 1, MaxArgs: 1, Doc: "", Usage: ""},
+			[]object.Object{},
+			"wrong number of arguments. got = 0, want = 1",
+		},
+		{
+			object.Signature{Name: "f", Params: []object.Param{{Name: "x", Types: numbers}}, MinArgs: 1, MaxArgs: 1, Doc: "", Usage: ""},
+			[]object.Object{&object.String{Value: "a"}},
+			"argument to `f` must be INTEGER or FLOAT, got STRING",
+		},
+		{
+			object.Signature{Name: "f", Params: []object.Param{{Name: "x", Types: nil}, {Name: "y", Types: numbers}}, MinArgs: 1, MaxArgs: 2, Doc: "", Usage: ""},
+			[]object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}, &object.Integer{Value: 3}},
+			"wrong number of arguments. got = 3, want = 1 or 2",
+		},
+		{
+			object.Signature{Name: "f", Params: []object.Param{{Name: "x", Types: nil}, {Name: "y", Types: numbers}}, MinArgs: 0, MaxArgs: 3, Doc: "", Usage: ""},
+			[]object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}, &object.Boolean{Value: true}},
+			"third argument to `f` must be INTEGER or FLOAT, got BOOLEAN",
+		},
+		{
+			object.Signature{Name: "f", Params: []object.Param{{Name: "xs", Types: numbers}}, MinArgs: 2, MaxArgs: object.Variadic, Doc: "", Usage: ""},
+			[]object.Object{&object.Integer{Value: 1}},
+			"wrong number of arguments. got = 1, want = at least 2",
+		},
+	}
+
+	for _, tt := range tests {
+		err := tt.sig.Check(tt.args)
+		switch {
+		case tt.expected == "" && err != nil:
+			t.Errorf("unexpected error: %s", err.Message)
+		case tt.expected != "" && err == nil:
+			t.Errorf("expected error %q, got none", tt.expected)
+		case err != nil && err.Message != tt.expected:
+			t.Errorf("wrong error. got = %q, want = %q", err.Message, tt.expected)
+		}
+	}
+}
+
+func TestSignatureString(t *testing.T) {
+	tests := []struct {
+		sig      object.Signature
+		expected string
+	}{
+		{
+			object.Signature{Name: "round", Params: []object.Param{{Name: "number", Types: nil}, {Name: "digits", Types: nil}}, MinArgs: 1, MaxArgs: 2, Doc: "", Usage: ""},
+			"round(number, [digits])",
+		},
+		{
+			object.Signature{Name: "sprintf", Params: []object.Param{{Name: "format", Types: nil}, {Name: "values", Types: nil}}, MinArgs: 1, MaxArgs: object.Variadic, Doc: "", Usage: ""},
+			"sprintf(format, values...)",
+		},
+		{
+			object.Signature{Name: "range", Params: nil, MinArgs: 1, MaxArgs: 3, Doc: "", Usage: "range(end) or range(start, end)"},
+			"range(end) or range(start, end)",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.sig.String(); got != tt.expected {
+			t.Errorf("wrong signature. got = %q, want = %q", got, tt.expected)
+		}
+	}
+}
+
+func TestBuiltinRegistry(t *testing.T) {
+	registry := object.NewBuiltinRegistry()
+	if len(registry.All()) != len(object.Builtins) {
+		t.Fatalf("wrong number of builtins. got = %d, want = %d", len(registry.All()), len(object.Builtins))
+	}
+
+	sig := object.Signature{Name: "answer", Params: nil, MinArgs: 0, MaxArgs: 0, Doc: "", Usage: ""}
+	err := registry.Register(sig, func(_ *object.ExecContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: 42}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	builtin, ok := registry.Lookup("answer")
+	if !ok {
+		t.Fatalf("registered builtin not found")
+	}
+	if result := builtin.Fn(object.DefaultExecContext()); result.Inspect() != "42" {
+		t.Errorf("wrong result. got = %s, want = 42", result.Inspect())
+	}
+	if result := builtin.Fn(object.DefaultExecContext(), &object.Integer{Value: 1}); result.Inspect() != "ERROR: wrong number of arguments. got = 1, want = 0" {
+		t.Errorf("arguments not checked. got = %s", result.Inspect())
+	}
+	if at, ok := registry.At(len(object.Builtins)); !ok || at != builtin {
+		t.Errorf("registered builtin not at the next index")
+	}
+
+	if _, ok := object.NewBuiltinRegistry().Lookup("answer"); ok {
+		t.Errorf("builtin registered in every registry")
+	}
+
+	if err := registry.Register(sig, nil); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("wrong error registering a builtin twice: %v", err)
+	}
+	for i := len(registry.All()); i < object.MaxBuiltins; i++ {
+		sig.Name = "answer" + strings.Repeat("!", i)
+		if err := registry.Register(sig, nil); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	sig.Name = "one too many"
+	if err := registry.Register(sig, nil); err == nil {
+		t.Errorf("registered more than %d builtins", object.MaxBuiltins)
+	}
+}
@@ -1,5 +1,11 @@
 package object
 
+import "unicode/utf8"
+
+// String is an immutable UTF-8 string.
+//
+// Everything that measures or indexes a string does so in chars (runes),
+// never in bytes: len("héllo") is 5, and "héllo"[1] is "é".
 type String struct {
 	Value string
 }
@@ -11,3 +17,25 @@ func (s *String) Type() ObjectType {
 func (s *String) Inspect() string {
 	return s.Value
 }
+
+// Len returns the number of chars in the string.
+func (s *String) Len() int {
+	return utf8.RuneCountInString(s.Value)
+}
+
+// CharAt returns the char at the given index as a string of its own, or false
+// if the index is out of bounds.
+func (s *String) CharAt(index int64) (*String, bool) {
+	if index < 0 {
+		return nil, false
+	}
+
+	for i, ch := range s.Value {
+		if index == 0 {
+			return &String{Value: s.Value[i : i+utf8.RuneLen(ch)]}, true
+		}
+		index--
+	}
+
+	return nil, false
+}
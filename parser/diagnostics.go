@@ -0,0 +1,16 @@
+package parser
+
+import "monkey/diagnostic"
+
+// Codes of the diagnostics reported by the parser.
+const (
+	CodeUnexpectedToken         diagnostic.Code = "P0001"
+	CodeExpectedExpression      diagnostic.Code = "P0002"
+	CodeInvalidInteger          diagnostic.Code = "P0003"
+	CodeInvalidParameter        diagnostic.Code = "P0004"
+	CodeIllegalCharacter        diagnostic.Code = "P0005"
+	CodeLoopControlOutsideLoop  diagnostic.Code = "P0006"
+	CodeInvalidAssignmentTarget diagnostic.Code = "P0007"
+	CodeInvalidFloat            diagnostic.Code = "P0008"
+	CodeInvalidImportPath       diagnostic.Code = "P0009"
+)
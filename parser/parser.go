@@ -1,8 +1,8 @@
 package parser
 
 import (
-	"fmt"
 	"monkey/ast"
+	"monkey/diagnostic"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
@@ -12,10 +12,10 @@ type prefixParseFn func() ast.Expression
 
 type infixParseFn func(ast.Expression) ast.Expression
 
-func Parse(input string) (*ast.Program, []string) {
+func Parse(input string) (*ast.Program, []diagnostic.Diagnostic) {
 	p := New(lexer.New(input))
 	program := p.ParseProgram()
-	return program, p.errors
+	return program, p.Diagnostics()
 }
 
 type Parser struct {
@@ -27,18 +27,24 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 
-	errors []string
+	diagnostics      []diagnostic.Diagnostic
+	lexerDiagnostics int // how many of the lexer's diagnostics were already collected
+
+	// loopDepth is how many loops enclose the current token within the current
+	// function, it decides whether `break` and `continue` are allowed.
+	loopDepth int
 }
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{ //nolint:exhaustruct
-		l:      l,
-		errors: []string{},
+		l:           l,
+		diagnostics: []diagnostic.Diagnostic{},
 	}
 
 	p.prefixParseFns = map[token.TokenType]prefixParseFn{
 		token.IDENT:    p.parseIdentifier,
 		token.INT:      p.parseIntegerLiteral,
+		token.FLOAT:    p.parseFloatLiteral,
 		token.TRUE:     p.parseBoolean,
 		token.FALSE:    p.parseBoolean,
 		token.BANG:     p.parsePrefixExpression,
@@ -50,6 +56,7 @@ func New(l *lexer.Lexer) *Parser {
 		token.STRING:   p.parseStringLiteral,
 		token.LBRACKET: p.parseArrayLiteral,
 		token.LBRACE:   p.parseHashLiteral,
+		token.IMPORT:   p.parseImportExpression,
 	}
 
 	p.infixParseFns = map[token.TokenType]infixParseFn{
@@ -76,6 +83,7 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) parseHashLiteral() ast.Expression {
 	curToken := p.curToken
 
+	keys := []ast.Expression{}
 	pairs := map[ast.Expression]ast.Expression{}
 
 	for !p.peekTokenIs(token.RBRACE) {
@@ -87,6 +95,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 
 		val := p.parseExpression(LOWEST)
+		keys = append(keys, key)
 		pairs[key] = val
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
@@ -97,7 +106,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		return nil
 	}
 
-	return ast.NewHashLiteral(curToken, pairs)
+	return ast.NewHashLiteral(curToken, keys, pairs)
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
@@ -135,6 +144,10 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		// The list may end with a comma, as lists spanning several lines do.
+		if p.peekTokenIs(end) {
+			break
+		}
 		p.nextToken()
 		list = append(list, p.parseExpression(LOWEST))
 	}
@@ -146,14 +159,22 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	curToken := p.curToken // the "(" token
 	arguments := p.parseExpressionList(token.RPAREN)
-	exp := ast.NewCallExpression(p.curToken, function, arguments)
+	exp := ast.NewCallExpression(curToken, function, arguments)
 	return exp
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	// Picking up whatever the lexer complained about while reading the token,
+	// which keeps all the diagnostics in source order.
+	if lexerDiagnostics := p.l.Diagnostics(); len(lexerDiagnostics) > p.lexerDiagnostics {
+		p.diagnostics = append(p.diagnostics, lexerDiagnostics[p.lexerDiagnostics:]...)
+		p.lexerDiagnostics = len(lexerDiagnostics)
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -161,20 +182,81 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.curToken.Type != token.EOF {
-		stmt := p.parseStatement()
-		program.Statements = append(program.Statements, stmt)
+		if stmt := p.parseStatementRecovering(); stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
 		p.nextToken()
 	}
 
 	return program
 }
 
+// parseStatementRecovering parses a statement, and if that fails, skips ahead
+// to where the next statement probably starts. This way a single mistake does
+// not hide (or cascade into) errors in everything that follows it.
+func (p *Parser) parseStatementRecovering() ast.Statement {
+	errorsBefore := len(p.diagnostics)
+
+	stmt := p.parseStatement()
+	if len(p.diagnostics) > errorsBefore {
+		p.synchronize()
+	}
+
+	return stmt
+}
+
+// synchronize advances until the current token ends a statement, or until the
+// next token starts a new one.
+func (p *Parser) synchronize() {
+	depth := 0
+	for !p.curTokenIs(token.EOF) && !p.peekTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		}
+
+		if depth == 0 && (p.peekTokenIs(token.LET) || p.peekTokenIs(token.RETURN) || p.peekTokenIs(token.RBRACE)) {
+			return
+		}
+
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
+	// The explicit nil returns are there to avoid returning typed nil pointers
+	// wrapped in a non-nil interface.
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.WHILE:
+		if stmt := p.parseWhileStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.FOR:
+		if stmt := p.parseForStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -185,6 +267,10 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
+	if stmt.ReturnValue == nil {
+		return nil
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -208,6 +294,9 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
 
 	// Tailoring an assignment of name to a function in case it is a function!
 	fn, ok := stmt.Value.(*ast.FunctionLiteral)
@@ -221,9 +310,152 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	tok := p.curToken
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	condition := p.parseExpression(LOWEST)
+	if condition == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	body := p.parseLoopBody()
+	if body == nil {
+		return nil
+	}
+
+	return ast.NewWhileStatement(tok, condition, body)
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	tok := p.curToken
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	variable := ast.NewIdentifier(p.curToken, p.curToken.Literal)
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	iterable := p.parseExpression(LOWEST)
+	if iterable == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	body := p.parseLoopBody()
+	if body == nil {
+		return nil
+	}
+
+	return ast.NewForStatement(tok, variable, iterable, body)
+}
+
+// parseLoopBody parses the block of a loop, starting at the token before the "{".
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loopDepth++
+	body := p.parseBlockStatement()
+	p.loopDepth--
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return body
+}
+
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if p.loopDepth == 0 {
+		p.push(diagnostic.Errorf(CodeLoopControlOutsideLoop, tok.Span, "`%s` outside of a loop", tok.Literal))
+		return nil
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
+// parseExpressionStatement parses a statement made of an expression, which
+// also covers assignments since their target is parsed as an expression.
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken} //nolint:exhaustruct
 	stmt.Expression = p.parseExpression(LOWEST)
+	if stmt.Expression == nil {
+		return nil
+	}
+
+	if _, ok := assignmentOperators[p.peekToken.Type]; ok {
+		if assign := p.parseAssignStatement(stmt.Expression); assign != nil {
+			return assign
+		}
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+var assignmentOperators = map[token.TokenType]struct{}{
+	token.ASSIGN:         {},
+	token.PLUS_ASSIGN:    {},
+	token.MINUS_ASSIGN:   {},
+	token.ASTERIX_ASSIGN: {},
+	token.SLASH_ASSIGN:   {},
+}
+
+// parseAssignStatement parses an assignment to the already parsed target,
+// starting at the token before the assignment operator.
+func (p *Parser) parseAssignStatement(target ast.Expression) *ast.AssignStatement {
+	p.nextToken()
+	stmt := &ast.AssignStatement{Token: p.curToken, Target: target, Operator: p.curToken.Literal} //nolint:exhaustruct
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.push(diagnostic.Errorf(
+			CodeInvalidAssignmentTarget,
+			target.Span(),
+			"cannot assign to %s", target.String(),
+		).WithNote("only variables and elements of arrays or hashes can be assigned to"))
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -231,16 +463,27 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
-func (p *Parser) pushNoPrefixParseFnError(tokenType token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", tokenType)
-	p.errors = append(p.errors, msg)
+func (p *Parser) pushNoPrefixParseFnError(tok token.Token) {
+	if tok.Type == token.ILLEGAL {
+		p.push(diagnostic.Errorf(CodeIllegalCharacter, tok.Span, "illegal character %q", tok.Literal))
+		return
+	}
+
+	p.push(
+		diagnostic.Errorf(CodeExpectedExpression, tok.Span, "no prefix parse function for %s found", tok.Type).
+			WithNote("an expression was expected here"),
+	)
+}
+
+func (p *Parser) push(d diagnostic.Diagnostic) {
+	p.diagnostics = append(p.diagnostics, d)
 }
 
 func (p *Parser) parseExpression(precedence Precedence) ast.Expression {
 	prefix, ok := p.prefixParseFns[p.curToken.Type]
 
 	if !ok {
-		p.pushNoPrefixParseFnError(p.curToken.Type)
+		p.pushNoPrefixParseFnError(p.curToken)
 		return nil
 	}
 
@@ -334,8 +577,9 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		blockStatements = append(blockStatements, stmt)
+		if stmt := p.parseStatementRecovering(); stmt != nil {
+			blockStatements = append(blockStatements, stmt)
+		}
 		p.nextToken()
 	}
 
@@ -357,12 +601,22 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	strLiteral := p.curToken.Literal
 	value, err := strconv.ParseInt(strLiteral, 0, 64)
 	if err != nil {
-		p.errors = append(p.errors, fmt.Sprintf("Could not parse %q as integer", strLiteral))
+		p.push(diagnostic.Errorf(CodeInvalidInteger, p.curToken.Span, "Could not parse %q as integer", strLiteral))
 		return nil
 	}
 	return ast.NewIntegerLiteral(p.curToken, value)
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	strLiteral := p.curToken.Literal
+	value, err := strconv.ParseFloat(strLiteral, 64)
+	if err != nil {
+		p.push(diagnostic.Errorf(CodeInvalidFloat, p.curToken.Span, "Could not parse %q as float", strLiteral))
+		return nil
+	}
+	return ast.NewFloatLiteral(p.curToken, value)
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return ast.NewBoolean(p.curToken, p.curTokenIs(token.TRUE))
 }
@@ -379,11 +633,22 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	body := p.parseBlockStatement()
+	body := p.parseFunctionBody()
 
 	return ast.NewFunctionLiteral(curToken, params, body, "")
 }
 
+// parseFunctionBody parses the block of a function (or macro). Loops don't
+// reach into function bodies, a `break` in a function defined within a loop
+// has nothing to break out of.
+func (p *Parser) parseFunctionBody() *ast.BlockStatement {
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
+	return p.parseBlockStatement()
+}
+
 func (p *Parser) parseMacroLiteral() ast.Expression {
 	curToken := p.curToken
 	if !p.expectPeek(token.LPAREN) {
@@ -396,11 +661,37 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 		return nil
 	}
 
-	body := p.parseBlockStatement()
+	body := p.parseFunctionBody()
 
 	return ast.NewMacroLiteral(curToken, params, body)
 }
 
+// parseImportExpression parses `import("path")`. The path has to be a string
+// literal, so that the modules a program imports are known before running it.
+func (p *Parser) parseImportExpression() ast.Expression {
+	tok := p.curToken
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.STRING) {
+		p.push(diagnostic.Errorf(
+			CodeInvalidImportPath,
+			p.peekToken.Span,
+			"the path of an import must be a string literal",
+		))
+		return nil
+	}
+	p.nextToken()
+	path := ast.NewStringLiteral(p.curToken, p.curToken.Literal)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return ast.NewImportExpression(tok, path)
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
@@ -411,21 +702,12 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	}
 
 	p.nextToken()
-
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	p.parseFunctionParameter(&identifiers)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-
-		if p.curToken.Type != token.IDENT {
-			p.errors = append(p.errors, "argument in function definition must be an identifier")
-			continue
-		}
-
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		p.parseFunctionParameter(&identifiers)
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -435,6 +717,19 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	return identifiers
 }
 
+func (p *Parser) parseFunctionParameter(identifiers *[]*ast.Identifier) {
+	if p.curToken.Type != token.IDENT {
+		p.push(diagnostic.Errorf(
+			CodeInvalidParameter,
+			p.curToken.Span,
+			"argument in function definition must be an identifier",
+		))
+		return
+	}
+
+	*identifiers = append(*identifiers, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -452,11 +747,44 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	return false
 }
 
+// Errors returns the problems found during parsing, each formatted as
+// "file:line:col: message". See Diagnostics for the structured version.
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := make([]string, 0, len(p.diagnostics))
+	for _, d := range p.diagnostics {
+		errors = append(errors, d.Error())
+	}
+	return errors
+}
+
+func (p *Parser) Diagnostics() []diagnostic.Diagnostic {
+	return p.diagnostics
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	d := diagnostic.Errorf(
+		CodeUnexpectedToken,
+		p.peekToken.Span,
+		"expected next token to be %s, got %s instead", t, p.peekToken.Type,
+	)
+
+	if closing, ok := closingDelimiters[t]; ok {
+		// Pointing right after the current token, which is where the missing
+		// delimiter would go.
+		end := p.curToken.Span
+		end.Start = end.End
+		d = d.WithSuggestion(diagnostic.Suggestion{
+			Message:     "insert the missing `" + closing + "`",
+			Span:        end,
+			Replacement: closing,
+		})
+	}
+
+	p.push(d)
+}
+
+var closingDelimiters = map[token.TokenType]string{
+	token.RPAREN:   ")",
+	token.RBRACE:   "}",
+	token.RBRACKET: "]",
 }
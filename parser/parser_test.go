@@ -3,6 +3,7 @@ package parser_test
 import (
 	"fmt"
 	"monkey/ast"
+	"monkey/diagnostic"
 	"monkey/lexer"
 	"monkey/parser"
 	"monkey/testutils"
@@ -289,6 +290,39 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "2.5e-1;"
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if gotLen := len(program.Statements); gotLen != 1 {
+		t.Fatalf("program has not enough statements. got = %d, expected = 1", gotLen)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf(
+			"program.Statements[0] is not ast.ExpressionStatement. got = %T",
+			program.Statements[0],
+		)
+	}
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("expression is not ast.FloatLiteral. got = %T", stmt.Expression)
+	}
+
+	if literal.Value != 0.25 {
+		t.Errorf("literal has wrong value. got = %g, expected = 0.25", literal.Value)
+	}
+
+	if literal.String() != "2.5e-1" {
+		t.Errorf("literal has wrong string. got = %q", literal.String())
+	}
+}
+
 func TestBooleanExpression(t *testing.T) {
 	cases := []struct {
 		name   string
@@ -804,6 +838,36 @@ func TestParsingArrayLiterals(t *testing.T) {
 	testInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
 
+func TestParsingTrailingCommas(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2,]", "(program (expr [1 2]))"},
+		{"[\n  1,\n  2,\n]", "(program (expr [1 2]))"},
+		{"add(1, 2,)", "(program (expr (call add 1 2)))"},
+		{"{\"a\": 1,}", "(program (expr (hash (pair \"a\" 1))))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program, diagnostics := parser.Parse(tt.input)
+			if len(diagnostics) != 0 {
+				t.Fatalf("unexpected diagnostics: %v", diagnostics)
+			}
+
+			if program.String() != tt.expected {
+				t.Errorf("wrong program. got = %q, want = %q", program.String(), tt.expected)
+			}
+		})
+	}
+
+	// A lone comma is no list.
+	if _, diagnostics := parser.Parse("[,]"); len(diagnostics) == 0 {
+		t.Errorf("expected [,] not to parse")
+	}
+}
+
 func TestParsingIndexExpression(t *testing.T) {
 	input := "array[1 + 1]"
 	p := parser.New(lexer.New(input))
@@ -926,3 +990,279 @@ func TestMacroLiteralParsing(t *testing.T) {
 
 	testInfixExpression(t, exprStatement.Expression, "x", "+", "y")
 }
+
+func TestParserErrorsHaveLocations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 5;", "script.monkey:1:5: expected next token to be IDENT, got ASSIGN instead"},
+		{"let x = 5;\nlet y 10;", "script.monkey:2:7: expected next token to be ASSIGN, got INT instead"},
+		{"add(1,\n    2;", "script.monkey:2:6: expected next token to be RPAREN, got SEMICOLON instead"},
+		{"\n  }", "script.monkey:2:3: no prefix parse function for RBRACE found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := parser.New(lexer.NewWithFilename("script.monkey", tt.input))
+			p.ParseProgram()
+
+			errors := p.Errors()
+			if len(errors) == 0 {
+				t.Fatalf("expected parser errors, got none")
+			}
+
+			if errors[0] != tt.expected {
+				t.Errorf("wrong error. got = %q, want = %q", errors[0], tt.expected)
+			}
+		})
+	}
+}
+
+func TestParserDiagnosticCodes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected diagnostic.Code
+	}{
+		{"let = 5;", parser.CodeUnexpectedToken},
+		{"}", parser.CodeExpectedExpression},
+		{"99999999999999999999;", parser.CodeInvalidInteger},
+		{"fn(1) { 1 };", parser.CodeInvalidParameter},
+		{"let x = @;", parser.CodeIllegalCharacter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, diagnostics := parser.Parse(tt.input)
+			if len(diagnostics) == 0 {
+				t.Fatalf("expected diagnostics, got none")
+			}
+
+			d := diagnostics[0]
+			if d.Code != tt.expected {
+				t.Errorf("wrong code. got = %q, want = %q (%s)", d.Code, tt.expected, d.Error())
+			}
+
+			if d.Severity != diagnostic.SeverityError {
+				t.Errorf("wrong severity. got = %s, want = %s", d.Severity, diagnostic.SeverityError)
+			}
+		})
+	}
+}
+
+func TestParserRecoversAtStatementBoundaries(t *testing.T) {
+	input := `let x 5;
+let y = 10;
+let = 3;
+fn(x) { let z 1; z };
+let w = y;`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	expectedErrors := []string{
+		"1:7: expected next token to be ASSIGN, got INT instead",
+		"3:5: expected next token to be IDENT, got ASSIGN instead",
+		"4:15: expected next token to be ASSIGN, got INT instead",
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expectedErrors) {
+		t.Fatalf("wrong number of errors. got = %d (%q), want = %d", len(errors), errors, len(expectedErrors))
+	}
+
+	for i, expected := range expectedErrors {
+		if errors[i] != expected {
+			t.Errorf("wrong error at %d. got = %q, want = %q", i, errors[i], expected)
+		}
+	}
+
+	expectedStatements := []string{"(let y 10)", "(expr (func [x] (block (expr z))))", "(let w y)"}
+	if len(program.Statements) != len(expectedStatements) {
+		t.Fatalf("wrong number of statements. got = %d (%q), want = %d",
+			len(program.Statements), program.String(), len(expectedStatements))
+	}
+
+	for i, expected := range expectedStatements {
+		if got := program.Statements[i].String(); got != expected {
+			t.Errorf("wrong statement at %d. got = %q, want = %q", i, got, expected)
+		}
+	}
+}
+
+func TestParsingWithComments(t *testing.T) {
+	input := `// computes the answer
+let answer = /* deep thought */ 42; // done
+answer;`
+
+	program, diagnostics := parser.Parse(input)
+	if len(diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics)
+	}
+
+	expected := "(program (let answer 42) (expr answer))"
+	if program.String() != expected {
+		t.Errorf("wrong program. got = %q, want = %q", program.String(), expected)
+	}
+}
+
+func TestUnterminatedBlockCommentIsReported(t *testing.T) {
+	_, diagnostics := parser.Parse("let x = 1; /* oops")
+	if len(diagnostics) != 1 || diagnostics[0].Code != lexer.CodeUnterminatedBlockComment {
+		t.Fatalf("expected an unterminated block comment diagnostic, got %v", diagnostics)
+	}
+}
+
+func TestLoopParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (x < 10) { x; }", "(program (while (infix x < 10) (block (expr x))))"},
+		{"while (true) { break; continue }", "(program (while true (block (break)(continue))))"},
+		{"for (x in [1, 2]) { x; };", "(program (for x [1 2] (block (expr x))))"},
+		{"for (c in \"ab\") { while (c) { break; } }", "(program (for c \"ab\" (block (while c (block (break))))))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program, diagnostics := parser.Parse(tt.input)
+			if len(diagnostics) != 0 {
+				t.Fatalf("unexpected diagnostics: %v", diagnostics)
+			}
+
+			if program.String() != tt.expected {
+				t.Errorf("wrong program. got = %q, want = %q", program.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: `break` outside of a loop"},
+		{"if (true) { continue; }", "1:13: `continue` outside of a loop"},
+		{"while (true) { fn() { break; } }", "1:23: `break` outside of a loop"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, diagnostics := parser.Parse(tt.input)
+			if len(diagnostics) != 1 {
+				t.Fatalf("expected a single diagnostic, got %v", diagnostics)
+			}
+
+			if diagnostics[0].Code != parser.CodeLoopControlOutsideLoop {
+				t.Errorf("wrong code. got = %q", diagnostics[0].Code)
+			}
+
+			if diagnostics[0].Error() != tt.expected {
+				t.Errorf("wrong error. got = %q, want = %q", diagnostics[0].Error(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestAssignmentParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5;", "(program (assign x = 5))"},
+		{"x += y * 2", "(program (assign x += (infix y * 2)))"},
+		{"arr[0] -= 1;", "(program (assign (index arr 0) -= 1))"},
+		{`h["a"] = [1, 2]`, `(program (assign (index h "a") = [1 2]))`},
+		{"while (x) { x /= 2; }", "(program (while x (block (assign x /= 2))))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program, diagnostics := parser.Parse(tt.input)
+			if len(diagnostics) != 0 {
+				t.Fatalf("unexpected diagnostics: %v", diagnostics)
+			}
+
+			if program.String() != tt.expected {
+				t.Errorf("wrong program. got = %q, want = %q", program.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2;", "1:1: cannot assign to 1"},
+		{`"s" += 1;`, `1:1: cannot assign to "s"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, diagnostics := parser.Parse(tt.input)
+			if len(diagnostics) != 1 {
+				t.Fatalf("expected a single diagnostic, got %v", diagnostics)
+			}
+
+			if diagnostics[0].Code != parser.CodeInvalidAssignmentTarget {
+				t.Errorf("wrong code. got = %q", diagnostics[0].Code)
+			}
+
+			if diagnostics[0].Error() != tt.expected {
+				t.Errorf("wrong error. got = %q, want = %q", diagnostics[0].Error(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestImportParsing(t *testing.T) {
+	program, diagnostics := parser.Parse(`let m = import("lib/math.monkey");`)
+	if len(diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics)
+	}
+
+	let, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.LetStatement. got = %T", program.Statements[0])
+	}
+
+	imp, ok := let.Value.(*ast.ImportExpression)
+	if !ok {
+		t.Fatalf("value is not *ast.ImportExpression. got = %T", let.Value)
+	}
+
+	if imp.Path() != "lib/math.monkey" {
+		t.Errorf("wrong path. got = %q", imp.Path())
+	}
+
+	if program.String() != `(program (let m (import "lib/math.monkey")))` {
+		t.Errorf("wrong string. got = %q", program.String())
+	}
+}
+
+func TestImportPathMustBeStringLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import(path)`, "1:8: the path of an import must be a string literal"},
+		{`import("a" + "b")`, "1:12: expected next token to be RPAREN, got PLUS instead"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, diagnostics := parser.Parse(tt.input)
+			if len(diagnostics) == 0 {
+				t.Fatalf("expected a diagnostic")
+			}
+
+			if diagnostics[0].Error() != tt.expected {
+				t.Errorf("wrong error. got = %q, want = %q", diagnostics[0].Error(), tt.expected)
+			}
+		})
+	}
+}
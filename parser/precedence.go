@@ -32,15 +32,17 @@ var precedences = map[token.TokenType]Precedence{
 }
 
 func (p *Parser) peekPrecedence() Precedence {
-	pr, ok := precedences[p.peekToken.Type]
-	if !ok {
-		return LOWEST
-	}
-	return pr
+	return InfixPrecedence(p.peekToken.Type)
 }
 
 func (p *Parser) curPrecedence() Precedence {
-	pr, ok := precedences[p.curToken.Type]
+	return InfixPrecedence(p.curToken.Type)
+}
+
+// InfixPrecedence returns how tightly the infix operator t binds, LOWEST if t
+// isn't one.
+func InfixPrecedence(t token.TokenType) Precedence {
+	pr, ok := precedences[t]
 	if !ok {
 		return LOWEST
 	}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"io"
 	"monkey/compiler"
+	"monkey/diagnostic"
 	"monkey/evaluator"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
 	"monkey/vm"
@@ -17,8 +19,17 @@ const PROMPT = ">> "
 func StartTree(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 
-	env := object.NewEnvironment()
 	macroEnv := object.NewEnvironment()
+	loader := module.NewLoader(module.SearchPathsFromEnv()...)
+
+	exec := execContext(out)
+	macroEnv.SetExecContext(exec)
+
+	importer := module.NewImporter(loader)
+	importer.SetExecContext(exec)
+	env := object.NewEnvironment()
+	env.SetImporter(importer)
+	env.SetExecContext(exec)
 
 	for {
 		fmt.Fprintf(out, "%s", PROMPT)
@@ -32,13 +43,16 @@ func StartTree(in io.Reader, out io.Writer) {
 		p := parser.New(l)
 
 		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if len(p.Diagnostics()) != 0 {
+			printParserErrors(out, line, p.Diagnostics())
 			continue
 		}
 
-		evaluator.DefineMacros(program, macroEnv)
-		expanded := evaluator.ExpandMacros(program, macroEnv)
+		expanded, err := loader.Expand(program, macroEnv)
+		if err != nil {
+			fmt.Fprintf(out, "Oops! Macro expansion failed:\n\t%s\n", err)
+			continue
+		}
 
 		evaluated := evaluator.Eval(expanded, env)
 		if evaluated != nil {
@@ -51,6 +65,11 @@ func StartCompiled(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 
 	macroEnv := object.NewEnvironment()
+	loader := module.NewLoader(module.SearchPathsFromEnv()...)
+	modules := compiler.NewModules(loader)
+
+	exec := execContext(out)
+	macroEnv.SetExecContext(exec)
 
 	constants := []object.Object{}
 	globals := vm.InitGlobalsArray()
@@ -72,24 +91,41 @@ func StartCompiled(in io.Reader, out io.Writer) {
 		p := parser.New(l)
 
 		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if len(p.Diagnostics()) != 0 {
+			printParserErrors(out, line, p.Diagnostics())
 			continue
 		}
 
-		evaluator.DefineMacros(program, macroEnv)
-		expanded := evaluator.ExpandMacros(program, macroEnv)
+		expanded, err := loader.Expand(program, macroEnv)
+		if err != nil {
+			fmt.Fprintf(out, "Oops! Macro expansion failed:\n\t%s\n", err)
+			continue
+		}
+
+		// Failed lines leave none of the globals they didn't set defined.
+		symbols := symbolTable.Copy()
 
 		comp := compiler.NewWithState(symbolTable, constants)
+		comp.SetModules(modules)
 
 		if err := comp.Compile(expanded); err != nil {
 			fmt.Fprintf(out, "Oops! Compilation failed:\n\t%s\n", err)
+			symbolTable = symbols
 			continue
 		}
 
-		machine := vm.NewWithGlobalState(comp.Bytecode(), globals)
+		// Keeping the constants around, compiled modules refer to theirs by index.
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := vm.NewWithGlobalState(bytecode, globals)
+		machine.SetExecContext(exec)
+		machine.SetGlobalNames(comp.GlobalNames())
 		if err := machine.Run(); err != nil {
 			fmt.Fprintf(out, "Executing bytecode failed:\n\t%s\n", err)
+			symbolTable.Rollback(symbols, func(symbol compiler.Symbol) bool {
+				return globals[symbol.Index] != nil
+			})
 			continue
 		}
 
@@ -98,10 +134,16 @@ func StartCompiled(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+// execContext returns the context of the programs run by the REPL, printing
+// to out. Reading stdin is denied, the REPL reading its own input from there.
+func execContext(out io.Writer) *object.ExecContext {
+	exec := object.DefaultExecContext()
+	exec.Stdout = out
+	exec.Stdin = nil
+	return exec
+}
+
+func printParserErrors(out io.Writer, source string, diagnostics []diagnostic.Diagnostic) {
 	fmt.Fprintf(out, "%s", "Oops! We ran into some monkey business here!\n")
-	fmt.Fprintf(out, "%s", "parser errors:\n")
-	for _, msg := range errors {
-		fmt.Fprintf(out, "%s", "\t"+msg+"\n")
-	}
+	diagnostic.FprintAll(out, source, diagnostics)
 }
@@ -0,0 +1,49 @@
+package token
+
+import "fmt"
+
+// Position is a single point in a source file.
+type Position struct {
+	Offset int `json:"offset"` // byte offset into the input, starting at 0
+	Line   int `json:"line"`   // line number, starting at 1
+	Column int `json:"column"` // column number in characters, starting at 1
+}
+
+// IsValid reports whether the position was actually set by the lexer.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span is the region of a source file a token (or an AST node) was read from.
+//
+// End points right after the last character of the region, so an empty span
+// has Start == End.
+type Span struct {
+	File  string   `json:"file"`
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// IsValid reports whether the span points at an actual location in the source.
+//
+// Nodes that are synthesized rather than parsed (e.g. the result of unquoting
+// inside a macro) have no span.
+func (s Span) IsValid() bool {
+	return s.Start.IsValid()
+}
+
+// String formats the span as "file:line:col", which is what most editors and
+// terminals recognize as a clickable location. The file is omitted if unknown.
+func (s Span) String() string {
+	if !s.IsValid() {
+		return "<unknown>"
+	}
+	if s.File == "" {
+		return s.Start.String()
+	}
+	return fmt.Sprintf("%s:%s", s.File, s.Start)
+}
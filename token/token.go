@@ -1,14 +1,17 @@
 package token
 
+import "sort"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Span    Span // where in the source the token was read from
 }
 
 func New(t TokenType, literal string) Token {
-	return Token{t, literal}
+	return Token{Type: t, Literal: literal} //nolint:exhaustruct
 }
 
 func (t *Token) Eq(o *Token) bool {
@@ -18,10 +21,12 @@ func (t *Token) Eq(o *Token) bool {
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // only ever found in the lexer's trivia channel
 
 	// Identifiers + literals
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
 	// Operators
@@ -38,6 +43,12 @@ const (
 	EQ      = "EQ"
 	NOT_EQ  = "NOT_EQ"
 
+	// Compound assignment operators
+	PLUS_ASSIGN    = "PLUS_ASSIGN"
+	MINUS_ASSIGN   = "MINUS_ASSIGN"
+	ASTERIX_ASSIGN = "ASTERIX_ASSIGN"
+	SLASH_ASSIGN   = "SLASH_ASSIGN"
+
 	// Delimiters
 	COMMA     = "COMMA"
 	SEMICOLON = "SEMICOLON"
@@ -59,17 +70,39 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	IMPORT   = "IMPORT"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"macro":  MACRO,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"macro":    MACRO,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"import":   IMPORT,
+}
+
+// Keywords returns every keyword of the language, sorted.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 func LookupIdent(rawString string) TokenType {
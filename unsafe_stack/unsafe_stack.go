@@ -36,6 +36,11 @@ func (u *UnsafeSizedStack[T]) Push(item T) {
 	u.topIndex++
 }
 
+// At returns the item at index, counting from the bottom of the stack.
+func (u *UnsafeSizedStack[T]) At(index int) T {
+	return u.items[index]
+}
+
 func (u *UnsafeSizedStack[T]) Size() int {
 	// doing the `+1` because an empty stack means that the reading position
 	// sits at `topIndex == -1`. See the initialization at the `Make` func.
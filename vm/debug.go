@@ -0,0 +1,110 @@
+package vm
+
+import (
+	"monkey/object"
+	"strings"
+)
+
+// Hook is called before each instruction runs, once set with SetHook. It may
+// inspect the VM, and block for as long as execution should be paused.
+// Returning an error stops the VM, Run returning that error as is.
+type Hook func(vm *VM) error
+
+// SetHook sets the hook called before each instruction, nil removes it.
+func (vm *VM) SetHook(hook Hook) {
+	vm.hook = hook
+}
+
+// Depth returns the number of calls in progress, 1 when running the top level
+// of the program.
+func (vm *VM) Depth() int {
+	return vm.frameStack.Size()
+}
+
+// StackTrace returns the calls in progress, the most recent one first.
+func (vm *VM) StackTrace() []TraceFrame {
+	return vm.stackTrace()
+}
+
+// Location returns where the innermost call is at, as the first frame of
+// StackTrace would but without walking the whole stack. Called from a Hook, it
+// is the instruction about to run.
+func (vm *VM) Location() TraceFrame {
+	return vm.traceFrame(vm.frameStack.Size()-1, vm.frameStack.Current().ip)
+}
+
+// Variable is a variable of the running program, as shown by debuggers.
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Locals returns the local variables of the call at depth, 0 being the most
+// recent one as in StackTrace. Variables that are yet to be defined are left
+// out.
+func (vm *VM) Locals(depth int) []Variable {
+	frame, ok := vm.frameAt(depth)
+	if !ok {
+		return nil
+	}
+
+	locals := []Variable{}
+	for i, name := range frame.cl.Fn.LocalNames {
+		if name == "" {
+			continue
+		}
+		if value := deref(vm.stack[frame.basePointer+i]); value != nil {
+			locals = append(locals, Variable{name, value})
+		}
+	}
+	return locals
+}
+
+// Free returns the free variables captured by the closure of the call at
+// depth.
+func (vm *VM) Free(depth int) []Variable {
+	frame, ok := vm.frameAt(depth)
+	if !ok {
+		return nil
+	}
+
+	free := []Variable{}
+	for i, name := range frame.cl.Fn.FreeNames {
+		if i < len(frame.cl.Free) {
+			free = append(free, Variable{name, deref(frame.cl.Free[i])})
+		}
+	}
+	return free
+}
+
+// Globals returns the globals of the main program that are set, names being
+// the names of the global slots as given by compiler.Compiler.GlobalNames.
+// The globals of imported modules are not included.
+func (vm *VM) Globals(names []string) []Variable {
+	globals := []Variable{}
+	for i, name := range names {
+		// Slots named with a "$" are internal, e.g. those caching modules.
+		if name == "" || strings.HasPrefix(name, "$") || i >= len(vm.globals) {
+			continue
+		}
+		if value := vm.globals[i]; value != nil {
+			globals = append(globals, Variable{name, value})
+		}
+	}
+	return globals
+}
+
+func (vm *VM) frameAt(depth int) (*Frame, bool) {
+	index := vm.frameStack.Size() - 1 - depth
+	if depth < 0 || index < 0 {
+		return nil, false
+	}
+	return vm.frameStack.At(index), true
+}
+
+func deref(value object.Object) object.Object {
+	if cell, ok := value.(*object.Cell); ok {
+		return cell.Value
+	}
+	return value
+}
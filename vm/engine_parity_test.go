@@ -0,0 +1,162 @@
+package vm_test
+
+import (
+	"errors"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"testing"
+)
+
+// TestEngineParity runs the same programs on both the tree-walking evaluator
+// and the vm, and checks that they agree on the result.
+func TestEngineParity(t *testing.T) {
+	inputs := []string{
+		"1 < 2",
+		"2 < 1",
+		"1 <= 1",
+		"2 <= 1",
+		"1 <= 2",
+		"1 > 2",
+		"2 > 1",
+		"1 >= 1",
+		"1 >= 2",
+		"2 >= 1",
+		"-1 >= -2",
+		"(1 <= 2) == (2 >= 1)",
+		"let max = fn(a, b) { if (a >= b) { a } else { b } }; max(3, 7)",
+		"let min = fn(a, b) { if (a <= b) { a } else { b } }; min(3, 7)",
+		`"mon" + "key"`,
+		`"héllo"[1]`,
+		`len("a😀b")`,
+		"[1, 2 * 2, 3 + 3][1]",
+		"fn() { for (x in range(5)) { if (x >= 3) { return x; } } }()",
+		"fn() { for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } if (x == 4) { break; } let seen = x; }; seen }()",
+		`fn() { for (pair in {"one": 1, "two": 2}) { let last = pair; }; last }()`,
+		`fn() { for (c in "ab😀") { let last = c; }; last }()`,
+		"fn() { while (true) { if (true) { break; } }; range(1, 10, 2) }()",
+		"len(range(-5, 5, 2))",
+		"let x = 3; x *= 4; x -= 2; x",
+		"let i = 0; let total = 0; while (i < 10) { i += 1; total += i; }; total",
+		"let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c()",
+		`let h = {"k": [1, 2]}; h["k"][1] += 40; h["k"]`,
+		"let x = 1; let f = fn() { x = x + 1; }; f(); f(); x",
+		"1.5 + 2",
+		"10 / 4.0",
+		"2.0 * 3",
+		"-1e3 < 0.5",
+		"1 == 1.0",
+		"[floor(1.5), ceil(1.5), round(1.5), round(3.14159, 3), float(2), int(2.9)]",
+		`[split("a,b", ","), join(["a", 1], "-"), trim(" a "), upper("é"), lower("A"), contains("ab", "b")]`,
+		`[index_of("héllo", "l"), replace("aa", "a", "b", 1), starts_with("ab", "a"), ends_with("ab", "a")]`,
+		`[substr("héllo", 1, -1), repeat("ab", 2), chars("hé"), format("{1}{0}", "a", "b")]`,
+		`[map([1, 2], fn(x) { x * 2 }), filter(range(5), fn(x) { x > 2 }), reduce([1, 2, 3], fn(a, b) { a + b })]`,
+		`[sort(["b", "c", "a"]), sort([1, 3, 2], fn(a, b) { a > b }), reverse("hé"), zip([1, 2], "ab")]`,
+		`[any([1, 2], fn(x) { x > 1 }), all([1, 2], fn(x) { x > 1 }), find({"a": 1}, fn(p) { p[1] == 1 })]`,
+		`[flat_map([1, 2], fn(x) { [x, -x] }), unique("abca"), map([[1, 2]], len)]`,
+		`{"c": 1, "a": 2, 1: [3], true: {"b": 4, "a": 5}}`,
+		`[keys({"b": 1, "a": 2}), values(merge({"b": 1}, {"a": 2, "b": 3})), items(delete({"x": 1, "y": 2}, "x"))]`,
+		`let h = {"b": 1}; h["a"] = 2; let out = []; for (p in h) { out = push(out, p[0]) }; [out, has(h, "a"), len(h)]`,
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			tree := runTree(t, input)
+			compiled := runVm(t, input)
+
+			if tree.Inspect() != compiled.Inspect() {
+				t.Errorf("engines disagree. tree = %s, vm = %s", tree.Inspect(), compiled.Inspect())
+			}
+		})
+	}
+}
+
+// TestEngineErrorParity runs failing programs on both engines, and checks
+// that they fail with the same message.
+func TestEngineErrorParity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{`{"a": 1}[null]`, "unusable as hash key: NULL"},
+		{`let h = {}; h[[1]] = 2`, "unusable as hash key: ARRAY"},
+		{`has({}, [1])`, "unusable as hash key: ARRAY"},
+		{`unique([[1]])`, "`unique`: unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tree, ok := runTree(t, tt.input).(*object.Error)
+			if !ok || tree.Message != tt.expected {
+				t.Errorf("wrong tree error. got = %v, want = %q", tree, tt.expected)
+			}
+			if compiled := runVmError(t, tt.input); compiled != tt.expected {
+				t.Errorf("wrong vm error. got = %q, want = %q", compiled, tt.expected)
+			}
+		})
+	}
+}
+
+func parseForParity(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}
+
+func runTree(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	return evaluator.Eval(parseForParity(t, input), object.NewEnvironment())
+}
+
+func runVm(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	comp := compiler.New()
+	if err := comp.Compile(parseForParity(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+// runVmError runs input, which must fail, and returns the message of the
+// error it failed with or of the ERROR it resulted in.
+func runVmError(t *testing.T, input string) string {
+	t.Helper()
+
+	comp := compiler.New()
+	if err := comp.Compile(parseForParity(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		var runErr *vm.VmRunError
+		if errors.As(err, &runErr) {
+			return runErr.Err.Error()
+		}
+		return err.Error()
+	}
+	if result, ok := machine.LastPoppedStackElem().(*object.Error); ok {
+		return result.Message
+	}
+	t.Fatalf("expected an error, got = %s", machine.LastPoppedStackElem().Inspect())
+	return ""
+}
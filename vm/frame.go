@@ -9,11 +9,20 @@ type Frame struct {
 	cl          *object.Closure
 	ip          int
 	basePointer int
+
+	// globals are the globals of the module the closure belongs to.
+	globals []object.Object
+	// moduleSlot is, for the body of a module, the global slot its exports
+	// are kept in once it returns. It's -1 for any other function.
+	moduleSlot int
 }
 
-func NewFrame(cl *object.Closure, basePointer int) *Frame {
+func NewFrame(cl *object.Closure, basePointer int, globals []object.Object) *Frame {
 	ip := -1
-	return &Frame{cl, ip, basePointer}
+	if cl.Globals != nil {
+		globals = cl.Globals
+	}
+	return &Frame{cl, ip, basePointer, globals, -1}
 }
 
 func (f *Frame) Instructions() code.Instructions {
@@ -5,6 +5,7 @@
 package vmtest
 
 import (
+	"errors"
 	"fmt"
 	"monkey/ast"
 	"monkey/compiler"
@@ -39,6 +40,19 @@ func testIntegerObject(expected int64, actual object.Object) error {
 	return nil
 }
 
+func testFloatObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.Float)
+	if !ok {
+		return fmt.Errorf("object is not *object.Float. got = %T (%+V)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("result has wrong value. got = %g, want = %g", result.Value, expected)
+	}
+
+	return nil
+}
+
 func testBooleanObject(expected bool, actual object.Object) error {
 	result, ok := actual.(*object.Boolean)
 	if !ok {
@@ -65,23 +79,10 @@ func testStringObject(expected string, actual object.Object) error {
 	return nil
 }
 
+// UserErr is the message of the error a program is expected to fail with, as
+// a builtin raises it.
 type UserErr string
 
-func testUserErrMessage(expectedUserErr UserErr, actual object.Object) error {
-	expected := string(expectedUserErr)
-
-	result, ok := actual.(*object.Error)
-	if !ok {
-		return fmt.Errorf("object is not *object.Error. got = %T (%+V)", actual, actual)
-	}
-
-	if result.Message != expected {
-		return fmt.Errorf("result.Message has wrong value. got = %q, want = %q", result.Message, expected)
-	}
-
-	return nil
-}
-
 func testNilObject(actual object.Object) error {
 	_, ok := actual.(*object.Null)
 	if ok {
@@ -105,6 +106,11 @@ func testExpectedObject(t *testing.T, expected any, actual object.Object) {
 			t.Fatalf("testIntegerObject failed: %s", err)
 		}
 
+	case float64:
+		if err := testFloatObject(expected, actual); err != nil {
+			t.Fatalf("testFloatObject failed: %s", err)
+		}
+
 	case bool:
 		if err := testBooleanObject(expected, actual); err != nil {
 			t.Fatalf("testBooleanObject failed: %s", err)
@@ -120,11 +126,6 @@ func testExpectedObject(t *testing.T, expected any, actual object.Object) {
 			t.Fatalf("testNilObject failed: %s", err)
 		}
 
-	case UserErr:
-		if err := testUserErrMessage(expected, actual); err != nil {
-			t.Fatalf("testUserErrMessage failed: %s", err)
-		}
-
 	case []int:
 		array, ok := actual.(*object.Array)
 		if !ok {
@@ -233,6 +234,13 @@ func RunVmTests(t *testing.T, tests []VmTestCase) {
 
 			vm := vm.New(comp.Bytecode())
 			err = vm.Run()
+			if expected, ok := tt.expected.(UserErr); ok {
+				if err == nil {
+					t.Fatalf("expected an error but got a result instead: %v", vm.LastPoppedStackElem().Inspect())
+				}
+				ensureErrMessageAsExpected(t, errors.Unwrap(err), string(expected))
+				return
+			}
 			if err != nil {
 				t.Fatalf("vm error: %s", err)
 			}
@@ -291,6 +299,45 @@ func RunVmTestsResultInError(t *testing.T, tests []VmErrorTestCase) {
 	}
 }
 
+type VmOutputTestCase struct {
+	Input          string
+	ExpectedOutput string
+}
+
+// RunVmOutputTests checks what the programs print to stdout.
+func RunVmOutputTests(t *testing.T, tests []VmOutputTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		t.Run(tt.Input, func(t *testing.T) {
+			program, err := parse(tt.Input)
+			if err != nil {
+				t.Fatalf("failed parsing: %s", err)
+			}
+
+			comp := compiler.New()
+			err = comp.Compile(program)
+			if err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+
+			var out strings.Builder
+			exec := object.DefaultExecContext()
+			exec.Stdout = &out
+
+			v := vm.New(comp.Bytecode())
+			v.SetExecContext(exec)
+			if err := v.Run(); err != nil {
+				t.Fatalf("vm error: %s", err)
+			}
+
+			if out.String() != tt.ExpectedOutput {
+				t.Errorf("wrong output. got = %q, want = %q", out.String(), tt.ExpectedOutput)
+			}
+		})
+	}
+}
+
 func ensureErrMessageAsExpected(t *testing.T, got error, expected string) {
 	t.Helper()
 
@@ -0,0 +1,82 @@
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/token"
+)
+
+// TraceFrame is one of the calls that were in progress when a runtime error
+// happened.
+type TraceFrame struct {
+	// Function is the name of the function being run. It's "<main>" for the
+	// top level of the program, "<module>" for that of an imported module and
+	// "<anonymous>" for functions that were never bound to a name.
+	Function string
+
+	// Offset is the offset of the instruction being run, in the instructions
+	// of the function. For every frame but the innermost one, that's the call
+	// to the next frame.
+	Offset int
+
+	// Span is the location of the source the instruction was compiled from.
+	// Only valid if the bytecode was compiled with a source map.
+	Span token.Span
+}
+
+func (f TraceFrame) String() string {
+	return fmt.Sprintf("at %s (%s) [%04d]", f.Function, f.Span, f.Offset)
+}
+
+// stackTrace returns the calls in progress, the most recent one first.
+func (vm *VM) stackTrace() []TraceFrame {
+	trace := make([]TraceFrame, 0, vm.frameStack.Size())
+
+	for i := vm.frameStack.Size() - 1; i >= 0; i-- {
+		frame := vm.frameStack.At(i)
+		trace = append(trace, vm.traceFrame(i, instructionAt(frame.Instructions(), frame.ip)))
+	}
+
+	return trace
+}
+
+// traceFrame describes the frame at index in the frame stack, running the
+// instruction at offset.
+func (vm *VM) traceFrame(index int, offset int) TraceFrame {
+	frame := vm.frameStack.At(index)
+
+	function := frame.cl.Fn.Name
+	switch {
+	case index == 0:
+		function = "<main>"
+	case frame.moduleSlot >= 0:
+		function = "<module>"
+	case function == "":
+		function = "<anonymous>"
+	}
+
+	span, _ := frame.cl.Fn.SourceMap.Lookup(offset)
+	return TraceFrame{Function: function, Offset: offset, Span: span}
+}
+
+// instructionAt returns the offset of the instruction ip is in. The ip of a
+// frame points at the last operand read, not at the opcode.
+func instructionAt(ins code.Instructions, ip int) int {
+	offset := 0
+	for offset < len(ins) {
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			break
+		}
+
+		width := 1
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if ip < offset+width {
+			return offset
+		}
+		offset += width
+	}
+	return ip
+}
@@ -1,6 +1,8 @@
 package vm
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"monkey/code"
 	"monkey/compiler"
@@ -43,6 +45,27 @@ type VM struct {
 	globals []object.Object
 
 	frameStack unsafestack.UnsafeSizedStack[*Frame]
+
+	// hook is called before each instruction, see SetHook.
+	hook Hook
+
+	// builtins are those the bytecode refers to, the standard ones if nil.
+	builtins *object.BuiltinRegistry
+
+	// limits bound the run, tracked by budget, see SetLimits.
+	limits object.Limits
+	budget *object.Budget
+
+	// exec is what builtins reach outside of the program through, calling
+	// the functions they're given back through the vm, see SetExecContext.
+	exec *object.ExecContext
+	// callbackErr is the error of a function called back by a builtin, which
+	// fails the call to the builtin once it returns.
+	callbackErr error
+
+	// globalNames name the globals of the program, for errors, see
+	// SetGlobalNames.
+	globalNames []string
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
@@ -57,19 +80,66 @@ func NewWithGlobalState(bytecode *compiler.Bytecode, globals []object.Object) *V
 
 	framesStack := unsafestack.Make[*Frame](MaxFrames)
 
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFn := &object.CompiledFunction{ //nolint:exhaustruct
+		Instructions: bytecode.Instructions,
+		SourceMap:    bytecode.SourceMap,
+	}
 	mainClosure := &object.Closure{Fn: mainFn}
-	mainFrame := NewFrame(mainClosure, 0)
+	mainFrame := NewFrame(mainClosure, 0, globals)
 
 	framesStack.Push(mainFrame)
 
-	return &VM{
+	vm := &VM{
 		bytecode.Constants,
 		InitStackArray(),
 		sp,
 		globals,
 		framesStack,
+		nil,
+		nil,
+		object.Limits{}, //nolint:exhaustruct
+		nil,
+		nil,
+		nil,
+		nil,
 	}
+	vm.SetExecContext(nil)
+	return vm
+}
+
+// SetExecContext sets what builtins reach outside of the program through, the
+// process itself if nil.
+func (vm *VM) SetExecContext(exec *object.ExecContext) {
+	vm.exec = exec.WithCaller(caller{vm})
+}
+
+// SetGlobalNames sets the names of the globals of the program, as given by
+// compiler.Compiler.GlobalNames, for the errors of globals read before they're
+// set to name them.
+func (vm *VM) SetGlobalNames(names []string) {
+	vm.globalNames = names
+}
+
+// SetLimits sets the limits of the runs to come. A run exceeding one of them
+// fails with an *object.LimitExceededError.
+func (vm *VM) SetLimits(limits object.Limits) {
+	vm.limits = limits
+}
+
+// SetBuiltins sets the builtins the bytecode was compiled with, if they're not
+// the standard ones.
+func (vm *VM) SetBuiltins(builtins *object.BuiltinRegistry) {
+	vm.builtins = builtins
+}
+
+func (vm *VM) builtin(index int) (*object.Builtin, bool) {
+	if vm.builtins != nil {
+		return vm.builtins.At(index)
+	}
+	if index >= len(object.Builtins) {
+		return nil, false
+	}
+	return object.Builtins[index].Builtin, true
 }
 
 // StackTop returns the top object of the stack without popping.
@@ -101,12 +171,131 @@ func (vm *VM) LastPoppedStackElem() object.Object {
 }
 
 func (vm *VM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext runs the program as Run does, within the limits set with
+// SetLimits. It stops once ctx is done, failing with the error of ctx.
+func (vm *VM) RunContext(ctx context.Context) error {
+	// Without a context that can be done nor limits, there's nothing to keep
+	// track of.
+	if ctx.Done() == nil && vm.limits == (object.Limits{}) { //nolint:exhaustruct
+		vm.budget = nil
+	} else {
+		vm.budget = object.NewBudget(ctx, vm.limits)
+	}
+	// Builtins check what they create against the budget before they do.
+	vm.exec = vm.exec.WithBudget(vm.budget)
+
+	return vm.run(0)
+}
+
+// Call calls fn, a closure or a builtin, with args and returns its result. It
+// may be called while the vm runs, by builtins calling back the functions
+// they're given, or once it has run, to call the functions the program
+// defined.
+func (vm *VM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	switch fn := fn.(type) {
+	case *object.Closure:
+		if fn.Fn.NumParameters != len(args) {
+			return nil, fmt.Errorf(
+				"wrong number of arguments: want = %d, got = %d",
+				fn.Fn.NumParameters,
+				len(args),
+			)
+		}
+		if vm.frameStack.Size() >= MaxFrames || vm.sp+1+fn.Fn.NumLocals >= StackSize {
+			return nil, fmt.Errorf("stack overflow")
+		}
+		if err := vm.enter(); err != nil {
+			return nil, err
+		}
+
+		// The call is laid out on the stack as OpCall would find it, and
+		// runs until the frame pushed for it returns.
+		sp, depth := vm.sp, vm.frameStack.Size()
+		vm.stack[vm.sp] = fn
+		vm.sp++
+		copy(vm.stack[vm.sp:], args)
+		vm.sp += len(args)
+
+		frame := NewFrame(fn, vm.sp-len(args), vm.globals)
+		vm.frameStack.Push(frame)
+		vm.sp = frame.basePointer + fn.Fn.NumLocals
+		clear(vm.stack[frame.basePointer+len(args) : vm.sp])
+
+		if err := vm.run(depth); err != nil {
+			for vm.frameStack.Size() > depth {
+				vm.frameStack.Pop()
+				vm.leave()
+			}
+			vm.sp = sp
+			return nil, err
+		}
+		return vm.pop(), nil
+
+	case *object.Builtin:
+		result := fn.Fn(vm.exec, args...)
+		if err := vm.takeCallbackErr(); err != nil {
+			return nil, err
+		}
+		if errObj, ok := result.(*object.Error); ok {
+			return nil, errors.New(errObj.Message)
+		}
+		if result == nil {
+			return constNull, nil
+		}
+		if err := vm.allocate(result); err != nil {
+			return nil, err
+		}
+		return result, nil
+
+	default:
+		return nil, fmt.Errorf("calling a non-function: (%s) %s", fn.Type(), fn.Inspect())
+	}
+}
+
+// caller calls back the functions builtins are given, failing the builtins
+// with the errors of the calls.
+type caller struct {
+	vm *VM
+}
+
+func (c caller) Call(fn object.Object, args ...object.Object) object.Object {
+	result, err := c.vm.Call(fn, args...)
+	if err != nil {
+		c.vm.callbackErr = err
+		return &object.Error{Message: err.Error()} //nolint:exhaustruct
+	}
+	return result
+}
+
+// takeCallbackErr returns the error of a function called back by the builtin
+// that just returned, if any, and forgets it.
+func (vm *VM) takeCallbackErr() error {
+	err := vm.callbackErr
+	vm.callbackErr = nil
+	return err
+}
+
+// run runs instructions until the frame stack gets down to stopDepth frames,
+// or the program ends.
+func (vm *VM) run(stopDepth int) error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
 
 	toErr := func(err error) error {
-		return &VmRunError{err, ins, vm.stack, vm.globals, vm.sp}
+		span, _ := vm.frameStack.Current().cl.Fn.SourceMap.Lookup(ip)
+		return &VmRunError{
+			Err:          err,
+			Span:         span,
+			Trace:        vm.stackTrace(),
+			Instructions: ins,
+			Stack:        vm.stack,
+			Globals:      vm.globals,
+			StackPointer: vm.sp,
+		}
 	}
 
 	for vm.frameStack.Current().ip < len(vm.frameStack.Current().Instructions())-1 {
@@ -118,6 +307,18 @@ func (vm *VM) Run() error {
 		ins = vm.frameStack.Current().Instructions()
 		op = code.Opcode(ins[ip])
 
+		if vm.hook != nil {
+			if err := vm.hook(vm); err != nil {
+				return err
+			}
+		}
+
+		if vm.budget != nil {
+			if err := vm.budget.Step(); err != nil {
+				return toErr(err)
+			}
+		}
+
 		switch op {
 		case code.OpConstant:
 			constIndex := code.ReadUint16(ins[ip+1:])
@@ -165,7 +366,7 @@ func (vm *VM) Run() error {
 				return toErr(err)
 			}
 
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterThanOrEqual:
 			if err := vm.executeComparison(op); err != nil {
 				return toErr(err)
 			}
@@ -183,19 +384,30 @@ func (vm *VM) Run() error {
 		case code.OpSetGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.frameStack.Current().ip += 2
-			vm.globals[globalIndex] = vm.pop()
+			vm.frameStack.Current().globals[globalIndex] = vm.pop()
 
 		case code.OpGetGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.frameStack.Current().ip += 2
-			if err := vm.push(vm.globals[globalIndex]); err != nil {
+
+			// Globals are defined at compile time, but only set once their
+			// definition runs, which it may not have.
+			global := vm.frameStack.Current().globals[globalIndex]
+			if global == nil {
+				return toErr(fmt.Errorf("identifier not found: %s", vm.globalName(int(globalIndex))))
+			}
+			if err := vm.push(global); err != nil {
 				return toErr(err)
 			}
 
 		case code.OpGetBuiltin:
 			builtinIndex := code.ReadUint8(ins[ip+1:])
 			vm.frameStack.Current().ip += 1
-			if err := vm.push(object.Builtins[builtinIndex].Builtin); err != nil {
+			builtin, ok := vm.builtin(int(builtinIndex))
+			if !ok {
+				return toErr(fmt.Errorf("unknown builtin: %d", builtinIndex))
+			}
+			if err := vm.push(builtin); err != nil {
 				return toErr(err)
 			}
 
@@ -204,7 +416,7 @@ func (vm *VM) Run() error {
 			vm.frameStack.Current().ip += 1
 
 			frame := vm.frameStack.Current()
-			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+			setVariable(&vm.stack[frame.basePointer+int(localIndex)], vm.pop())
 
 		case code.OpGetLocal:
 			localIndex := code.ReadUint8(ins[ip+1:])
@@ -212,7 +424,17 @@ func (vm *VM) Run() error {
 
 			currentFrame := vm.frameStack.Current()
 			local := vm.stack[currentFrame.basePointer+int(localIndex)]
-			if err := vm.push(local); err != nil {
+			if err := vm.push(getVariable(local)); err != nil {
+				return toErr(err)
+			}
+
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.frameStack.Current().ip += 1
+
+			currentFrame := vm.frameStack.Current()
+			cell := toCell(&vm.stack[currentFrame.basePointer+int(localIndex)])
+			if err := vm.push(cell); err != nil {
 				return toErr(err)
 			}
 
@@ -220,7 +442,21 @@ func (vm *VM) Run() error {
 			index := code.ReadUint8(ins[ip+1:])
 			vm.frameStack.Current().ip += 1
 			currentClosure := vm.frameStack.Current().cl
-			if err := vm.push(currentClosure.Free[index]); err != nil {
+			if err := vm.push(getVariable(currentClosure.Free[index])); err != nil {
+				return toErr(err)
+			}
+
+		case code.OpSetFree:
+			index := code.ReadUint8(ins[ip+1:])
+			vm.frameStack.Current().ip += 1
+			currentClosure := vm.frameStack.Current().cl
+			setVariable(&currentClosure.Free[index], vm.pop())
+
+		case code.OpGetFreeCell:
+			index := code.ReadUint8(ins[ip+1:])
+			vm.frameStack.Current().ip += 1
+			currentClosure := vm.frameStack.Current().cl
+			if err := vm.push(toCell(&currentClosure.Free[index])); err != nil {
 				return toErr(err)
 			}
 
@@ -237,6 +473,9 @@ func (vm *VM) Run() error {
 			array := vm.buildArray(vm.sp-numElements, vm.sp)
 			vm.sp = vm.sp - numElements
 
+			if err := vm.allocate(array); err != nil {
+				return toErr(err)
+			}
 			if err := vm.push(array); err != nil {
 				return toErr(err)
 			}
@@ -245,27 +484,30 @@ func (vm *VM) Run() error {
 			numElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.frameStack.Current().ip += 2
 
-			hashmap := map[object.HashKey]object.HashPair{}
+			hash := object.NewHash()
 			for index := vm.sp - numElements; index < vm.sp; index += 2 {
 				key := vm.stack[index]
 
 				// postpone user error handling for a moment
 				hashkey, err := key.HashKey()
 				if err != nil {
-					return toErr(fmt.Errorf("type is unusable as a hash key: %s", key.Type()))
+					return toErr(err)
 				}
 
 				value := vm.stack[index+1]
 
-				hashmap[hashkey] = object.HashPair{
+				hash.Set(hashkey, object.HashPair{
 					Key:   key,
 					Value: value,
-				}
+				})
 			}
 
 			vm.sp = vm.sp - numElements
 
-			if err := vm.push(&object.Hash{Pairs: hashmap}); err != nil {
+			if err := vm.allocate(hash); err != nil {
+				return toErr(err)
+			}
+			if err := vm.push(hash); err != nil {
 				return toErr(err)
 			}
 
@@ -283,6 +525,64 @@ func (vm *VM) Run() error {
 				if err := vm.executeArrayIndexOperator(collection, index); err != nil {
 					return toErr(err)
 				}
+
+			case *object.String:
+				if err := vm.executeStringIndexOperator(collection, index); err != nil {
+					return toErr(err)
+				}
+
+			default:
+				return toErr(fmt.Errorf("index operator not supported: %s", collection.Type()))
+			}
+
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			collection := vm.pop()
+
+			if err := executeSetIndex(collection, index, value); err != nil {
+				return toErr(err)
+			}
+			if vm.budget != nil {
+				if err := vm.budget.CheckSize(collection); err != nil {
+					return toErr(err)
+				}
+			}
+
+		case code.OpDup2:
+			if err := vm.push(vm.stack[vm.sp-2]); err != nil {
+				return toErr(err)
+			}
+			if err := vm.push(vm.stack[vm.sp-2]); err != nil {
+				return toErr(err)
+			}
+
+		case code.OpIterator:
+			iterator, err := object.NewIterator(vm.pop())
+			if err != nil {
+				return toErr(err)
+			}
+
+			if err := vm.push(iterator); err != nil {
+				return toErr(err)
+			}
+
+		case code.OpIterNext:
+			iterator, ok := vm.pop().(*object.Iterator)
+			if !ok {
+				return toErr(fmt.Errorf("expected an iterator on the stack"))
+			}
+
+			element, ok := iterator.Next()
+			if !ok {
+				pos := int(code.ReadUint16(ins[ip+1:]))
+				vm.frameStack.Current().ip = pos - 1
+				continue
+			}
+
+			vm.frameStack.Current().ip += 2
+			if err := vm.push(element); err != nil {
+				return toErr(err)
 			}
 
 		case code.OpClosure:
@@ -316,17 +616,53 @@ func (vm *VM) Run() error {
 					))
 				}
 
-				frame := NewFrame(callee, vm.sp-iNumOfArgs)
+				if vm.frameStack.Size() >= MaxFrames || vm.sp-iNumOfArgs+callee.Fn.NumLocals >= StackSize {
+					return toErr(fmt.Errorf("stack overflow"))
+				}
+				if err := vm.enter(); err != nil {
+					return toErr(err)
+				}
+
+				frame := NewFrame(callee, vm.sp-iNumOfArgs, vm.globals)
 				vm.frameStack.Push(frame)
 				vm.sp = frame.basePointer + callee.Fn.NumLocals
 
+				// The slots of the locals may still hold cells left over by
+				// previous calls, which must not be written through.
+				clear(vm.stack[frame.basePointer+iNumOfArgs : vm.sp])
+
 			case *object.Builtin:
 				args := vm.stack[vm.sp-iNumOfArgs : vm.sp]
-				result := callee.Fn(args...)
+				result := callee.Fn(vm.exec, args...)
 				vm.sp = vm.sp - iNumOfArgs - 1
+
+				// A function the builtin called back failed, which fails
+				// the program where it did.
+				if err := vm.takeCallbackErr(); err != nil {
+					var runErr *VmRunError
+					if errors.As(err, &runErr) {
+						return err
+					}
+					return toErr(err)
+				}
+				// The builtin ran out of the budget, which fails the program
+				// even if it's the last thing it does.
+				if vm.budget != nil {
+					if err := vm.budget.Err(); err != nil {
+						return toErr(err)
+					}
+				}
+				// The builtin failed, which fails the program as it does on
+				// the tree engine.
+				if errObj, ok := result.(*object.Error); ok {
+					return toErr(errors.New(errObj.Message))
+				}
 				if result == nil {
 					vm.push(constNull)
 				} else {
+					if err := vm.allocate(result); err != nil {
+						return toErr(err)
+					}
 					vm.push(result)
 				}
 
@@ -338,24 +674,63 @@ func (vm *VM) Run() error {
 				))
 			}
 
+		case code.OpImport:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			slot := code.ReadUint16(ins[ip+3:])
+			vm.frameStack.Current().ip += 4
+
+			if exports := vm.globals[slot]; exports != nil {
+				if err := vm.push(exports); err != nil {
+					return toErr(err)
+				}
+				continue
+			}
+
+			if err := vm.runModule(int(constIndex), int(slot)); err != nil {
+				return toErr(err)
+			}
+
 		case code.OpReturnValue:
 			returnValue := vm.pop()
 
+			// Returning from the top level ends the program, with the
+			// returned value left as the last popped element.
+			if vm.frameStack.Size() == 1 {
+				return nil
+			}
+
 			// two pops - one for the function frame, and one for the CALL that
 			// put us into the function to begin with.
 			frame := vm.frameStack.Pop()
 			vm.sp = frame.basePointer - 1
+			vm.leave()
+
+			if frame.moduleSlot >= 0 {
+				vm.globals[frame.moduleSlot] = returnValue
+			}
 
 			if err := vm.push(returnValue); err != nil {
 				return toErr(err)
 			}
+			if vm.frameStack.Size() == stopDepth {
+				return nil
+			}
 
 		case code.OpReturn:
+			if vm.frameStack.Size() == 1 {
+				vm.stack[vm.sp] = constNull
+				return nil
+			}
+
 			frame := vm.frameStack.Pop()
 			vm.sp = frame.basePointer - 1
+			vm.leave()
 			if err := vm.push(constNull); err != nil {
 				return toErr(err)
 			}
+			if vm.frameStack.Size() == stopDepth {
+				return nil
+			}
 
 		default:
 			rawCode := ins[ip]
@@ -389,13 +764,15 @@ func (vm *VM) executeBangOperator() error {
 
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
-	if operand.Type() != object.INTEGER_OBJ {
+
+	switch operand := operand.(type) {
+	case *object.Integer:
+		return vm.push(&object.Integer{Value: -operand.Value})
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
 		return fmt.Errorf("prefix operator '-' not supported for type '%s'", operand.Type())
 	}
-
-	value := operand.(*object.Integer).Value
-
-	return vm.push(&object.Integer{Value: -value})
 }
 
 func (vm *VM) executeComparison(op code.Opcode) error {
@@ -406,6 +783,10 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 		return vm.executeIntegerComparison(op, left, right)
 	}
 
+	if object.IsNumber(left) && object.IsNumber(right) {
+		return vm.executeFloatComparison(op, left, right)
+	}
+
 	switch op {
 	case code.OpEqual:
 		return vm.push(nativeBoolToObjectBool(left == right))
@@ -433,19 +814,99 @@ func (vm *VM) executeHashIndexOperator(hash *object.Hash, index object.Object) e
 	// Hashing and stuff's reserved to the hashmap type.
 	hashKey, err := index.HashKey()
 	if err != nil {
-		return fmt.Errorf("object %s is not hashable: %s", index.Type(), err)
+		return err
 	}
 
-	if err := vm.push(hash.Pairs[hashKey].Value); err != nil {
-		return err
+	pair, ok := hash.Get(hashKey)
+	if !ok {
+		return vm.push(constNull)
 	}
 
-	return nil
+	return vm.push(pair.Value)
 }
 
 func (vm *VM) executeArrayIndexOperator(array *object.Array, index object.Object) error {
-	indexValue := index.(*object.Integer).Value
-	return vm.push(array.Elements[indexValue])
+	indexValue, ok := index.(*object.Integer)
+	if !ok {
+		return fmt.Errorf("array index must be %s, got %s", object.INTEGER_OBJ, index.Type())
+	}
+
+	if indexValue.Value < 0 || indexValue.Value >= int64(len(array.Elements)) {
+		return vm.push(constNull)
+	}
+	return vm.push(array.Elements[indexValue.Value])
+}
+
+func (vm *VM) executeStringIndexOperator(str *object.String, index object.Object) error {
+	indexValue, ok := index.(*object.Integer)
+	if !ok {
+		return fmt.Errorf("string index must be %s, got %s", object.INTEGER_OBJ, index.Type())
+	}
+
+	char, ok := str.CharAt(indexValue.Value)
+	if !ok {
+		return vm.push(constNull)
+	}
+	return vm.push(char)
+}
+
+func executeSetIndex(collection object.Object, index object.Object, value object.Object) error {
+	switch collection := collection.(type) {
+	case *object.Array:
+		indexValue, ok := index.(*object.Integer)
+		if !ok {
+			return fmt.Errorf("array index must be %s, got %s", object.INTEGER_OBJ, index.Type())
+		}
+
+		if indexValue.Value < 0 || indexValue.Value >= int64(len(collection.Elements)) {
+			return fmt.Errorf("index out of range: %d", indexValue.Value)
+		}
+
+		collection.Elements[indexValue.Value] = value
+		return nil
+
+	case *object.Hash:
+		hashKey, err := index.HashKey()
+		if err != nil {
+			return err
+		}
+
+		collection.Set(hashKey, object.HashPair{Key: index, Value: value})
+		return nil
+
+	default:
+		return fmt.Errorf("index assignment not supported: %s", collection.Type())
+	}
+}
+
+// getVariable returns the value of a variable, looking through its cell if
+// it has been captured.
+func getVariable(variable object.Object) object.Object {
+	if cell, ok := variable.(*object.Cell); ok {
+		return cell.Value
+	}
+	return variable
+}
+
+// setVariable assigns to a variable, through its cell if it has been captured.
+func setVariable(variable *object.Object, value object.Object) {
+	if cell, ok := (*variable).(*object.Cell); ok {
+		cell.Value = value
+		return
+	}
+	*variable = value
+}
+
+// toCell returns the cell of a variable, moving the variable into a new cell
+// the first time it's captured.
+func toCell(variable *object.Object) *object.Cell {
+	if cell, ok := (*variable).(*object.Cell); ok {
+		return cell
+	}
+
+	cell := &object.Cell{Value: *variable}
+	*variable = cell
+	return cell
 }
 
 func (vm *VM) executeIntegerComparison(
@@ -462,12 +923,37 @@ func (vm *VM) executeIntegerComparison(
 		return vm.push(nativeBoolToObjectBool(leftValue != rightValue))
 	case code.OpGreaterThan:
 		return vm.push(nativeBoolToObjectBool(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToObjectBool(leftValue >= rightValue))
 	default:
 		panic(fmt.Sprintf("unexpected code.Opcode: %#v", op))
 	}
 	// if complaints about missing return, means that a branch is missing a return clause.
 }
 
+// executeFloatComparison compares two numbers at least one of which is a
+// float, promoting the other one to a float as well.
+func (vm *VM) executeFloatComparison(
+	op code.Opcode,
+	left object.Object, right object.Object,
+) error {
+	leftValue, _ := object.ToFloat(left)
+	rightValue, _ := object.ToFloat(right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToObjectBool(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToObjectBool(leftValue != rightValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToObjectBool(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToObjectBool(leftValue >= rightValue))
+	default:
+		panic(fmt.Sprintf("unexpected code.Opcode: %#v", op))
+	}
+}
+
 func nativeBoolToObjectBool(b bool) object.Object {
 	if b {
 		return constTrue
@@ -484,6 +970,10 @@ func objectBoolToNativeBool(o object.Object) bool {
 		value := o.(*object.Integer)
 		return value.Value != 0
 
+	case object.FLOAT_OBJ:
+		value := o.(*object.Float)
+		return value.Value != 0
+
 	case object.NULL_OBJ:
 		return false
 
@@ -496,11 +986,6 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
-	// @Shacham:
-	// we're explicitly expecting only numbers here, will fail on floats.
-	// on the other hand i'm pretty certain we don't support floats at all
-	// as of now... so yeah.
-
 	leftType := left.Type()
 	rightType := right.Type()
 
@@ -508,6 +993,10 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 		return vm.executeBinaryIntegerOperation(op, left, right)
 	}
 
+	if object.IsNumber(left) && object.IsNumber(right) {
+		return vm.executeBinaryFloatOperation(op, left, right)
+	}
+
 	if leftType == object.STRING_OBJ && rightType == object.STRING_OBJ {
 		return vm.executeBinaryStringOperation(op, left, right)
 	}
@@ -547,6 +1036,9 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left object.Object,
 	case code.OpMul:
 		result = leftValue * rightValue
 	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
 		result = leftValue / rightValue
 	default:
 		return fmt.Errorf("unsupported integer operator: %d", op)
@@ -560,13 +1052,40 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left object.Object,
 	return nil
 }
 
+// executeBinaryFloatOperation carries out an arithmetic operation between two
+// numbers at least one of which is a float, promoting the other one as well.
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left object.Object, right object.Object) error {
+	leftValue, _ := object.ToFloat(left)
+	rightValue, _ := object.ToFloat(right)
+
+	var result float64
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		result = leftValue / rightValue
+	default:
+		return fmt.Errorf("unsupported float operator: %d", op)
+	}
+
+	return vm.push(&object.Float{Value: result})
+}
+
 func (vm *VM) executeBinaryStringOperation(op code.Opcode, left object.Object, right object.Object) error {
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
 
 	switch op {
 	case code.OpAdd:
-		return vm.push(&object.String{Value: fmt.Sprintf("%s%s", leftValue, rightValue)})
+		result := &object.String{Value: fmt.Sprintf("%s%s", leftValue, rightValue)}
+		if err := vm.allocate(result); err != nil {
+			return err
+		}
+		return vm.push(result)
 
 	default:
 		def, err := code.Lookup(byte(op))
@@ -602,10 +1121,75 @@ func (vm *VM) pushClosure(constIndex int, numFree int) error {
 	// codes manually.
 	vm.sp = vm.sp - numFree
 
-	closure := &object.Closure{Fn: function, Free: free}
+	// Closures created by a module keep referring to its globals.
+	closure := &object.Closure{Fn: function, Free: free, Globals: vm.frameStack.Current().cl.Globals}
+	if err := vm.allocate(closure); err != nil {
+		return err
+	}
 	return vm.push(closure)
 }
 
+// runModule starts running the body of a module, in a frame of its own with
+// fresh globals. The body returns the module's exports, which get kept in the
+// global slot so that the module doesn't run again.
+func (vm *VM) runModule(constIndex int, slot int) error {
+	body, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a module: %+v", vm.constants[constIndex])
+	}
+
+	if vm.frameStack.Size() >= MaxFrames {
+		return fmt.Errorf("stack overflow")
+	}
+	if err := vm.enter(); err != nil {
+		return err
+	}
+
+	module := &object.Closure{Fn: body, Globals: make([]object.Object, body.NumGlobals)} //nolint:exhaustruct
+
+	// The module takes the place of the callee of a call, so that returning
+	// from it works just like returning from a function.
+	if err := vm.push(module); err != nil {
+		return err
+	}
+
+	frame := NewFrame(module, vm.sp, nil)
+	frame.moduleSlot = slot
+	vm.frameStack.Push(frame)
+	return nil
+}
+
+// globalName returns the name of the global at index, of the program or of
+// the module running.
+func (vm *VM) globalName(index int) string {
+	if vm.frameStack.Current().cl.Globals == nil && index < len(vm.globalNames) && vm.globalNames[index] != "" {
+		return vm.globalNames[index]
+	}
+	return fmt.Sprintf("global %d", index)
+}
+
+// enter counts a call starting against the limits, leave one returning.
+func (vm *VM) enter() error {
+	if vm.budget == nil {
+		return nil
+	}
+	return vm.budget.Enter()
+}
+
+func (vm *VM) leave() {
+	if vm.budget != nil {
+		vm.budget.Leave()
+	}
+}
+
+// allocate counts obj, just created, against the limits.
+func (vm *VM) allocate(obj object.Object) error {
+	if vm.budget == nil {
+		return nil
+	}
+	return vm.budget.Allocate(obj)
+}
+
 func (vm *VM) pop() object.Object {
 	o := vm.stack[vm.sp-1]
 	vm.sp-- // simply decreasing the pointer, this will allow this location in memory to be overwritten. No need to explicitly "drop" the memory.
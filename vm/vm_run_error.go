@@ -4,11 +4,22 @@ import (
 	"fmt"
 	"monkey/code"
 	"monkey/object"
+	"monkey/token"
 	"strings"
 )
 
 type VmRunError struct {
-	Err          error
+	Err error
+
+	// Span is the location of the source that compiled into the failing
+	// instruction. Only valid if the bytecode was compiled with a source map.
+	Span token.Span
+
+	// Trace are the calls in progress when the error happened, the most
+	// recent one first.
+	Trace []TraceFrame
+
+	// The state of the VM, only shown by Dump.
 	Instructions code.Instructions
 
 	Stack        []object.Object
@@ -16,9 +27,35 @@ type VmRunError struct {
 	StackPointer int // "stack pointer". Always points to the next value. Top of stack is stack[sp-1]
 }
 
+// Error describes the error along with the stack trace of the calls that led
+// to it.
 func (e *VmRunError) Error() string {
-	lines := []string{}
-	lines = append(lines, fmt.Sprintf("Got runtime error: %s", e.Err))
+	lines := []string{e.header()}
+	if len(e.Trace) > 0 {
+		lines = append(lines, "Stack trace (most recent call first):")
+		for _, frame := range e.Trace {
+			lines = append(lines, "    "+frame.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *VmRunError) Unwrap() error {
+	return e.Err
+}
+
+func (e *VmRunError) header() string {
+	if e.Span.IsValid() {
+		return fmt.Sprintf("Got runtime error at %s: %s", e.Span, e.Err)
+	}
+	return fmt.Sprintf("Got runtime error: %s", e.Err)
+}
+
+// Dump describes the error along with the state of the VM when it happened:
+// the globals that are set, the stack and the instructions of the function
+// that failed. It's meant for debugging monkey itself.
+func (e *VmRunError) Dump() string {
+	lines := []string{e.Error()}
 
 	globalsLines := []string{"Globals:"}
 	for idx, g := range e.Globals {
@@ -1,9 +1,17 @@
 package vm_test
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"monkey/compiler"
+	"monkey/lexer"
 	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
 	"monkey/vm/internal/vmtest"
 )
 
@@ -44,6 +52,12 @@ func TestBooleanExpressions(t *testing.T) {
 		vmtest.New("1 > 2", false),
 		vmtest.New("1 < 1", false),
 		vmtest.New("1 > 1", false),
+		vmtest.New("1 <= 2", true),
+		vmtest.New("2 <= 1", false),
+		vmtest.New("1 <= 1", true),
+		vmtest.New("1 >= 2", false),
+		vmtest.New("2 >= 1", true),
+		vmtest.New("1 >= 1", true),
 		vmtest.New("1 == 1", true),
 		vmtest.New("1 != 1", false),
 		vmtest.New("1 == 2", false),
@@ -106,6 +120,13 @@ func TestStringExpressions(t *testing.T) {
 		vmtest.New(`"lol"`, "lol"),
 		vmtest.New(`"mon" + "key"`, "monkey"),
 		vmtest.New(`"mon" + "key" + "banana"`, "monkeybanana"),
+		vmtest.New(`"tab\there\n"`, "tab\there\n"),
+		vmtest.New(`"\u{1F600}" + "!"`, "😀!"),
+		vmtest.New("`raw\\n\nstring`", "raw\\n\nstring"),
+		vmtest.New(`"héllo"[1]`, "é"),
+		vmtest.New(`"a😀b"[2]`, "b"),
+		vmtest.New(`"abc"[3]`, nil),
+		vmtest.New(`len("a😀b")`, 3),
 	})
 }
 
@@ -394,18 +415,22 @@ func TestBuiltinFunctions(t *testing.T) {
 		vmtest.New(`len("hello world")`, 11),
 		vmtest.New(`len([1, 2, 3])`, 3),
 		vmtest.New(`len([])`, 0),
-		vmtest.New(`len(1)`, vmtest.UserErr("argument to `len` not supported, got INTEGER")),
+		vmtest.New(`len(1)`, vmtest.UserErr("argument to `len` must be STRING, ARRAY, RANGE or HASH, got INTEGER")),
 		vmtest.New(`len("one", "two")`, vmtest.UserErr("wrong number of arguments. got = 2, want = 1")),
 		vmtest.New(`first([1, 2, 3])`, 1),
 		vmtest.New(`first([])`, nil),
-		vmtest.New(`first(1)`, vmtest.UserErr("argument to `first` must be an ARRAY, got INTEGER")),
+		vmtest.New(`first(1)`, vmtest.UserErr("argument to `first` must be ARRAY, got INTEGER")),
 		vmtest.New(`last([1, 2, 3])`, 3),
 		vmtest.New(`last([])`, nil),
-		vmtest.New(`last(1)`, vmtest.UserErr("argument to `last` must be an ARRAY, got INTEGER")),
+		vmtest.New(`last(1)`, vmtest.UserErr("argument to `last` must be ARRAY, got INTEGER")),
 		vmtest.New(`rest([1, 2, 3])`, []int{2, 3}),
 		vmtest.New(`rest([])`, nil),
 		vmtest.New(`push([], 1)`, []int{1}),
 		vmtest.New(`push(1, 1)`, vmtest.UserErr("first argument to `push` must be ARRAY, got INTEGER")),
+		// A failing builtin fails the program, not just the expression.
+		vmtest.New(`len(1); 5`, vmtest.UserErr("argument to `len` must be STRING, ARRAY, RANGE or HASH, got INTEGER")),
+		vmtest.New(`let f = fn() { len(1); 7 }; f()`, vmtest.UserErr("argument to `len` must be STRING, ARRAY, RANGE or HASH, got INTEGER")),
+		vmtest.New(`upper(5) + 1`, vmtest.UserErr("argument to `upper` must be STRING, got INTEGER")),
 	})
 }
 
@@ -529,3 +554,418 @@ func TestClosures(t *testing.T) {
 		),
 	})
 }
+
+func TestRunErrorLocation(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedLocation string
+	}{
+		{"1 + true", "script.monkey:1:3"},
+		{"let f = fn(a) {\n  -a\n};\nf(\"one\");", "script.monkey:2:3"},
+		{"let x = 1;\nx(2)", "script.monkey:2:2"},
+		{"let a = 4;\na / 0", "script.monkey:2:3"},
+		{"let a = [1];\na[\"x\"]", "script.monkey:2:2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := parser.New(lexer.NewWithFilename("script.monkey", tt.input))
+			program := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("unexpected parser errors: %v", p.Errors())
+			}
+
+			comp := compiler.New()
+			if err := comp.Compile(program); err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+
+			err := vm.New(comp.Bytecode()).Run()
+			vmErr, ok := err.(*vm.VmRunError)
+			if !ok {
+				t.Fatalf("expected a *vm.VmRunError, got = %T (%v)", err, err)
+			}
+
+			if vmErr.Span.String() != tt.expectedLocation {
+				t.Errorf("wrong error location. expected = %q, got = %q", tt.expectedLocation, vmErr.Span.String())
+			}
+		})
+	}
+}
+
+func TestRunErrorStackTrace(t *testing.T) {
+	input := `let divide = fn(a, b) {
+  a / b
+};
+let average = fn(xs) { divide(xs[0] + xs[1], 0) };
+fn() { average([1, 2]) }();`
+
+	p := parser.New(lexer.NewWithFilename("script.monkey", input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parser errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	err := vm.New(comp.Bytecode()).Run()
+	vmErr, ok := err.(*vm.VmRunError)
+	if !ok {
+		t.Fatalf("expected a *vm.VmRunError, got = %T (%v)", err, err)
+	}
+
+	expected := []string{
+		"divide script.monkey:2:5",
+		"average script.monkey:4:30",
+		"<anonymous> script.monkey:5:15",
+		"<main> script.monkey:5:25",
+	}
+
+	if len(vmErr.Trace) != len(expected) {
+		t.Fatalf("wrong number of frames. got = %v", vmErr.Trace)
+	}
+	for i, frame := range vmErr.Trace {
+		if got := frame.Function + " " + frame.Span.String(); got != expected[i] {
+			t.Errorf("frame %d wrong. got = %q, want = %q", i, got, expected[i])
+		}
+	}
+
+	expectedMessage := `Got runtime error at script.monkey:2:5: division by zero
+Stack trace (most recent call first):
+    at divide (script.monkey:2:5) [0004]`
+	if message := vmErr.Error(); !strings.HasPrefix(message, expectedMessage) {
+		t.Errorf("wrong message. got = %q", message)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	vmtest.RunVmTests(t, []vmtest.VmTestCase{
+		vmtest.New(`fn() { for (x in range(10)) { if (x == 3) { return x * 10; } } }()`, 30),
+		vmtest.New(`fn() { for (x in [1, 2, 3, 4]) { if (x == 3) { break; } let seen = x; }; seen }()`, 2),
+		vmtest.New(`fn() { for (x in [1, 2, 3, 4]) { if (x == 4) { continue; } let seen = x; }; seen }()`, 3),
+		vmtest.New(`fn() { for (x in range(10, 0, -3)) { let last = x; }; last }()`, 1),
+		vmtest.New(`fn() { for (c in "hé😀") { let last = c; }; last }()`, "😀"),
+		vmtest.New(`fn() { for (pair in {"b": 2, "a": 1}) { let last = pair; }; last[0] }()`, "a"),
+		vmtest.New(`fn() { for (pair in {"b": 2, "a": 1}) { let last = pair; }; last[1] }()`, 1),
+		vmtest.New(`fn() { while (true) { break; }; 5 }()`, 5),
+		vmtest.New(`fn() { while (false) { return 1; }; 2 }()`, 2),
+		vmtest.New(
+			`fn() {
+				for (i in range(3)) {
+					for (j in range(3)) {
+						if (j == 1) { break; }
+						let inner = j;
+					}
+					let outer = i;
+				}
+				[inner, outer]
+			}()`,
+			[]int{0, 2},
+		),
+		vmtest.New(`let total = 0; for (x in [1, 2]) { let total = x; }; total`, 2),
+		vmtest.New(`len(range(0, 10, 3))`, 4),
+		vmtest.New(`len(range(5, 0))`, 0),
+	})
+
+	vmtest.RunVmTestsResultInError(t, []vmtest.VmErrorTestCase{
+		{
+			Input:         `for (x in 5) { x }`,
+			ExpectedError: "cannot iterate over INTEGER",
+		},
+	})
+}
+
+func TestAssignment(t *testing.T) {
+	vmtest.RunVmTests(t, []vmtest.VmTestCase{
+		vmtest.New(`let x = 1; x = 2; x`, 2),
+		vmtest.New(`let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x`, 6),
+		vmtest.New(`let s = "a"; s += "b"; s`, "ab"),
+		vmtest.New(`let i = 0; while (i < 5) { i += 1; }; i`, 5),
+		vmtest.New(`fn() { let i = 0; let sum = 0; while (i < 4) { i += 1; sum += i; }; sum }()`, 10),
+		vmtest.New(`let x = 1; let f = fn() { x = 2; }; f(); x`, 2),
+		vmtest.New(`let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c(); c()`, 3),
+		vmtest.New(`let counter = fn() { let n = 0; fn() { n += 1; n } }; let a = counter(); let b = counter(); a(); a(); b()`, 1),
+		vmtest.New(
+			`let pair = fn() { let n = 0; [fn() { n += 1 }, fn() { n }] }; let p = pair(); p[0](); p[0](); p[1]()`,
+			2,
+		),
+		vmtest.New(`fn() { let n = 1; let inner = fn() { fn() { n *= 5; } }; inner()(); n }()`, 5),
+		vmtest.New(`let f = fn(x) { let g = fn() { x }; x = x + 1; g() }; f(1) + f(10)`, 13),
+		vmtest.New(`let a = [1, 2, 3]; a[1] = 5; a`, []int{1, 5, 3}),
+		vmtest.New(`let a = [1, 2, 3]; a[2] *= 10; a[2]`, 30),
+		vmtest.New(`let h = {"a": 1}; h["b"] = 2; h["a"] += 1; h["a"] + h["b"]`, 4),
+		vmtest.New(`let a = [1]; let b = push(a, 2); a[0] = 9; b[0]`, 1),
+	})
+
+	vmtest.RunVmTestsResultInError(t, []vmtest.VmErrorTestCase{
+		{
+			Input:         `let a = [1]; a[1] = 2`,
+			ExpectedError: "index out of range: 1",
+		},
+		{
+			Input:         `let a = [1]; a["0"] = 2`,
+			ExpectedError: "array index must be INTEGER, got STRING",
+		},
+		{
+			Input:         `let s = "ab"; s[0] = "c"`,
+			ExpectedError: "index assignment not supported: STRING",
+		},
+	})
+}
+
+func TestFloats(t *testing.T) {
+	vmtest.RunVmTests(t, []vmtest.VmTestCase{
+		vmtest.New(`1.5`, 1.5),
+		vmtest.New(`-2.5e-1`, -0.25),
+		vmtest.New(`1.5 + 1`, 2.5),
+		vmtest.New(`1 - 0.5`, 0.5),
+		vmtest.New(`3 / 2.0`, 1.5),
+		vmtest.New(`3 / 2`, 1),
+		vmtest.New(`1 == 1.0`, true),
+		vmtest.New(`1.5 < 2`, true),
+		vmtest.New(`2.5 >= 3`, false),
+		vmtest.New(`if (0.0) { 1 } else { 2 }`, 2),
+		vmtest.New(`let x = 100; x *= 0.15; x`, 15.0),
+		vmtest.New(`{1: "one"}[1.0]`, "one"),
+		vmtest.New(`floor(2.7) + ceil(2.1)`, 5),
+		vmtest.New(`round(12.3456, 2)`, 12.35),
+		vmtest.New(`int(float("2.5") * 4)`, 10),
+	})
+
+	vmtest.RunVmTestsResultInError(t, []vmtest.VmErrorTestCase{
+		{
+			Input:         `1.5 + "a"`,
+			ExpectedError: "unsupported types for binary (OpAdd) operations: FLOAT STRING",
+		},
+	})
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	vmtest.RunVmTestsResultInError(t, []vmtest.VmErrorTestCase{
+		{
+			Input:         `1 / 0`,
+			ExpectedError: "division by zero",
+		},
+		{
+			Input:         `let f = fn(x) { 10 / x }; f(0)`,
+			ExpectedError: "division by zero",
+		},
+		{
+			Input:         `[1, 2]["a"]`,
+			ExpectedError: "array index must be INTEGER, got STRING",
+		},
+		{
+			Input:         `5[0]`,
+			ExpectedError: "index operator not supported: INTEGER",
+		},
+		{
+			Input:         `let f = fn() { f() }; f()`,
+			ExpectedError: "stack overflow",
+		},
+		{
+			Input:         `let f = fn(n) { f(n + 1) }; f(0)`,
+			ExpectedError: "stack overflow",
+		},
+	})
+
+	vmtest.RunVmTests(t, []vmtest.VmTestCase{
+		vmtest.New(`[1, 2][2]`, nil),
+		vmtest.New(`[1, 2][-1]`, nil),
+		vmtest.New(`return 7; 8`, 7),
+	})
+}
+
+func TestRunContextLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   object.Limits
+		expected object.Limit
+	}{
+		{`while (true) {}`, object.Limits{MaxInstructions: 1000}, object.InstructionLimit},
+		{`let f = fn(n) { f(n + 1) }; f(0)`, object.Limits{MaxDepth: 100}, object.DepthLimit},
+		{`let xs = []; for (i in range(100)) { xs = push(xs, i) }`, object.Limits{MaxAllocations: 50}, object.AllocationLimit},
+		{`let s = "a"; while (true) { s = s + s }`, object.Limits{MaxStringLength: 1000}, object.StringLengthLimit},
+		{`let h = {}; for (i in range(100)) { h[i] = i }`, object.Limits{MaxArrayLength: 10}, object.ArrayLengthLimit},
+		{`push([1, 2, 3], 4)`, object.Limits{MaxArrayLength: 3}, object.ArrayLengthLimit},
+		{`repeat("ab", 1000)`, object.Limits{MaxStringLength: 100}, object.StringLengthLimit},
+		{`chars("abcdef")`, object.Limits{MaxArrayLength: 3}, object.ArrayLengthLimit},
+		{`chars("abcdef")`, object.Limits{MaxAllocations: 3}, object.AllocationLimit},
+		{`split("a,b,c,d", ",")`, object.Limits{MaxArrayLength: 3}, object.ArrayLengthLimit},
+		{`replace("aaaa", "a", "bbbb")`, object.Limits{MaxStringLength: 10}, object.StringLengthLimit},
+		{`join(["abc", "def"], "-")`, object.Limits{MaxStringLength: 5}, object.StringLengthLimit},
+		{`map(range(1000000000), fn(x) { x })`, object.Limits{MaxArrayLength: 100}, object.ArrayLengthLimit},
+		{`sort(range(1000000000))`, object.Limits{MaxArrayLength: 100}, object.ArrayLengthLimit},
+		{`zip(range(100), range(100))`, object.Limits{MaxAllocations: 50}, object.AllocationLimit},
+		{`let h = {}; for (i in range(40)) { h[i] = i }; items(h)`, object.Limits{MaxAllocations: 40}, object.AllocationLimit},
+		{`merge({1: 1, 2: 2}, {2: 2, 3: 3})`, object.Limits{MaxArrayLength: 2}, object.ArrayLengthLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program := parser.New(lexer.New(tt.input)).ParseProgram()
+			comp := compiler.New()
+			if err := comp.Compile(program); err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+
+			machine := vm.New(comp.Bytecode())
+			machine.SetLimits(tt.limits)
+			err := machine.RunContext(context.Background())
+
+			var limitErr *object.LimitExceededError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("expected an *object.LimitExceededError, got = %T (%v)", err, err)
+			}
+			if limitErr.Limit != tt.expected {
+				t.Errorf("wrong limit exceeded. got = %q, want = %q", limitErr.Limit, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRunContextCanceled(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parser.New(lexer.New(`while (true) {}`)).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := vm.New(comp.Bytecode()).RunContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got = %v", err)
+	}
+}
+
+func TestPuts(t *testing.T) {
+	vmtest.RunVmOutputTests(t, []vmtest.VmOutputTestCase{
+		{Input: `puts("hello")`, ExpectedOutput: "hello\n"},
+		{Input: `puts("a", 1, 2.5, [true, null])`, ExpectedOutput: "a 1 2.5 [true, null]\n"},
+		{Input: `puts()`, ExpectedOutput: "\n"},
+		{Input: `let f = fn(x) { puts("x =", x) }; f(1); f(2)`, ExpectedOutput: "x = 1\nx = 2\n"},
+	})
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	vmtest.RunVmTests(t, []vmtest.VmTestCase{
+		vmtest.New(`map([1, 2, 3], fn(x) { x * 2 })`, []int{2, 4, 6}),
+		vmtest.New(`let k = 10; map(range(3), fn(x) { x + k })`, []int{10, 11, 12}),
+		vmtest.New(`map(["ab", "c"], len)`, []int{2, 1}),
+		vmtest.New(`filter(range(10), fn(x) { x / 3 * 3 == x })`, []int{0, 3, 6, 9}),
+		vmtest.New(`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, 10),
+		vmtest.New(`reduce([1, 2, 3], fn(acc, x) { acc * x }, 10)`, 60),
+		vmtest.New(`reduce([], fn(acc, x) { acc + x })`, nil),
+		vmtest.New(`sort([3, 1, 2])`, []int{1, 2, 3}),
+		vmtest.New(`sort([3, 1, 2], fn(a, b) { a > b })`, []int{3, 2, 1}),
+		vmtest.New(`sort([1, "a"])`, vmtest.UserErr("`sort`: cannot compare STRING with INTEGER, a function comparing them is needed")),
+		vmtest.New(`reverse([1, 2, 3])`, []int{3, 2, 1}),
+		vmtest.New(`reverse("abc")`, "cba"),
+		vmtest.New(`map(zip([1, 2, 3], [10, 20]), fn(p) { p[0] + p[1] })`, []int{11, 22}),
+		vmtest.New(`any([1, 2, 3], fn(x) { x > 2 })`, true),
+		vmtest.New(`any([], fn(x) { true })`, false),
+		vmtest.New(`all([1, 2, 3], fn(x) { x > 0 })`, true),
+		vmtest.New(`all([true, false])`, false),
+		vmtest.New(`find([1, 2, 3, 4], fn(x) { x > 1 })`, 2),
+		vmtest.New(`find([1, 3], fn(x) { x > 3 })`, nil),
+		vmtest.New(`flat_map([1, 2], fn(x) { [x, x] })`, []int{1, 1, 2, 2}),
+		vmtest.New(`flat_map([1], fn(x) { x })`, vmtest.UserErr("`flat_map`: fn must return ARRAY, got INTEGER")),
+		vmtest.New(`unique([1, 2, 1, 3, 2])`, []int{1, 2, 3}),
+		vmtest.New(`map(1, fn(x) { x })`, vmtest.UserErr("first argument to `map` must be ARRAY, RANGE, STRING or HASH, got INTEGER")),
+		vmtest.New(`map([1], fn(x) { map([x], fn(y) { y + 1 }) })[0]`, []int{2}),
+	})
+}
+
+// TestCollectionBuiltinsNullCallbacks calls back functions returning null
+// without a return value: empty ones, and ones ending with a statement.
+func TestCollectionBuiltinsNullCallbacks(t *testing.T) {
+	vmtest.RunVmOutputTests(t, []vmtest.VmOutputTestCase{
+		{Input: `puts(map([1, 2], fn(x) {})); puts("after")`, ExpectedOutput: "[null, null]\nafter\n"},
+		{Input: `puts(map([1, 2], fn(x) { while (false) {} }))`, ExpectedOutput: "[null, null]\n"},
+		{Input: `puts(filter([1, 2], fn(x) {}), filter([1], fn(x) { let y = x; }))`, ExpectedOutput: "[] []\n"},
+		{Input: `puts(reduce([1, 2, 3], fn(a, b) {}), reduce([1], fn(a, b) { a = b; }, 0))`, ExpectedOutput: "null null\n"},
+		{Input: `puts(find([1], fn(x) {}), find([1], fn(x) { for (y in []) {} }))`, ExpectedOutput: "null null\n"},
+		{Input: `puts(sort([2, 1], fn(a, b) {}), sort([3, 1, 2], fn(a, b) { while (false) {} }))`, ExpectedOutput: "[2, 1] [3, 1, 2]\n"},
+	})
+}
+
+func TestCollectionBuiltinsCallbackErrors(t *testing.T) {
+	vmtest.RunVmTestsResultInError(t, []vmtest.VmErrorTestCase{
+		{Input: `map([1], fn(x) { x / 0 })`, ExpectedError: "division by zero"},
+		{Input: `filter([1], fn(x, y) { x })`, ExpectedError: "wrong number of arguments: want = 2, got = 1"},
+		{Input: `sort([2, 1], fn(a, b) { a + true })`, ExpectedError: "unsupported types for binary (OpAdd) operations: INTEGER BOOLEAN"},
+	})
+}
+
+func TestCall(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parser.New(lexer.New(`let add = fn(a, b) { a + b }; add`)).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	add := machine.LastPoppedStackElem()
+
+	result, err := machine.Call(add, &object.Integer{Value: 1}, &object.Integer{Value: 2})
+	if err != nil {
+		t.Fatalf("call error: %s", err)
+	}
+	if result.Inspect() != "3" {
+		t.Errorf("wrong result. got = %s, want = 3", result.Inspect())
+	}
+
+	if _, err := machine.Call(add, &object.Integer{Value: 1}); err == nil {
+		t.Errorf("expected the call with too few arguments to fail")
+	}
+	if _, err := machine.Call(add, &object.Integer{Value: 1}, &object.Boolean{Value: true}); err == nil {
+		t.Errorf("expected the call adding a boolean to fail")
+	}
+
+	result, err = machine.Call(add, &object.Integer{Value: 2}, &object.Integer{Value: 2})
+	if err != nil {
+		t.Fatalf("call error after a failed one: %s", err)
+	}
+	if result.Inspect() != "4" {
+		t.Errorf("wrong result. got = %s, want = 4", result.Inspect())
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	vmtest.RunVmTests(t, []vmtest.VmTestCase{
+		vmtest.New(`join(keys({"b": 1, "a": 2, 3: 3}), ",")`, "b,a,3"),
+		vmtest.New(`values({"b": 1, "a": 2})`, []int{1, 2}),
+		vmtest.New(`items({"b": 1})[0][1]`, 1),
+		vmtest.New(`has({"a": 1}, "a")`, true),
+		vmtest.New(`has({"a": 1}, "b")`, false),
+		vmtest.New(`has({}, [])`, vmtest.UserErr("unusable as hash key: ARRAY")),
+		vmtest.New(`let h = {"a": 1, "b": 2}; let d = delete(h, "a"); [len(d), len(h)]`, []int{1, 2}),
+		vmtest.New(`values(merge({"a": 1, "b": 2}, {"c": 3, "a": 4}))`, []int{4, 2, 3}),
+		vmtest.New(`len({"a": 1, "b": 2})`, 2),
+		vmtest.New(`let h = {"z": 1}; h["a"] = 2; h["z"] = 3; join(items(h), " ")`, `[z, 3] [a, 2]`),
+		vmtest.New(`keys([])`, vmtest.UserErr("argument to `keys` must be HASH, got ARRAY")),
+	})
+}
+
+func TestUnsetGlobal(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parser.New(lexer.New(`if (false) { let b = 1; }; puts(b + 1)`)).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	machine.SetGlobalNames(comp.GlobalNames())
+	err := machine.Run()
+
+	var vmErr *vm.VmRunError
+	if !errors.As(err, &vmErr) {
+		t.Fatalf("expected a *vm.VmRunError, got = %T (%v)", err, err)
+	}
+	if vmErr.Err.Error() != "identifier not found: b" {
+		t.Errorf("wrong error. got = %q", vmErr.Err.Error())
+	}
+}